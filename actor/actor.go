@@ -2,6 +2,8 @@ package act
 
 import (
 	"fmt"
+	"time"
+
 	"marketmonkey/event"
 
 	"github.com/anthdm/hollywood/actor"
@@ -10,3 +12,53 @@ import (
 func GetPublishPID(pair event.Pair) *actor.PID {
 	return actor.NewPID("local", fmt.Sprintf("%s/1/symbol/%s/publish/%s", pair.Exchange, pair.Symbol, pair.Symbol))
 }
+
+func GetBookPID(pair event.Pair) *actor.PID {
+	return actor.NewPID("local", fmt.Sprintf("%s/1/symbol/%s/book/%s", pair.Exchange, pair.Symbol, pair.Symbol))
+}
+
+func GetTradePID(pair event.Pair) *actor.PID {
+	return actor.NewPID("local", fmt.Sprintf("%s/1/symbol/%s/trade/%s", pair.Exchange, pair.Symbol, pair.Symbol))
+}
+
+func GetL3BookPID(pair event.Pair) *actor.PID {
+	return actor.NewPID("local", fmt.Sprintf("%s/1/symbol/%s/l3book/%s", pair.Exchange, pair.Symbol, pair.Symbol))
+}
+
+// GetConsumerPID returns the consumer of the given exchange.
+func GetConsumerPID(exchange string) *actor.PID {
+	return actor.NewPID("local", exchange+"/1")
+}
+
+func GetHealthPID() *actor.PID {
+	return actor.NewPID("local", "health/1")
+}
+
+func GetHistoryPID() *actor.PID {
+	return actor.NewPID("local", "history/1")
+}
+
+func GetProfilePID(pair event.Pair) *actor.PID {
+	return actor.NewPID("local", fmt.Sprintf("%s/1/symbol/%s/profile/%s", pair.Exchange, pair.Symbol, pair.Symbol))
+}
+
+func GetVWAPPID(pair event.Pair) *actor.PID {
+	return actor.NewPID("local", fmt.Sprintf("%s/1/symbol/%s/vwap/%s", pair.Exchange, pair.Symbol, pair.Symbol))
+}
+
+const requestTimeout = time.Second
+
+// Request sends the message to the given actor and waits for its response of
+// type T.
+func Request[T any](e *actor.Engine, pid *actor.PID, msg any) (T, error) {
+	var zero T
+	res, err := e.Request(pid, msg, requestTimeout).Result()
+	if err != nil {
+		return zero, err
+	}
+	typed, ok := res.(T)
+	if !ok {
+		return zero, fmt.Errorf("unexpected response %T from %s, want %T", res, pid, zero)
+	}
+	return typed, nil
+}
@@ -0,0 +1,111 @@
+package aggregate
+
+import (
+	"sort"
+	"time"
+
+	act "marketmonkey/actor"
+	"marketmonkey/actor/publish"
+	"marketmonkey/actor/symbol"
+	"marketmonkey/event"
+	"marketmonkey/settings"
+
+	"github.com/anthdm/hollywood/actor"
+)
+
+// Trades of different venues arrive with different latencies. We hold them
+// back for this long so they can be released ordered by exchange time.
+const reorderWindow = time.Millisecond * 250
+
+type source struct {
+	pair   event.Pair
+	symbol string
+}
+
+// Aggregate merges the trade streams of the same asset on multiple venues
+// into a synthetic pair of the aggregated market. The merged trades are fed
+// into a regular symbol actor, hence the candles, the trade tape and the
+// subscriptions work the same as for any other pair.
+type Aggregate struct {
+	symbols map[string]*actor.PID
+	sources []source
+	buffers map[string][]event.Trade
+}
+
+func New() actor.Producer {
+	return func() actor.Receiver {
+		return &Aggregate{
+			symbols: make(map[string]*actor.PID),
+			buffers: make(map[string][]event.Trade),
+		}
+	}
+}
+
+func (a *Aggregate) Receive(c *actor.Context) {
+	switch msg := c.Message().(type) {
+	case actor.Started:
+		a.start(c)
+	case actor.Stopped:
+		for _, src := range a.sources {
+			key := publish.CreateRouteKey(src.pair, event.StreamTrades, 0)
+			c.Send(act.GetPublishPID(src.pair), event.PubUnsub{Streams: []uint32{key}})
+		}
+	case event.Trade:
+		a.handleTrade(msg)
+	case event.Tick:
+		a.flush(c)
+	}
+}
+
+func (a *Aggregate) start(c *actor.Context) {
+	market := settings.Markets[settings.Aggregated]
+	for _, sym := range market.Symbols {
+		pair := event.NewPair(settings.Aggregated, sym.Name)
+		pid := c.SpawnChild(symbol.New(pair, nil), "symbol", actor.WithID(pair.Symbol))
+		a.symbols[pair.Symbol] = pid
+
+		for _, src := range settings.Aggregates[sym.Name].Sources {
+			srcPair := event.NewPair(src.Exchange, src.Symbol)
+			a.sources = append(a.sources, source{pair: srcPair, symbol: sym.Name})
+			key := publish.CreateRouteKey(srcPair, event.StreamTrades, 0)
+			c.Send(act.GetPublishPID(srcPair), event.PubSub{Streams: []uint32{key}})
+		}
+	}
+	c.SendRepeat(c.PID(), event.Tick{}, time.Millisecond*100)
+}
+
+func (a *Aggregate) handleTrade(trade event.Trade) {
+	for _, src := range a.sources {
+		if src.pair != trade.Pair {
+			continue
+		}
+		trade.Venue = trade.Pair.Exchange
+		trade.Pair = event.NewPair(settings.Aggregated, src.symbol)
+		if settings.Aggregates[src.symbol].Notional {
+			trade.Qty = trade.Qty * trade.Price
+		}
+		a.buffers[src.symbol] = append(a.buffers[src.symbol], trade)
+		return
+	}
+}
+
+// flush releases all buffered trades that are older than the reorder window
+// ordered by their exchange time.
+func (a *Aggregate) flush(c *actor.Context) {
+	watermark := time.Now().Add(-reorderWindow).UnixMilli()
+	for sym, trades := range a.buffers {
+		if len(trades) == 0 {
+			continue
+		}
+		sort.SliceStable(trades, func(i, j int) bool {
+			return trades[i].Unix < trades[j].Unix
+		})
+		n := sort.Search(len(trades), func(i int) bool {
+			return trades[i].Unix > watermark
+		})
+		for _, trade := range trades[:n] {
+			c.Send(a.symbols[sym], trade)
+		}
+		a.buffers[sym] = append(trades[:0], trades[n:]...)
+	}
+}
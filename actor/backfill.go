@@ -0,0 +1,66 @@
+package act
+
+import (
+	"marketmonkey/event"
+	"marketmonkey/pkg/ring"
+
+	"github.com/anthdm/hollywood/actor"
+)
+
+type historyEntry[T any] struct {
+	key int64
+	msg T
+}
+
+// History keeps the last messages of a stream for the backfill of new
+// subscribers. The messages are keyed by their interval, an update of the
+// last interval replaces its message.
+type History[T any] struct {
+	entries *ring.Buffer[historyEntry[T]]
+}
+
+func NewHistory[T any](capacity int) *History[T] {
+	return &History[T]{
+		entries: ring.NewBuffer[historyEntry[T]](capacity),
+	}
+}
+
+// Add appends the message of the interval key, or replaces the last one when
+// it has the same key. Messages of older intervals are dropped.
+func (h *History[T]) Add(key int64, msg T) {
+	if h.entries.Len() > 0 {
+		last := h.entries.Last()
+		switch {
+		case key == last.key:
+			h.entries.SetLast(historyEntry[T]{key: key, msg: msg})
+			return
+		case key < last.key:
+			return
+		}
+	}
+	h.entries.Push(historyEntry[T]{key: key, msg: msg})
+}
+
+// Last returns the last count messages, the oldest first. A nil history has
+// none.
+func (h *History[T]) Last(count int) []T {
+	if h == nil {
+		return nil
+	}
+	entries := h.entries.Tail(count)
+	msgs := make([]T, len(entries))
+	for i, entry := range entries {
+		msgs[i] = entry.msg
+	}
+	return msgs
+}
+
+// Backfill sends the last msg.Count of the given messages to the sender of
+// the backfill request and subscribes it to the live ones of the publisher
+// after, so they arrive in order.
+func Backfill[T any](c *actor.Context, publishPID *actor.PID, msg event.Backfill, msgs []T) {
+	for _, m := range msgs[max(0, len(msgs)-msg.Count):] {
+		c.Send(c.Sender(), m)
+	}
+	c.Engine().SendWithSender(publishPID, event.PubSub{Streams: []uint32{msg.Key}}, c.Sender())
+}
@@ -4,42 +4,53 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	act "marketmonkey/actor"
+	"marketmonkey/actor/ingress"
 	"marketmonkey/actor/symbol"
 	"marketmonkey/event"
 	"net"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/anthdm/hollywood/actor"
 	"github.com/gorilla/websocket"
 	"github.com/valyala/fastjson"
 )
 
-const wsEndpoint = "wss://dstream.binance.com/stream?streams="
+const (
+	wsEndpoint     = "wss://stream.binance.com:9443/stream?streams="
+	reconnectDelay = time.Second * 5
+)
 
 var symbols = []string{
 	"BTCUSDT",
-	// "SOLUSDT",
+	"ETHUSDT",
 }
 
 type Binance struct {
 	ws      *websocket.Conn
-	symbols map[string]*actor.PID
+	done    chan struct{}
+	symbols map[string]*ingress.Queue
 	c       *actor.Context
 }
 
 func (b *Binance) Receive(c *actor.Context) {
 	switch c.Message().(type) {
 	case actor.Started:
-		b.start(c)
 		b.c = c
+		b.start(c)
+	case actor.Stopped:
+		b.disconnect()
+	case event.Reconnect:
+		b.reconnect()
 	}
 }
 
 func New() actor.Producer {
 	return func() actor.Receiver {
 		return &Binance{
-			symbols: make(map[string]*actor.PID),
+			symbols: make(map[string]*ingress.Queue),
 		}
 	}
 }
@@ -51,27 +62,61 @@ func (b *Binance) start(c *actor.Context) {
 			Exchange: "binance",
 			Symbol:   strings.ToLower(sym),
 		}
-		pid := c.SpawnChild(symbol.New(pair), "symbol", actor.WithID(pair.Symbol))
-		b.symbols[pair.Symbol] = pid
+		queue := ingress.New(pair, ingress.DefaultConfig)
+		c.SpawnChild(symbol.New(pair, queue), "symbol", actor.WithID(pair.Symbol))
+		b.symbols[pair.Symbol] = queue
 	}
-	foo := "wss://dstream.binance.com/stream?streams=bnbusdt@aggTrade/btcusdt@markPrice"
-	ws, _, err := websocket.DefaultDialer.Dial(foo, nil)
+	b.connect()
+}
+
+func (b *Binance) connect() {
+	ws, _, err := websocket.DefaultDialer.Dial(createWsEndpoint(), nil)
 	if err != nil {
-		log.Fatal(err)
+		log.Printf("failed to connect: %v", err)
+		time.AfterFunc(reconnectDelay, func() {
+			b.c.Send(b.c.PID(), event.Reconnect{})
+		})
+		return
 	}
 	b.ws = ws
-	go b.wsLoop()
+	b.done = make(chan struct{})
+	b.c.Send(act.GetHealthPID(), event.FeedConnection{Exchange: "binance", State: event.FeedLive})
+	go b.wsLoop(ws, b.done)
+}
+
+func (b *Binance) disconnect() {
+	if b.ws == nil {
+		return
+	}
+	close(b.done)
+	b.ws.Close()
+	b.ws = nil
 }
 
-func (b *Binance) wsLoop() {
+func (b *Binance) reconnect() {
+	b.c.Send(act.GetHealthPID(), event.FeedConnection{Exchange: "binance", State: event.FeedReconnecting})
+	b.disconnect()
+	for _, queue := range b.symbols {
+		queue.Reset()
+	}
+	b.connect()
+}
+
+func (b *Binance) wsLoop(ws *websocket.Conn, done chan struct{}) {
 	for {
-		_, msg, err := b.ws.ReadMessage()
+		_, msg, err := ws.ReadMessage()
 		if err != nil {
-			if errors.Is(err, net.ErrClosed) {
-				break
+			select {
+			case <-done:
+				// We closed the connection ourselves.
+				return
+			default:
 			}
-			fmt.Println("error reading from ws connection", err)
-			continue
+			if !errors.Is(err, net.ErrClosed) {
+				fmt.Println("error reading from ws connection", err)
+			}
+			b.c.Send(b.c.PID(), event.Reconnect{})
+			return
 		}
 
 		parser := fastjson.Parser{}
@@ -80,20 +125,15 @@ func (b *Binance) wsLoop() {
 			fmt.Println("failed to parse msg", err)
 			continue
 		}
-		fmt.Println(v)
 
 		data := v.Get("data")
 		stream := string(v.GetStringBytes("stream"))
 		symbol, kind := splitStream(stream)
 
-		switch {
-		case strings.HasSuffix(stream, "markPrice"):
-			b.handleMarkPrice(symbol, data)
-		case strings.HasSuffix(stream, "depth"):
+		switch kind {
+		case "depth":
 			b.handleOrderbook(data)
-		}
-
-		if kind == "aggTrade" {
+		case "aggTrade":
 			b.handleAggTrade(symbol, data)
 		}
 	}
@@ -105,7 +145,7 @@ func (b *Binance) handleOrderbook(data *fastjson.Value) {
 		bids   = data.GetArray("b")
 		symbol = strings.ToLower(string(data.GetStringBytes("s")))
 		msg    = event.BookUpdate{
-			Unix: data.GetInt64("T"),
+			Unix: data.GetInt64("E"),
 			Pair: event.Pair{
 				Exchange: "binance",
 				Symbol:   symbol,
@@ -130,30 +170,7 @@ func (b *Binance) handleOrderbook(data *fastjson.Value) {
 			Size:  size,
 		})
 	}
-	b.c.Send(b.symbols[symbol], msg)
-}
-
-func (b *Binance) handleMarkPrice(symbol string, data *fastjson.Value) {
-	// var (
-	// 	unix         = data.GetInt64("E")
-	// 	markPriceStr = string(data.GetStringBytes("p"))
-	// 	fundingStr   = string(data.GetStringBytes("r"))
-	// )
-	// funding, _ := strconv.ParseFloat(fundingStr, 64)
-	// markPrice, _ := strconv.ParseFloat(markPriceStr, 64)
-
-	// stat := event.Stat{
-	// 	Pair: event.Pair{
-	// 		Exchange: "binancef",
-	// 		Symbol:   symbol,
-	// 	},
-	// 	MarkPrice: markPrice,
-	// 	Funding:   funding,
-	// 	Unix:      unix,
-	// }
-	// symbolPID, _ := b.symbols[symbol]
-	// b.c.Send(symbolPID, stat)
-	// b.c.Send(b.appPID, stat)
+	b.symbols[symbol].PushBook(msg)
 }
 
 func (b *Binance) handleAggTrade(symbol string, data *fastjson.Value) {
@@ -162,22 +179,21 @@ func (b *Binance) handleAggTrade(symbol string, data *fastjson.Value) {
 	trade := event.Trade{
 		Price: price,
 		Qty:   qty,
-		IsBuy: data.GetBool("m"),
+		IsBuy: !data.GetBool("m"),
 		Unix:  data.GetInt64("T"),
 		Pair: event.Pair{
 			Exchange: "binance",
 			Symbol:   symbol,
 		},
 	}
-	b.c.Send(b.symbols[symbol], trade)
+	b.symbols[symbol].PushTrade(trade)
 }
 
 func createWsEndpoint() string {
 	results := []string{}
 	for _, sym := range symbols {
 		results = append(results, fmt.Sprintf("%s@aggTrade", strings.ToLower(sym)))
-		results = append(results, fmt.Sprintf("%s@markPrice", strings.ToLower(sym)))
-		results = append(results, fmt.Sprintf("%s@depth", strings.ToLower(sym)))
+		results = append(results, fmt.Sprintf("%s@depth@100ms", strings.ToLower(sym)))
 	}
 	return fmt.Sprintf("%s%s", wsEndpoint, strings.Join(results, "/"))
 }
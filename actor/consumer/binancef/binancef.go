@@ -4,38 +4,49 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	act "marketmonkey/actor"
+	"marketmonkey/actor/ingress"
 	"marketmonkey/actor/symbol"
 	"marketmonkey/event"
 	"marketmonkey/settings"
 	"net"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/anthdm/hollywood/actor"
 	"github.com/gorilla/websocket"
 	"github.com/valyala/fastjson"
 )
 
-const wsEndpoint = "wss://fstream.binance.com/stream?streams="
+const (
+	wsEndpoint     = "wss://fstream.binance.com/stream?streams="
+	reconnectDelay = time.Second * 5
+)
 
 type Binancef struct {
 	ws      *websocket.Conn
-	symbols map[string]*actor.PID
+	done    chan struct{}
+	symbols map[string]*ingress.Queue
 	c       *actor.Context
 }
 
 func (b *Binancef) Receive(c *actor.Context) {
 	switch c.Message().(type) {
 	case actor.Started:
-		b.start(c)
 		b.c = c
+		b.start(c)
+	case actor.Stopped:
+		b.disconnect()
+	case event.Reconnect:
+		b.reconnect()
 	}
 }
 
 func New() actor.Producer {
 	return func() actor.Receiver {
 		return &Binancef{
-			symbols: make(map[string]*actor.PID),
+			symbols: make(map[string]*ingress.Queue),
 		}
 	}
 }
@@ -48,26 +59,61 @@ func (b *Binancef) start(c *actor.Context) {
 			Exchange: "binancef",
 			Symbol:   sym.Name,
 		}
-		pid := c.SpawnChild(symbol.New(pair), "symbol", actor.WithID(pair.Symbol))
-		b.symbols[pair.Symbol] = pid
+		queue := ingress.New(pair, ingress.DefaultConfig)
+		c.SpawnChild(symbol.New(pair, queue), "symbol", actor.WithID(pair.Symbol))
+		b.symbols[pair.Symbol] = queue
 	}
+	b.connect()
+}
+
+func (b *Binancef) connect() {
 	ws, _, err := websocket.DefaultDialer.Dial(createWsEndpoint(), nil)
 	if err != nil {
-		log.Fatal(err)
+		log.Printf("failed to connect: %v", err)
+		time.AfterFunc(reconnectDelay, func() {
+			b.c.Send(b.c.PID(), event.Reconnect{})
+		})
+		return
 	}
 	b.ws = ws
-	go b.wsLoop()
+	b.done = make(chan struct{})
+	b.c.Send(act.GetHealthPID(), event.FeedConnection{Exchange: "binancef", State: event.FeedLive})
+	go b.wsLoop(ws, b.done)
+}
+
+func (b *Binancef) disconnect() {
+	if b.ws == nil {
+		return
+	}
+	close(b.done)
+	b.ws.Close()
+	b.ws = nil
 }
 
-func (b *Binancef) wsLoop() {
+func (b *Binancef) reconnect() {
+	b.c.Send(act.GetHealthPID(), event.FeedConnection{Exchange: "binancef", State: event.FeedReconnecting})
+	b.disconnect()
+	for _, queue := range b.symbols {
+		queue.Reset()
+	}
+	b.connect()
+}
+
+func (b *Binancef) wsLoop(ws *websocket.Conn, done chan struct{}) {
 	for {
-		_, msg, err := b.ws.ReadMessage()
+		_, msg, err := ws.ReadMessage()
 		if err != nil {
-			if errors.Is(err, net.ErrClosed) {
-				break
+			select {
+			case <-done:
+				// We closed the connection ourselves.
+				return
+			default:
 			}
-			fmt.Println("error reading from ws connection", err)
-			continue
+			if !errors.Is(err, net.ErrClosed) {
+				fmt.Println("error reading from ws connection", err)
+			}
+			b.c.Send(b.c.PID(), event.Reconnect{})
+			return
 		}
 
 		parser := fastjson.Parser{}
@@ -125,7 +171,7 @@ func (b *Binancef) handleOrderbook(data *fastjson.Value) {
 			Size:  size,
 		})
 	}
-	b.c.Send(b.symbols[symbol], msg)
+	b.symbols[symbol].PushBook(msg)
 }
 
 func (b *Binancef) handleMarkPrice(symbol string, data *fastjson.Value) {
@@ -164,7 +210,7 @@ func (b *Binancef) handleAggTrade(symbol string, data *fastjson.Value) {
 			Symbol:   symbol,
 		},
 	}
-	b.c.Send(b.symbols[symbol], trade)
+	b.symbols[symbol].PushTrade(trade)
 }
 
 func createWsEndpoint() string {
@@ -4,6 +4,8 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	act "marketmonkey/actor"
+	"marketmonkey/actor/ingress"
 	"marketmonkey/actor/symbol"
 	"marketmonkey/event"
 	"net"
@@ -16,7 +18,10 @@ import (
 	"github.com/valyala/fastjson"
 )
 
-const wsEndpoint = "wss://stream.bybit.com/v5/public/linear"
+const (
+	wsEndpoint     = "wss://stream.bybit.com/v5/public/linear"
+	reconnectDelay = time.Second * 5
+)
 
 var symbols = []string{
 	"BTCUSDT",
@@ -26,26 +31,27 @@ var symbols = []string{
 
 type Bybit struct {
 	ws      *websocket.Conn
-	symbols map[string]*actor.PID
+	done    chan struct{}
+	symbols map[string]*ingress.Queue
 	c       *actor.Context
 }
 
 func (b *Bybit) Receive(c *actor.Context) {
 	switch c.Message().(type) {
 	case actor.Started:
-		b.start(c)
 		b.c = c
+		b.start(c)
 	case actor.Stopped:
-		if b.ws != nil {
-			b.ws.Close()
-		}
+		b.disconnect()
+	case event.Reconnect:
+		b.reconnect()
 	}
 }
 
 func New() actor.Producer {
 	return func() actor.Receiver {
 		return &Bybit{
-			symbols: make(map[string]*actor.PID),
+			symbols: make(map[string]*ingress.Queue),
 		}
 	}
 }
@@ -57,29 +63,59 @@ func (b *Bybit) start(c *actor.Context) {
 			Exchange: "bybit",
 			Symbol:   strings.ToLower(sym),
 		}
-		pid := c.SpawnChild(symbol.New(pair), "symbol", actor.WithID(pair.Symbol))
-		b.symbols[pair.Symbol] = pid
+		queue := ingress.New(pair, ingress.DefaultConfig)
+		c.SpawnChild(symbol.New(pair, queue), "symbol", actor.WithID(pair.Symbol))
+		b.symbols[pair.Symbol] = queue
 	}
+	b.connect()
+}
 
+func (b *Bybit) connect() {
 	ws, _, err := websocket.DefaultDialer.Dial(createWsEndpoint(), nil)
+	if err == nil {
+		err = b.subscribe(ws)
+	}
 	if err != nil {
-		log.Fatal(err)
+		log.Printf("failed to connect: %v", err)
+		if ws != nil {
+			ws.Close()
+		}
+		time.AfterFunc(reconnectDelay, func() {
+			b.c.Send(b.c.PID(), event.Reconnect{})
+		})
+		return
 	}
 	b.ws = ws
+	b.done = make(chan struct{})
+	b.c.Send(act.GetHealthPID(), event.FeedConnection{Exchange: "bybit", State: event.FeedLive})
+
+	go b.heartbeat(ws, b.done)
+	go b.wsLoop(ws, b.done)
+}
 
-	if err := b.subscribe(); err != nil {
-		log.Fatal(err)
+func (b *Bybit) disconnect() {
+	if b.ws == nil {
+		return
 	}
+	close(b.done)
+	b.ws.Close()
+	b.ws = nil
+}
 
-	go b.heartbeat()
-	go b.wsLoop()
+func (b *Bybit) reconnect() {
+	b.c.Send(act.GetHealthPID(), event.FeedConnection{Exchange: "bybit", State: event.FeedReconnecting})
+	b.disconnect()
+	for _, queue := range b.symbols {
+		queue.Reset()
+	}
+	b.connect()
 }
 
 func createWsEndpoint() string {
 	return wsEndpoint
 }
 
-func (b *Bybit) subscribe() error {
+func (b *Bybit) subscribe(ws *websocket.Conn) error {
 	streams := make([]string, 0, len(symbols)*2)
 	for _, sym := range symbols {
 		streams = append(streams, fmt.Sprintf("orderbook.50.%s", sym)) // orderbook stream (50 levels - 20ms frequency)
@@ -93,18 +129,24 @@ func (b *Bybit) subscribe() error {
 	}
 
 	log.Printf("Subscribing to Bybit streams: %v", streams)
-	return b.ws.WriteJSON(subMsg)
+	return ws.WriteJSON(subMsg)
 }
 
-func (b *Bybit) wsLoop() {
+func (b *Bybit) wsLoop(ws *websocket.Conn, done chan struct{}) {
 	for {
-		_, msg, err := b.ws.ReadMessage()
+		_, msg, err := ws.ReadMessage()
 		if err != nil {
-			if errors.Is(err, net.ErrClosed) {
-				break
+			select {
+			case <-done:
+				// We closed the connection ourselves.
+				return
+			default:
 			}
-			log.Printf("error reading from ws connection: %v", err)
-			continue
+			if !errors.Is(err, net.ErrClosed) {
+				log.Printf("error reading from ws connection: %v", err)
+			}
+			b.c.Send(b.c.PID(), event.Reconnect{})
+			return
 		}
 
 		parser := fastjson.Parser{}
@@ -203,8 +245,8 @@ func (b *Bybit) handleOrderbook(v *fastjson.Value) {
 		return
 	}
 
-	if pid, ok := b.symbols[symbol]; ok {
-		b.c.Send(pid, msg)
+	if queue, ok := b.symbols[symbol]; ok {
+		queue.PushBook(msg)
 	} else {
 		log.Printf("No symbol actor found for %s", symbol)
 	}
@@ -247,25 +289,38 @@ func (b *Bybit) handleTrade(v *fastjson.Value) {
 			},
 		}
 
-		if pid, ok := b.symbols[symbol]; ok {
-			b.c.Send(pid, trade)
+		if queue, ok := b.symbols[symbol]; ok {
+			queue.PushTrade(trade)
 		} else {
 			log.Printf("No symbol actor found for %s", symbol)
 		}
 	}
 }
 
-func (b *Bybit) heartbeat() {
+func (b *Bybit) heartbeat(ws *websocket.Conn, done chan struct{}) {
 	ticker := time.NewTicker(20 * time.Second)
 	defer ticker.Stop()
 
-	for range ticker.C {
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+		}
 		pingMsg := map[string]interface{}{
 			"req_id": "ping",
 			"op":     "ping",
 		}
-		if err := b.ws.WriteJSON(pingMsg); err != nil {
+		if err := ws.WriteJSON(pingMsg); err != nil {
+			select {
+			case <-done:
+				return
+			default:
+			}
 			log.Printf("Failed to send ping: %v", err)
+			// A connection we can't write to is dead, even if the reads
+			// did not notice yet.
+			b.c.Send(b.c.PID(), event.Reconnect{})
 			return
 		}
 	}
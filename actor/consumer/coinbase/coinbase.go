@@ -3,10 +3,14 @@ package coinbase
 import (
 	"errors"
 	"fmt"
+	"io"
 	"log"
+	act "marketmonkey/actor"
+	"marketmonkey/actor/ingress"
 	"marketmonkey/actor/symbol"
 	"marketmonkey/event"
 	"net"
+	"net/http"
 	"strconv"
 	"strings"
 	"time"
@@ -16,7 +20,13 @@ import (
 	"github.com/valyala/fastjson"
 )
 
-const wsEndpoint = "wss://ws-feed.exchange.coinbase.com"
+const (
+	wsEndpoint     = "wss://ws-feed.exchange.coinbase.com"
+	restEndpoint   = "https://api.exchange.coinbase.com"
+	reconnectDelay = time.Second * 5
+)
+
+var httpClient = &http.Client{Timeout: time.Second * 30}
 
 var symbols = []string{
 	"BTC-USD",
@@ -26,22 +36,32 @@ var symbols = []string{
 
 type Coinbase struct {
 	ws      *websocket.Conn
-	symbols map[string]*actor.PID
-	c       *actor.Context
+	done    chan struct{}
+	symbols map[string]*ingress.Queue
+	// product ids by symbol
+	products map[string]string
+	c        *actor.Context
 }
 
 func (b *Coinbase) Receive(c *actor.Context) {
-	switch c.Message().(type) {
+	switch msg := c.Message().(type) {
 	case actor.Started:
-		b.start(c)
 		b.c = c
+		b.start(c)
+	case actor.Stopped:
+		b.disconnect()
+	case event.Reconnect:
+		b.reconnect()
+	case event.L3Resync:
+		b.fetchSnapshot(msg.Pair)
 	}
 }
 
 func New() actor.Producer {
 	return func() actor.Receiver {
 		return &Coinbase{
-			symbols: make(map[string]*actor.PID),
+			symbols:  make(map[string]*ingress.Queue),
+			products: make(map[string]string),
 		}
 	}
 }
@@ -53,45 +73,79 @@ func (b *Coinbase) start(c *actor.Context) {
 			Exchange: "coinbase",
 			Symbol:   strings.ToLower(strings.Replace(sym, "-", "", -1)),
 		}
-		pid := c.SpawnChild(symbol.New(pair), "symbol", actor.WithID(pair.Symbol))
-		b.symbols[pair.Symbol] = pid
+		queue := ingress.New(pair, ingress.DefaultConfig)
+		c.SpawnChild(symbol.New(pair, queue), "symbol", actor.WithID(pair.Symbol))
+		b.symbols[pair.Symbol] = queue
+		b.products[pair.Symbol] = sym
 	}
+	b.connect()
+}
 
+func (b *Coinbase) connect() {
 	ws, _, err := websocket.DefaultDialer.Dial(wsEndpoint, nil)
+	if err == nil {
+		subscribeMsg := map[string]interface{}{
+			"type": "subscribe",
+			"channels": []map[string]interface{}{
+				{
+					// The full channel streams every order, the L2 book
+					// is derived from it by the L3 book of the symbol.
+					"name":        "full",
+					"product_ids": symbols,
+				},
+			},
+		}
+		err = ws.WriteJSON(subscribeMsg)
+	}
 	if err != nil {
-		log.Fatal(err)
+		log.Printf("failed to connect: %v", err)
+		if ws != nil {
+			ws.Close()
+		}
+		time.AfterFunc(reconnectDelay, func() {
+			b.c.Send(b.c.PID(), event.Reconnect{})
+		})
+		return
 	}
 	b.ws = ws
+	b.done = make(chan struct{})
+	b.c.Send(act.GetHealthPID(), event.FeedConnection{Exchange: "coinbase", State: event.FeedLive})
+	go b.wsLoop(ws, b.done)
+}
 
-	subscribeMsg := map[string]interface{}{
-		"type": "subscribe",
-		"channels": []map[string]interface{}{
-			{
-				"name":        "level2_batch",
-				"product_ids": symbols,
-			},
-			{
-				"name":        "matches",
-				"product_ids": symbols,
-			},
-		},
-	}
-	if err := ws.WriteJSON(subscribeMsg); err != nil {
-		log.Fatal(err)
+func (b *Coinbase) disconnect() {
+	if b.ws == nil {
+		return
 	}
+	close(b.done)
+	b.ws.Close()
+	b.ws = nil
+}
 
-	go b.wsLoop()
+func (b *Coinbase) reconnect() {
+	b.c.Send(act.GetHealthPID(), event.FeedConnection{Exchange: "coinbase", State: event.FeedReconnecting})
+	b.disconnect()
+	for _, queue := range b.symbols {
+		queue.Reset()
+	}
+	b.connect()
 }
 
-func (b *Coinbase) wsLoop() {
+func (b *Coinbase) wsLoop(ws *websocket.Conn, done chan struct{}) {
 	for {
-		_, msg, err := b.ws.ReadMessage()
+		_, msg, err := ws.ReadMessage()
 		if err != nil {
-			if errors.Is(err, net.ErrClosed) {
-				break
+			select {
+			case <-done:
+				// We closed the connection ourselves.
+				return
+			default:
 			}
-			fmt.Println("error reading from ws connection", err)
-			continue
+			if !errors.Is(err, net.ErrClosed) {
+				fmt.Println("error reading from ws connection", err)
+			}
+			b.c.Send(b.c.PID(), event.Reconnect{})
+			return
 		}
 
 		parser := fastjson.Parser{}
@@ -103,129 +157,123 @@ func (b *Coinbase) wsLoop() {
 
 		msgType := string(v.GetStringBytes("type"))
 		switch msgType {
-		case "snapshot":
-			b.handleSnapshot(v)
-		case "l2update":
-			b.handleOrderbook(v)
+		case "received":
+			b.handleL3(v, event.L3Received)
+		case "open":
+			b.handleL3(v, event.L3Open)
 		case "match":
 			b.handleTrade(v)
+			b.handleL3(v, event.L3Match)
+		case "change":
+			b.handleL3(v, event.L3Change)
+		case "done":
+			b.handleL3(v, event.L3Done)
 		}
 	}
 }
 
-func (b *Coinbase) handleSnapshot(data *fastjson.Value) {
+// handleL3 pushes a message of the full channel into the queue of its symbol.
+func (b *Coinbase) handleL3(data *fastjson.Value, kind event.L3EventKind) {
 	productID := string(data.GetStringBytes("product_id"))
 	symbol := strings.ToLower(strings.Replace(productID, "-", "", -1))
-
-	bidsValue := data.Get("bids")
-	asksValue := data.Get("asks")
-	if bidsValue == nil || asksValue == nil {
+	queue, ok := b.symbols[symbol]
+	if !ok {
 		return
 	}
 
-	bids := bidsValue.GetArray()
-	asks := asksValue.GetArray()
-
-	msg := event.BookUpdate{
-		Unix: parseTimestamp(string(data.GetStringBytes("time"))),
-		Pair: event.Pair{
-			Exchange: "coinbase",
-			Symbol:   symbol,
-		},
-		Bids: make([]event.BookEntry, 0, len(bids)),
-		Asks: make([]event.BookEntry, 0, len(asks)),
-	}
-
-	for _, bid := range bids {
-		bidArr := bid.GetArray()
-		if len(bidArr) < 2 {
-			continue
-		}
-		price, err := strconv.ParseFloat(string(bidArr[0].GetStringBytes()), 64)
-		if err != nil {
-			continue
-		}
-		size, err := strconv.ParseFloat(string(bidArr[1].GetStringBytes()), 64)
-		if err != nil {
-			continue
-		}
-		msg.Bids = append(msg.Bids, event.BookEntry{
-			Price: price,
-			Size:  size,
-		})
+	msg := event.L3Event{
+		Kind:    kind,
+		Seq:     data.GetInt64("sequence"),
+		Unix:    parseTimestamp(string(data.GetStringBytes("time"))),
+		OrderID: string(data.GetStringBytes("order_id")),
+		IsBid:   string(data.GetStringBytes("side")) == "buy",
 	}
-
-	for _, ask := range asks {
-		askArr := ask.GetArray()
-		if len(askArr) < 2 {
-			continue
-		}
-		price, err := strconv.ParseFloat(string(askArr[0].GetStringBytes()), 64)
-		if err != nil {
-			continue
-		}
-		size, err := strconv.ParseFloat(string(askArr[1].GetStringBytes()), 64)
-		if err != nil {
-			continue
-		}
-		msg.Asks = append(msg.Asks, event.BookEntry{
-			Price: price,
-			Size:  size,
-		})
+	msg.Price, _ = strconv.ParseFloat(string(data.GetStringBytes("price")), 64)
+	switch kind {
+	case event.L3Open:
+		msg.Size, _ = strconv.ParseFloat(string(data.GetStringBytes("remaining_size")), 64)
+	case event.L3Match:
+		msg.OrderID = string(data.GetStringBytes("maker_order_id"))
+		msg.Size, _ = strconv.ParseFloat(string(data.GetStringBytes("size")), 64)
+	case event.L3Change:
+		msg.Size, _ = strconv.ParseFloat(string(data.GetStringBytes("new_size")), 64)
 	}
-
-	b.c.Send(b.symbols[symbol], msg)
+	queue.PushL3(msg)
 }
 
-func (b *Coinbase) handleOrderbook(data *fastjson.Value) {
-	productID := string(data.GetStringBytes("product_id"))
-	symbol := strings.ToLower(strings.Replace(productID, "-", "", -1))
-
-	changesValue := data.Get("changes")
-	if changesValue == nil {
+// fetchSnapshot requests the level 3 book of the pair and pushes it into the
+// queue of its symbol. Failed requests are retried after the reconnect delay.
+func (b *Coinbase) fetchSnapshot(pair event.Pair) {
+	queue, ok := b.symbols[pair.Symbol]
+	if !ok {
 		return
 	}
-	changes := changesValue.GetArray()
-
-	msg := event.BookUpdate{
-		Unix: parseTimestamp(string(data.GetStringBytes("time"))),
-		Pair: event.Pair{
-			Exchange: "coinbase",
-			Symbol:   symbol,
-		},
-		Bids: make([]event.BookEntry, 0),
-		Asks: make([]event.BookEntry, 0),
-	}
-
-	for _, change := range changes {
-		changeArr := change.GetArray()
-		if len(changeArr) != 3 {
-			continue
-		}
-
-		side := string(changeArr[0].GetStringBytes())
-		price, err := strconv.ParseFloat(string(changeArr[1].GetStringBytes()), 64)
-		if err != nil {
-			continue
-		}
-		size, err := strconv.ParseFloat(string(changeArr[2].GetStringBytes()), 64)
+	productID := b.products[pair.Symbol]
+	go func() {
+		snapshot, err := fetchSnapshot(pair, productID)
 		if err != nil {
-			continue
+			log.Printf("failed to fetch %s snapshot: %v", productID, err)
+			time.AfterFunc(reconnectDelay, func() {
+				b.c.Send(b.c.PID(), event.L3Resync{Pair: pair})
+			})
+			return
 		}
+		queue.PushL3Snapshot(snapshot)
+	}()
+}
 
-		entry := event.BookEntry{
-			Price: price,
-			Size:  size,
-		}
+func fetchSnapshot(pair event.Pair, productID string) (event.L3Snapshot, error) {
+	resp, err := httpClient.Get(fmt.Sprintf("%s/products/%s/book?level=3", restEndpoint, productID))
+	if err != nil {
+		return event.L3Snapshot{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return event.L3Snapshot{}, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return event.L3Snapshot{}, err
+	}
+	var parser fastjson.Parser
+	v, err := parser.ParseBytes(body)
+	if err != nil {
+		return event.L3Snapshot{}, err
+	}
 
-		if side == "buy" {
-			msg.Bids = append(msg.Bids, entry)
-		} else if side == "sell" {
-			msg.Asks = append(msg.Asks, entry)
+	bids := v.GetArray("bids")
+	asks := v.GetArray("asks")
+	snapshot := event.L3Snapshot{
+		Pair:   pair,
+		Seq:    v.GetInt64("sequence"),
+		Unix:   parseTimestamp(string(v.GetStringBytes("time"))),
+		Orders: make([]event.L3Order, 0, len(bids)+len(asks)),
+	}
+	add := func(entries []*fastjson.Value, isBid bool) {
+		for _, entry := range entries {
+			arr := entry.GetArray()
+			if len(arr) < 3 {
+				continue
+			}
+			price, err := strconv.ParseFloat(string(arr[0].GetStringBytes()), 64)
+			if err != nil {
+				continue
+			}
+			size, err := strconv.ParseFloat(string(arr[1].GetStringBytes()), 64)
+			if err != nil {
+				continue
+			}
+			snapshot.Orders = append(snapshot.Orders, event.L3Order{
+				ID:    string(arr[2].GetStringBytes()),
+				IsBid: isBid,
+				Price: price,
+				Size:  size,
+			})
 		}
 	}
-
-	b.c.Send(b.symbols[symbol], msg)
+	add(bids, true)
+	add(asks, false)
+	return snapshot, nil
 }
 
 func (b *Coinbase) handleTrade(data *fastjson.Value) {
@@ -247,7 +295,7 @@ func (b *Coinbase) handleTrade(data *fastjson.Value) {
 		},
 	}
 
-	b.c.Send(b.symbols[symbol], trade)
+	b.symbols[symbol].PushTrade(trade)
 }
 
 // parseTimestamp converts Coinbase's ISO8601 timestamp to Unix milliseconds
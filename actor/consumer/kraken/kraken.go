@@ -4,6 +4,8 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	act "marketmonkey/actor"
+	"marketmonkey/actor/ingress"
 	"marketmonkey/actor/symbol"
 	"marketmonkey/event"
 	"net"
@@ -14,30 +16,41 @@ import (
 	"github.com/valyala/fastjson"
 )
 
-const wsEndpoint = "wss://ws.kraken.com/v2"
+const (
+	wsEndpoint     = "wss://ws.kraken.com/v2"
+	reconnectDelay = time.Second * 5
+)
 
-var symbols = []string{
-	"TRUMP/USD", // BTC/USD Perpetual
+// symbols maps the kraken symbols to the symbols of their pairs, the
+// aggregated market refers to them by the latter.
+var symbols = map[string]string{
+	"BTC/USD": "xbtusd",
+	"ETH/USD": "ethusd",
 }
 
 type Kraken struct {
 	ws      *websocket.Conn
-	symbols map[string]*actor.PID
+	done    chan struct{}
+	symbols map[string]*ingress.Queue
 	c       *actor.Context
 }
 
 func (k *Kraken) Receive(c *actor.Context) {
 	switch c.Message().(type) {
 	case actor.Started:
-		k.start(c)
 		k.c = c
+		k.start(c)
+	case actor.Stopped:
+		k.disconnect()
+	case event.Reconnect:
+		k.reconnect()
 	}
 }
 
 func New() actor.Producer {
 	return func() actor.Receiver {
 		return &Kraken{
-			symbols: make(map[string]*actor.PID),
+			symbols: make(map[string]*ingress.Queue),
 		}
 	}
 }
@@ -48,28 +61,69 @@ func (k *Kraken) start(c *actor.Context) {
 			Exchange: "kraken",
 			Symbol:   sym,
 		}
-		pid := c.SpawnChild(symbol.New(pair), "symbol", actor.WithID(pair.Symbol))
-		k.symbols[pair.Symbol] = pid
+		queue := ingress.New(pair, ingress.DefaultConfig)
+		c.SpawnChild(symbol.New(pair, queue), "symbol", actor.WithID(pair.Symbol))
+		k.symbols[pair.Symbol] = queue
 	}
+	k.connect()
+}
 
+func (k *Kraken) connect() {
 	ws, _, err := websocket.DefaultDialer.Dial(wsEndpoint, nil)
+	if err == nil {
+		err = subscribe(ws)
+	}
 	if err != nil {
-		log.Fatal(err)
+		log.Printf("failed to connect: %v", err)
+		if ws != nil {
+			ws.Close()
+		}
+		time.AfterFunc(reconnectDelay, func() {
+			k.c.Send(k.c.PID(), event.Reconnect{})
+		})
+		return
 	}
 	k.ws = ws
+	k.done = make(chan struct{})
+	k.c.Send(act.GetHealthPID(), event.FeedConnection{Exchange: "kraken", State: event.FeedLive})
+	go k.wsLoop(ws, k.done)
+}
+
+func (k *Kraken) disconnect() {
+	if k.ws == nil {
+		return
+	}
+	close(k.done)
+	k.ws.Close()
+	k.ws = nil
+}
+
+func (k *Kraken) reconnect() {
+	k.c.Send(act.GetHealthPID(), event.FeedConnection{Exchange: "kraken", State: event.FeedReconnecting})
+	k.disconnect()
+	for _, queue := range k.symbols {
+		queue.Reset()
+	}
+	k.connect()
+}
 
+func subscribe(ws *websocket.Conn) error {
+	names := make([]string, 0, len(symbols))
+	for name := range symbols {
+		names = append(names, name)
+	}
 	subscribeBook := map[string]any{
 		"method": "subscribe",
 		"params": map[string]any{
 			"channel": "book",
-			"symbol":  []string{"TRUMP/USD"},
+			"symbol":  names,
 		},
 	}
 	subscribeTrades := map[string]any{
 		"method": "subscribe",
 		"params": map[string]any{
 			"channel": "trade",
-			"symbol":  []string{"TRUMP/USD"},
+			"symbol":  names,
 		},
 	}
 
@@ -88,24 +142,26 @@ func (k *Kraken) start(c *actor.Context) {
 	// 	"product_ids": symbols,
 	// }
 	if err := ws.WriteJSON(subscribeBook); err != nil {
-		log.Fatal(err)
-	}
-	if err := ws.WriteJSON(subscribeTrades); err != nil {
-		log.Fatal(err)
+		return err
 	}
-
-	go k.wsLoop()
+	return ws.WriteJSON(subscribeTrades)
 }
 
-func (k *Kraken) wsLoop() {
+func (k *Kraken) wsLoop(ws *websocket.Conn, done chan struct{}) {
 	for {
-		_, msg, err := k.ws.ReadMessage()
+		_, msg, err := ws.ReadMessage()
 		if err != nil {
-			if errors.Is(err, net.ErrClosed) {
-				break
+			select {
+			case <-done:
+				// We closed the connection ourselves.
+				return
+			default:
 			}
-			fmt.Println("error reading from ws connection", err)
-			continue
+			if !errors.Is(err, net.ErrClosed) {
+				fmt.Println("error reading from ws connection", err)
+			}
+			k.c.Send(k.c.PID(), event.Reconnect{})
+			return
 		}
 
 		parser := fastjson.Parser{}
@@ -116,12 +172,11 @@ func (k *Kraken) wsLoop() {
 		}
 
 		channel := string(v.GetStringBytes("channel"))
-		symbol := string(v.GetStringBytes("symbol"))
 		data := v.GetArray("data")
 
 		switch channel {
 		case "book":
-			k.handleOrderbookDelta(symbol, data)
+			k.handleOrderbookDelta(data)
 		case "trade":
 			k.handleTrades(data)
 		}
@@ -130,7 +185,12 @@ func (k *Kraken) wsLoop() {
 
 func (k *Kraken) handleTrades(values []*fastjson.Value) {
 	for _, data := range values {
-		// {"symbol":"TRUMP/USD","side":"buy","price":69.796,"qty":5.57000,"ord_type":"market","trade_id":146163,"timestamp":"2025-01-19T09:59:44.811645Z"}
+		// {"symbol":"BTC/USD","side":"buy","price":69.796,"qty":5.57000,"ord_type":"market","trade_id":146163,"timestamp":"2025-01-19T09:59:44.811645Z"}
+		symbol := symbols[string(data.GetStringBytes("symbol"))]
+		queue, ok := k.symbols[symbol]
+		if !ok {
+			continue
+		}
 		tsRaw := data.GetStringBytes("timestamp")
 		ts, _ := time.Parse(time.RFC3339Nano, string(tsRaw))
 
@@ -138,52 +198,56 @@ func (k *Kraken) handleTrades(values []*fastjson.Value) {
 			Price: data.GetFloat64("price"),
 			Qty:   data.GetFloat64("qty"),
 			IsBuy: string(data.GetStringBytes("side")) == "buy",
-			Unix:  ts.Unix() * 1000,
+			Unix:  ts.UnixMilli(),
 			Pair: event.Pair{
 				Exchange: "kraken",
-				Symbol:   "TRUMP/USD",
+				Symbol:   symbol,
 			},
 		}
-		k.c.Send(k.symbols[trade.Pair.Symbol], trade)
+		queue.PushTrade(trade)
 	}
 }
 
-func (k *Kraken) handleOrderbookDelta(symbol string, data []*fastjson.Value) {
-	foo := data[0]
-	tsRaw := foo.GetStringBytes("timestamp")
-	ts, _ := time.Parse(time.RFC3339, string(tsRaw))
+func (k *Kraken) handleOrderbookDelta(values []*fastjson.Value) {
+	for _, data := range values {
+		symbol := symbols[string(data.GetStringBytes("symbol"))]
+		queue, ok := k.symbols[symbol]
+		if !ok {
+			continue
+		}
+		// The snapshot of the book comes without a timestamp.
+		ts, err := time.Parse(time.RFC3339Nano, string(data.GetStringBytes("timestamp")))
+		if err != nil {
+			ts = time.Now()
+		}
 
-	var (
-		asks = foo.GetArray("asks")
-		bids = foo.GetArray("bids")
-		msg  = event.BookUpdate{
-			Pair: event.Pair{
-				Exchange: "kraken",
-				Symbol:   symbol,
-			},
-			Unix: ts.Unix(),
-			Bids: make([]event.BookEntry, 0, len(asks)),
-			Asks: make([]event.BookEntry, 0, len(bids)),
+		var (
+			asks = data.GetArray("asks")
+			bids = data.GetArray("bids")
+			msg  = event.BookUpdate{
+				Pair: event.Pair{
+					Exchange: "kraken",
+					Symbol:   symbol,
+				},
+				Unix: ts.UnixMilli(),
+				Bids: make([]event.BookEntry, 0, len(bids)),
+				Asks: make([]event.BookEntry, 0, len(asks)),
+			}
+		)
+		for _, item := range asks {
+			msg.Asks = append(msg.Asks, event.BookEntry{
+				Price: item.GetFloat64("price"),
+				Size:  item.GetFloat64("qty"),
+			})
 		}
-	)
-	for _, item := range asks {
-		price := item.GetFloat64("price")
-		size := item.GetFloat64("qty")
-		msg.Asks = append(msg.Asks, event.BookEntry{
-			Price: price,
-			Size:  size,
-		})
-	}
-	for _, item := range bids {
-		price := item.GetFloat64("price")
-		size := item.GetFloat64("qty")
-		msg.Bids = append(msg.Bids, event.BookEntry{
-			Price: price,
-			Size:  size,
-		})
+		for _, item := range bids {
+			msg.Bids = append(msg.Bids, event.BookEntry{
+				Price: item.GetFloat64("price"),
+				Size:  item.GetFloat64("qty"),
+			})
+		}
+		queue.PushBook(msg)
 	}
-
-	k.c.Send(k.symbols["TRUMP/USD"], msg)
 }
 
 func (k *Kraken) handleTrade(symbol string, data *fastjson.Value) {
@@ -223,5 +287,5 @@ func (k *Kraken) processTrade(symbol string, data *fastjson.Value) {
 			Symbol:   symbol,
 		},
 	}
-	k.c.Send(k.symbols[symbol], trade)
+	k.symbols[symbol].PushTrade(trade)
 }
@@ -4,17 +4,23 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	act "marketmonkey/actor"
+	"marketmonkey/actor/ingress"
 	"marketmonkey/actor/symbol"
 	"marketmonkey/event"
 	"net"
 	"strings"
+	"time"
 
 	"github.com/anthdm/hollywood/actor"
 	"github.com/gorilla/websocket"
 	"github.com/valyala/fastjson"
 )
 
-const wsEndpoint = "wss://futures.kraken.com/ws/v1"
+const (
+	wsEndpoint     = "wss://futures.kraken.com/ws/v1"
+	reconnectDelay = time.Second * 5
+)
 
 var symbols = []string{
 	"PI_XBTUSD", // BTC/USD Perpetual
@@ -24,22 +30,27 @@ var symbols = []string{
 
 type Krakenf struct {
 	ws      *websocket.Conn
-	symbols map[string]*actor.PID
+	done    chan struct{}
+	symbols map[string]*ingress.Queue
 	c       *actor.Context
 }
 
 func (k *Krakenf) Receive(c *actor.Context) {
 	switch c.Message().(type) {
 	case actor.Started:
-		k.start(c)
 		k.c = c
+		k.start(c)
+	case actor.Stopped:
+		k.disconnect()
+	case event.Reconnect:
+		k.reconnect()
 	}
 }
 
 func New() actor.Producer {
 	return func() actor.Receiver {
 		return &Krakenf{
-			symbols: make(map[string]*actor.PID),
+			symbols: make(map[string]*ingress.Queue),
 		}
 	}
 }
@@ -51,55 +62,81 @@ func (k *Krakenf) start(c *actor.Context) {
 			Exchange: "kraken",
 			Symbol:   strings.ToLower(strings.Replace(sym, "PI_", "", -1)),
 		}
-		pid := c.SpawnChild(symbol.New(pair), "symbol", actor.WithID(pair.Symbol))
-		k.symbols[pair.Symbol] = pid
+		queue := ingress.New(pair, ingress.DefaultConfig)
+		c.SpawnChild(symbol.New(pair, queue), "symbol", actor.WithID(pair.Symbol))
+		k.symbols[pair.Symbol] = queue
 	}
+	k.connect()
+}
 
+func (k *Krakenf) connect() {
 	ws, _, err := websocket.DefaultDialer.Dial(wsEndpoint, nil)
+	if err == nil {
+		err = subscribe(ws)
+	}
 	if err != nil {
-		log.Fatal(err)
+		log.Printf("failed to connect: %v", err)
+		if ws != nil {
+			ws.Close()
+		}
+		time.AfterFunc(reconnectDelay, func() {
+			k.c.Send(k.c.PID(), event.Reconnect{})
+		})
+		return
 	}
 	k.ws = ws
+	k.done = make(chan struct{})
+	k.c.Send(act.GetHealthPID(), event.FeedConnection{Exchange: "kraken", State: event.FeedLive})
+	go k.wsLoop(ws, k.done)
+}
 
-	subscribeBook := map[string]interface{}{
-		"event":       "subscribe",
-		"feed":        "book",
-		"product_ids": symbols,
-	}
-	if err := ws.WriteJSON(subscribeBook); err != nil {
-		log.Fatal(err)
+func (k *Krakenf) disconnect() {
+	if k.ws == nil {
+		return
 	}
+	close(k.done)
+	k.ws.Close()
+	k.ws = nil
+}
 
-	subscribeTrades := map[string]interface{}{
-		"event":       "subscribe",
-		"feed":        "trade",
-		"product_ids": symbols,
-	}
-	if err := ws.WriteJSON(subscribeTrades); err != nil {
-		log.Fatal(err)
+func (k *Krakenf) reconnect() {
+	k.c.Send(act.GetHealthPID(), event.FeedConnection{Exchange: "kraken", State: event.FeedReconnecting})
+	k.disconnect()
+	for _, queue := range k.symbols {
+		queue.Reset()
 	}
+	k.connect()
+}
 
-	subscribeTradeSnapshot := map[string]interface{}{
-		"event":       "subscribe",
-		"feed":        "trade_snapshot",
-		"product_ids": symbols,
-	}
-	if err := ws.WriteJSON(subscribeTradeSnapshot); err != nil {
-		log.Fatal(err)
+func subscribe(ws *websocket.Conn) error {
+	for _, feed := range []string{"book", "trade", "trade_snapshot"} {
+		msg := map[string]interface{}{
+			"event":       "subscribe",
+			"feed":        feed,
+			"product_ids": symbols,
+		}
+		if err := ws.WriteJSON(msg); err != nil {
+			return err
+		}
 	}
-
-	go k.wsLoop()
+	return nil
 }
 
-func (k *Krakenf) wsLoop() {
+func (k *Krakenf) wsLoop(ws *websocket.Conn, done chan struct{}) {
 	for {
-		_, msg, err := k.ws.ReadMessage()
+		_, msg, err := ws.ReadMessage()
 		if err != nil {
-			if errors.Is(err, net.ErrClosed) {
-				break
+			select {
+			case <-done:
+				// We closed the connection ourselves.
+				return
+			default:
 			}
-			fmt.Println("error reading from ws connection", err)
-			continue
+			if !errors.Is(err, net.ErrClosed) {
+				fmt.Println("error reading from ws connection", err)
+			}
+			k.c.Send(k.c.PID(), event.Reconnect{})
+			return
 		}
 
 		parser := fastjson.Parser{}
@@ -156,7 +193,7 @@ func (k *Krakenf) handleOrderbookSnapshot(symbol string, data *fastjson.Value) {
 		})
 	}
 
-	k.c.Send(k.symbols[symbol], msg)
+	k.symbols[symbol].PushBook(msg)
 }
 
 func (k *Krakenf) handleOrderbookDelta(symbol string, data *fastjson.Value) {
@@ -185,7 +222,7 @@ func (k *Krakenf) handleOrderbookDelta(symbol string, data *fastjson.Value) {
 		msg.Asks = append(msg.Asks, entry)
 	}
 
-	k.c.Send(k.symbols[symbol], msg)
+	k.symbols[symbol].PushBook(msg)
 }
 
 func (k *Krakenf) handleTrade(symbol string, data *fastjson.Value) {
@@ -225,5 +262,5 @@ func (k *Krakenf) processTrade(symbol string, data *fastjson.Value) {
 			Symbol:   symbol,
 		},
 	}
-	k.c.Send(k.symbols[symbol], trade)
+	k.symbols[symbol].PushTrade(trade)
 }
@@ -0,0 +1,182 @@
+package health
+
+import (
+	"sort"
+	"time"
+
+	"marketmonkey/actor/publish"
+	"marketmonkey/event"
+	"marketmonkey/settings"
+
+	"github.com/anthdm/hollywood/actor"
+)
+
+const (
+	// A feed is flagged stale when it did not deliver a message for this long.
+	staleAfter = time.Second * 10
+	// The minimum time between two reconnects of the same venue.
+	reconnectBackoff = time.Second * 30
+)
+
+type symbolHealth struct {
+	pair       event.Pair
+	lastReport int64
+	lastUnix   int64
+	rate       float64
+	dropped    int64
+}
+
+type venueHealth struct {
+	pid           *actor.PID
+	state         event.FeedState
+	lastReconnect int64
+}
+
+// Health supervises the feeds of all venues. It collects the message stats
+// of the symbol actors, flags feeds that stopped delivering as stale and asks
+// their consumer to reconnect. The state of all feeds is published as
+// event.FeedHealth on the health market.
+type Health struct {
+	pair       event.Pair
+	symbols    map[event.Pair]*symbolHealth
+	venues     map[string]*venueHealth
+	publishPID *actor.PID
+}
+
+func New() actor.Producer {
+	return func() actor.Receiver {
+		return &Health{
+			pair:    event.NewPair(settings.Health, "feeds"),
+			symbols: make(map[event.Pair]*symbolHealth),
+			venues:  make(map[string]*venueHealth),
+		}
+	}
+}
+
+func (h *Health) Receive(c *actor.Context) {
+	switch msg := c.Message().(type) {
+	case actor.Started:
+		// Spawned under the path act.GetPublishPID expects for our pair.
+		h.publishPID = c.SpawnChild(publish.New(h.pair), "symbol/"+h.pair.Symbol+"/publish", actor.WithID(h.pair.Symbol))
+		c.SendRepeat(c.PID(), event.Tick{}, time.Second)
+	case event.FeedStats:
+		h.handleStats(msg, time.Now().UnixMilli())
+	case event.FeedConnection:
+		venue := h.getVenue(msg.Exchange)
+		venue.pid = c.Sender()
+		venue.state = msg.State
+	case event.Tick:
+		now := time.Now().UnixMilli()
+		h.supervise(c, now)
+		c.Send(h.publishPID, h.snapshot(now))
+	}
+}
+
+func (h *Health) getVenue(exchange string) *venueHealth {
+	venue, ok := h.venues[exchange]
+	if !ok {
+		venue = &venueHealth{}
+		h.venues[exchange] = venue
+	}
+	return venue
+}
+
+func (h *Health) handleStats(msg event.FeedStats, now int64) {
+	sym, ok := h.symbols[msg.Pair]
+	if !ok {
+		// Symbols that never received a message are considered fresh from
+		// the moment they reported for the first time.
+		sym = &symbolHealth{pair: msg.Pair, lastReport: now, lastUnix: now}
+		h.symbols[msg.Pair] = sym
+		h.getVenue(msg.Pair.Exchange)
+	}
+	if elapsed := now - sym.lastReport; elapsed > 0 {
+		sym.rate = float64(msg.Messages) / (float64(elapsed) / 1000)
+	}
+	sym.dropped += msg.Dropped
+	if msg.LastUnix > sym.lastUnix {
+		sym.lastUnix = msg.LastUnix
+	}
+	sym.lastReport = now
+}
+
+// supervise flags venues of which all symbols went stale and asks their
+// consumer to reconnect.
+func (h *Health) supervise(c *actor.Context, now int64) {
+	for exchange, venue := range h.venues {
+		symbols, stale := 0, 0
+		for pair, sym := range h.symbols {
+			if pair.Exchange != exchange {
+				continue
+			}
+			symbols++
+			if h.isStale(sym, now) {
+				stale++
+			}
+		}
+		if symbols == 0 || stale < symbols {
+			venue.state = event.FeedLive
+			continue
+		}
+		if venue.state == event.FeedLive {
+			venue.state = event.FeedStale
+		}
+		// Only the consumers that registered themselves can be reconnected.
+		if venue.pid == nil || now-venue.lastReconnect < reconnectBackoff.Milliseconds() {
+			continue
+		}
+		venue.lastReconnect = now
+		venue.state = event.FeedReconnecting
+		c.Send(venue.pid, event.Reconnect{})
+	}
+}
+
+func (h *Health) isStale(sym *symbolHealth, now int64) bool {
+	return now-sym.lastUnix > staleAfter.Milliseconds()
+}
+
+func (h *Health) snapshot(now int64) event.FeedHealth {
+	msg := event.FeedHealth{
+		Unix:    now,
+		Venues:  make([]event.FeedStatus, 0, len(h.venues)),
+		Symbols: make([]event.FeedStatus, 0, len(h.symbols)),
+	}
+	for exchange, venue := range h.venues {
+		status := event.FeedStatus{
+			Pair:  event.NewPair(exchange, ""),
+			State: venue.state,
+		}
+		for pair, sym := range h.symbols {
+			if pair.Exchange != exchange {
+				continue
+			}
+			status.Rate += sym.rate
+			status.Dropped += sym.dropped
+			status.LastUnix = max(status.LastUnix, sym.lastUnix)
+		}
+		msg.Venues = append(msg.Venues, status)
+	}
+	for pair, sym := range h.symbols {
+		state := event.FeedLive
+		if h.isStale(sym, now) {
+			state = event.FeedStale
+		}
+		if venue := h.venues[pair.Exchange]; venue.state == event.FeedReconnecting {
+			state = event.FeedReconnecting
+		}
+		msg.Symbols = append(msg.Symbols, event.FeedStatus{
+			Pair:     pair,
+			State:    state,
+			Rate:     sym.rate,
+			Dropped:  sym.dropped,
+			LastUnix: sym.lastUnix,
+		})
+	}
+	sort.Slice(msg.Venues, func(i, j int) bool {
+		return msg.Venues[i].Pair.Exchange < msg.Venues[j].Pair.Exchange
+	})
+	sort.Slice(msg.Symbols, func(i, j int) bool {
+		return msg.Symbols[i].Pair.String() < msg.Symbols[j].Pair.String()
+	})
+	return msg
+}
@@ -0,0 +1,203 @@
+package history
+
+import (
+	"fmt"
+	"strings"
+
+	"marketmonkey/event"
+)
+
+const (
+	binanceEndpoint        = "https://api.binance.com/api/v3"
+	binanceFuturesEndpoint = "https://fapi.binance.com/fapi/v1"
+
+	binanceKlineLimit    = 1000
+	binanceAggTradeLimit = 1000
+	// The timeframes without klines are built from the aggregated trades,
+	// they are paged back at most this many times per request.
+	maxAggTradePages = 20
+)
+
+// Binance fetches the klines of the Binance spot or futures API. The
+// timeframes the klines don't have are built from the aggregated trades.
+type Binance struct {
+	endpoint  string
+	intervals map[int64]string
+}
+
+func NewBinance(endpoint string) *Binance {
+	intervals := binanceIntervals()
+	intervals[1] = "1s"
+	return &Binance{
+		endpoint:  endpoint,
+		intervals: intervals,
+	}
+}
+
+func NewBinanceFutures(endpoint string) *Binance {
+	return &Binance{
+		endpoint:  endpoint,
+		intervals: binanceIntervals(),
+	}
+}
+
+// The weekly and monthly klines start on calendar boundaries, the live
+// candles don't, so they are left out.
+func binanceIntervals() map[int64]string {
+	return map[int64]string{
+		60:    "1m",
+		300:   "5m",
+		900:   "15m",
+		3600:  "1h",
+		86400: "1d",
+	}
+}
+
+func (b *Binance) Candles(symbol string, timeframe, end int64, count int) ([]event.Candle, error) {
+	symbol = strings.ToUpper(symbol)
+	interval, ok := b.intervals[timeframe]
+	if !ok {
+		if timeframe < 60 {
+			return b.tradeCandles(symbol, timeframe, end, count)
+		}
+		return nil, ErrUnsupported
+	}
+
+	url := fmt.Sprintf("%s/klines?symbol=%s&interval=%s&limit=%d", b.endpoint, symbol, interval, min(count, binanceKlineLimit))
+	if end > 0 {
+		url += fmt.Sprintf("&endTime=%d", end*1000-1)
+	}
+	v, err := getJSON(url)
+	if err != nil {
+		return nil, err
+	}
+	klines, err := v.Array()
+	if err != nil {
+		return nil, err
+	}
+	candles := make([]event.Candle, 0, len(klines))
+	for _, kline := range klines {
+		k := kline.GetArray()
+		if len(k) < 10 {
+			continue
+		}
+		volume := number(k[5])
+		vbuy := number(k[9])
+		tbuy, tsell := splitCount(number(k[8]), vbuy, volume)
+		candles = append(candles, event.Candle{
+			Unix:  k[0].GetInt64() / 1000,
+			Open:  number(k[1]),
+			High:  number(k[2]),
+			Low:   number(k[3]),
+			Close: number(k[4]),
+			Vbuy:  vbuy,
+			Vsell: volume - vbuy,
+			Tbuy:  tbuy,
+			Tsell: tsell,
+		})
+	}
+	return candles, nil
+}
+
+// tradeCandles builds the candles from the aggregated trades, paging back
+// from the first trade at end until count candles are covered.
+func (b *Binance) tradeCandles(symbol string, timeframe, end int64, count int) ([]event.Candle, error) {
+	url := fmt.Sprintf("%s/aggTrades?symbol=%s&limit=1", b.endpoint, symbol)
+	if end > 0 {
+		url += fmt.Sprintf("&startTime=%d", end*1000)
+	}
+	anchor, err := b.aggTrades(url)
+	if err != nil {
+		return nil, err
+	}
+	if len(anchor) == 0 {
+		return nil, nil
+	}
+	// The anchor is the latest trade, or the first one after end.
+	nextID := anchor[0].id
+	if end == 0 {
+		nextID++
+	}
+
+	var trades []aggTrade
+	for page := 0; page < maxAggTradePages && nextID > 0; page++ {
+		fromID := max(0, nextID-binanceAggTradeLimit)
+		url := fmt.Sprintf("%s/aggTrades?symbol=%s&fromId=%d&limit=%d", b.endpoint, symbol, fromID, nextID-fromID)
+		batch, err := b.aggTrades(url)
+		if err != nil {
+			return nil, err
+		}
+		if len(batch) == 0 {
+			break
+		}
+		trades = append(batch, trades...)
+		nextID = fromID
+		if trades[len(trades)-1].unix/1000-trades[0].unix/1000 >= int64(count)*timeframe {
+			break
+		}
+	}
+	return candlesFromTrades(trades, timeframe, nextID > 0), nil
+}
+
+type aggTrade struct {
+	id    int64
+	price float64
+	qty   float64
+	unix  int64
+	isBuy bool
+}
+
+func (b *Binance) aggTrades(url string) ([]aggTrade, error) {
+	v, err := getJSON(url)
+	if err != nil {
+		return nil, err
+	}
+	values, err := v.Array()
+	if err != nil {
+		return nil, err
+	}
+	trades := make([]aggTrade, 0, len(values))
+	for _, value := range values {
+		trades = append(trades, aggTrade{
+			id:    value.GetInt64("a"),
+			price: number(value.Get("p")),
+			qty:   number(value.Get("q")),
+			unix:  value.GetInt64("T"),
+			// The buyer is the maker when the trade was a market sell.
+			isBuy: !value.GetBool("m"),
+		})
+	}
+	return trades, nil
+}
+
+// candlesFromTrades samples the trades into candles. When the trades are
+// truncated at the start, the first candle is incomplete and left out.
+func candlesFromTrades(trades []aggTrade, timeframe int64, truncated bool) []event.Candle {
+	var candles []event.Candle
+	for _, trade := range trades {
+		unix := trade.unix / 1000 / timeframe * timeframe
+		if n := len(candles); n == 0 || candles[n-1].Unix != unix {
+			candles = append(candles, event.Candle{
+				Unix: unix,
+				Open: trade.price,
+				High: trade.price,
+				Low:  trade.price,
+			})
+		}
+		candle := &candles[len(candles)-1]
+		candle.Close = trade.price
+		candle.High = max(candle.High, trade.price)
+		candle.Low = min(candle.Low, trade.price)
+		if trade.isBuy {
+			candle.Vbuy += trade.qty
+			candle.Tbuy++
+		} else {
+			candle.Vsell += trade.qty
+			candle.Tsell++
+		}
+	}
+	if truncated && len(candles) > 0 {
+		candles = candles[1:]
+	}
+	return candles
+}
@@ -0,0 +1,71 @@
+package history
+
+import (
+	"fmt"
+	"strings"
+
+	"marketmonkey/event"
+)
+
+const (
+	bybitEndpoint   = "https://api.bybit.com/v5/market"
+	bybitKlineLimit = 1000
+)
+
+// Bybit fetches the klines of the linear contracts, like the consumer
+// streams them.
+type Bybit struct {
+	endpoint string
+}
+
+func NewBybit(endpoint string) *Bybit {
+	return &Bybit{endpoint: endpoint}
+}
+
+var bybitIntervals = map[int64]string{
+	60:    "1",
+	300:   "5",
+	900:   "15",
+	3600:  "60",
+	86400: "D",
+}
+
+func (b *Bybit) Candles(symbol string, timeframe, end int64, count int) ([]event.Candle, error) {
+	interval, ok := bybitIntervals[timeframe]
+	if !ok {
+		return nil, ErrUnsupported
+	}
+	url := fmt.Sprintf("%s/kline?category=linear&symbol=%s&interval=%s&limit=%d",
+		b.endpoint, strings.ToUpper(symbol), interval, min(count, bybitKlineLimit))
+	if end > 0 {
+		url += fmt.Sprintf("&end=%d", end*1000-1)
+	}
+	v, err := getJSON(url)
+	if err != nil {
+		return nil, err
+	}
+	if code := v.GetInt("retCode"); code != 0 {
+		return nil, fmt.Errorf("bybit error %d: %s", code, v.GetStringBytes("retMsg"))
+	}
+	klines := v.GetArray("result", "list")
+	candles := make([]event.Candle, 0, len(klines))
+	for _, kline := range klines {
+		k := kline.GetArray()
+		if len(k) < 6 {
+			continue
+		}
+		// Bybit has no taker volume, it is split evenly so the delta of
+		// the history is zero rather than wrong.
+		volume := number(k[5])
+		candles = append(candles, event.Candle{
+			Unix:  int64(number(k[0])) / 1000,
+			Open:  number(k[1]),
+			High:  number(k[2]),
+			Low:   number(k[3]),
+			Close: number(k[4]),
+			Vbuy:  volume / 2,
+			Vsell: volume / 2,
+		})
+	}
+	return candles, nil
+}
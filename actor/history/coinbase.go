@@ -0,0 +1,91 @@
+package history
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"marketmonkey/event"
+)
+
+const (
+	coinbaseEndpoint    = "https://api.exchange.coinbase.com"
+	coinbaseCandleLimit = 300
+)
+
+// Coinbase fetches the candles of the Coinbase Exchange API, which returns
+// at most 300 per request, so they are paged back until count is reached.
+type Coinbase struct {
+	endpoint string
+}
+
+func NewCoinbase(endpoint string) *Coinbase {
+	return &Coinbase{endpoint: endpoint}
+}
+
+var coinbaseGranularities = map[int64]bool{
+	60:    true,
+	300:   true,
+	900:   true,
+	3600:  true,
+	86400: true,
+}
+
+func (cb *Coinbase) Candles(symbol string, timeframe, end int64, count int) ([]event.Candle, error) {
+	if !coinbaseGranularities[timeframe] {
+		return nil, ErrUnsupported
+	}
+	if end == 0 {
+		end = (time.Now().Unix()/timeframe + 1) * timeframe
+	}
+
+	var candles []event.Candle
+	for len(candles) < count {
+		n := min(count-len(candles), coinbaseCandleLimit)
+		start := end - int64(n)*timeframe
+		url := fmt.Sprintf("%s/products/%s/candles?granularity=%d&start=%s&end=%s",
+			cb.endpoint, coinbaseProduct(symbol), timeframe,
+			time.Unix(start, 0).UTC().Format(time.RFC3339),
+			// The end is inclusive.
+			time.Unix(end-1, 0).UTC().Format(time.RFC3339))
+		v, err := getJSON(url)
+		if err != nil {
+			return nil, err
+		}
+		values, err := v.Array()
+		if err != nil {
+			return nil, err
+		}
+		if len(values) == 0 {
+			break
+		}
+		for _, value := range values {
+			c := value.GetArray()
+			if len(c) < 6 {
+				continue
+			}
+			// Coinbase has no taker volume, see Bybit.
+			volume := number(c[5])
+			candles = append(candles, event.Candle{
+				Unix:  int64(number(c[0])),
+				Low:   number(c[1]),
+				High:  number(c[2]),
+				Open:  number(c[3]),
+				Close: number(c[4]),
+				Vbuy:  volume / 2,
+				Vsell: volume / 2,
+			})
+		}
+		end = start
+	}
+	return candles, nil
+}
+
+// coinbaseProduct returns the product id of a symbol, btcusd is BTC-USD.
+func coinbaseProduct(symbol string) string {
+	symbol = strings.ToUpper(symbol)
+	if len(symbol) <= 3 {
+		return symbol
+	}
+	return symbol[:len(symbol)-3] + "-" + symbol[len(symbol)-3:]
+}
@@ -0,0 +1,176 @@
+package history
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	act "marketmonkey/actor"
+	"marketmonkey/event"
+
+	"github.com/anthdm/hollywood/actor"
+	"github.com/valyala/fastjson"
+)
+
+// ErrUnsupported is returned by the fetchers for the timeframes their venue
+// has no history for.
+var ErrUnsupported = errors.New("timeframe not supported")
+
+var httpClient = &http.Client{Timeout: time.Second * 30}
+
+// Fetcher fetches the candles of a symbol from the REST API of a venue. end
+// is the unix time in seconds the candles end before, zero for the latest
+// ones. The candles may be returned in any order and with gaps.
+type Fetcher interface {
+	Candles(symbol string, timeframe, end int64, count int) ([]event.Candle, error)
+}
+
+// DefaultFetchers returns the fetchers of all venues by exchange.
+func DefaultFetchers() map[string]Fetcher {
+	return map[string]Fetcher{
+		"binance":  NewBinance(binanceEndpoint),
+		"binancef": NewBinanceFutures(binanceFuturesEndpoint),
+		"bybit":    NewBybit(bybitEndpoint),
+		"coinbase": NewCoinbase(coinbaseEndpoint),
+		"kraken":   NewKraken(krakenEndpoint),
+	}
+}
+
+// History serves event.HistoryRequest with the candles of the venue of the
+// pair. The requests are fetched in the background, the response is sent to
+// the sender of the request.
+type History struct {
+	fetchers map[string]Fetcher
+}
+
+func New(fetchers map[string]Fetcher) actor.Producer {
+	return func() actor.Receiver {
+		return &History{
+			fetchers: fetchers,
+		}
+	}
+}
+
+func (h *History) Receive(c *actor.Context) {
+	switch msg := c.Message().(type) {
+	case event.HistoryRequest:
+		sender := c.Sender()
+		if sender == nil {
+			return
+		}
+		fetcher, ok := h.fetchers[msg.Pair.Exchange]
+		if !ok {
+			c.Send(sender, response(msg, nil))
+			return
+		}
+		engine := c.Engine()
+		go func() {
+			candles, err := fetcher.Candles(msg.Pair.Symbol, msg.Timeframe, msg.End, msg.Count)
+			res := response(msg, candles)
+			if err != nil && !errors.Is(err, ErrUnsupported) {
+				log.Printf("failed to fetch %s history: %v", msg.Pair, err)
+				res.Failed = true
+			}
+			engine.Send(sender, res)
+		}()
+	}
+}
+
+// Request asks the history service for candles, the response is sent to the
+// given sender. It does not wait for it, fetching can take seconds.
+func Request(e *actor.Engine, req event.HistoryRequest, sender *actor.PID) {
+	e.SendWithSender(act.GetHistoryPID(), req, sender)
+}
+
+func response(req event.HistoryRequest, candles []event.Candle) event.HistoryCandles {
+	return event.HistoryCandles{
+		Pair:      req.Pair,
+		Timeframe: req.Timeframe,
+		End:       req.End,
+		Candles:   normalize(req, candles, time.Now().Unix()),
+	}
+}
+
+// normalize sorts the candles, drops the ones outside of the request and
+// fills the gaps with flat candles, like the live candles have them. The
+// candles are closed, except the latest one while its interval is running.
+func normalize(req event.HistoryRequest, candles []event.Candle, now int64) []event.Candle {
+	byUnix := make(map[int64]event.Candle, len(candles))
+	first, last := int64(0), int64(0)
+	for _, candle := range candles {
+		if req.End > 0 && candle.Unix >= req.End {
+			continue
+		}
+		byUnix[candle.Unix] = candle
+		if first == 0 || candle.Unix < first {
+			first = candle.Unix
+		}
+		last = max(last, candle.Unix)
+	}
+	if len(byUnix) == 0 {
+		return nil
+	}
+	if req.Count > 0 && last-first >= int64(req.Count)*req.Timeframe {
+		first = last - int64(req.Count-1)*req.Timeframe
+		for _, ok := byUnix[first]; !ok; _, ok = byUnix[first] {
+			first += req.Timeframe
+		}
+	}
+
+	filled := make([]event.Candle, 0, (last-first)/req.Timeframe+1)
+	for unix := first; unix <= last; unix += req.Timeframe {
+		candle, ok := byUnix[unix]
+		if !ok {
+			price := filled[len(filled)-1].Close
+			candle = event.Candle{Open: price, Close: price, High: price, Low: price}
+		}
+		candle.Pair = req.Pair
+		candle.Timeframe = req.Timeframe
+		candle.Unix = unix
+		candle.Closed = unix+req.Timeframe <= now
+		filled = append(filled, candle)
+	}
+	return filled
+}
+
+func getJSON(url string) (*fastjson.Value, error) {
+	resp, err := httpClient.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s: %s", resp.Status, body)
+	}
+	return fastjson.ParseBytes(body)
+}
+
+// number returns a number the venues send either as a string or as a number.
+func number(v *fastjson.Value) float64 {
+	if v == nil {
+		return 0
+	}
+	if v.Type() == fastjson.TypeString {
+		f, _ := strconv.ParseFloat(string(v.GetStringBytes()), 64)
+		return f
+	}
+	return v.GetFloat64()
+}
+
+// splitCount splits the trade count of a candle by the share of the buy
+// volume, for the venues that only have the total.
+func splitCount(count, vbuy, volume float64) (tbuy, tsell float64) {
+	if volume <= 0 {
+		return 0, 0
+	}
+	tbuy = float64(int64(count*vbuy/volume + 0.5))
+	return tbuy, count - tbuy
+}
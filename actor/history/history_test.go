@@ -0,0 +1,186 @@
+package history
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"marketmonkey/event"
+)
+
+// standIn serves the given handler in place of a venue's REST API and
+// returns its URL with the path prefix the fetcher expects.
+func standIn(t *testing.T, prefix string, handler http.HandlerFunc) string {
+	t.Helper()
+	mux := http.NewServeMux()
+	mux.HandleFunc(prefix+"/", handler)
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv.URL + prefix
+}
+
+func TestBinanceKlines(t *testing.T) {
+	endpoint := standIn(t, "/api/v3", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v3/klines" {
+			http.NotFound(w, r)
+			return
+		}
+		q := r.URL.Query()
+		if q.Get("symbol") != "BTCUSDT" || q.Get("interval") != "1m" || q.Get("endTime") != "179999" {
+			t.Errorf("unexpected query %s", r.URL.RawQuery)
+		}
+		fmt.Fprint(w, `[
+			[60000,"100","110","90","105","10",119999,"0",20,"6","0","0"],
+			[120000,"105","106","104","104","4",179999,"0",4,"1","0","0"]
+		]`)
+	})
+
+	candles, err := NewBinance(endpoint).Candles("btcusdt", 60, 180, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(candles) != 2 {
+		t.Fatalf("expected 2 candles, got %d", len(candles))
+	}
+	want := event.Candle{Unix: 60, Open: 100, High: 110, Low: 90, Close: 105, Vbuy: 6, Vsell: 4, Tbuy: 12, Tsell: 8}
+	if candles[0] != want {
+		t.Fatalf("expected %+v, got %+v", want, candles[0])
+	}
+}
+
+func TestBinanceUnsupported(t *testing.T) {
+	if _, err := NewBinanceFutures("http://unused").Candles("btcusdt", 604800, 0, 10); err != ErrUnsupported {
+		t.Fatalf("expected ErrUnsupported, got %v", err)
+	}
+}
+
+func TestBinanceAggTrades(t *testing.T) {
+	// Trades 1..6, two per second starting at 10s. The futures API has no
+	// second klines, so 1s candles are built from the trades.
+	trade := func(id int) string {
+		unix := 10000 + (id-1)/2*1000 + (id-1)%2*500
+		return fmt.Sprintf(`{"a":%d,"p":"%d","q":"1","T":%d,"m":%t}`, id, 100+id, unix, id%2 == 0)
+	}
+	endpoint := standIn(t, "/fapi/v1", func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if q.Get("fromId") == "" {
+			fmt.Fprintf(w, "[%s]", trade(6))
+			return
+		}
+		from, _ := strconv.Atoi(q.Get("fromId"))
+		limit, _ := strconv.Atoi(q.Get("limit"))
+		out := "["
+		for id := max(from, 1); id < from+limit && id <= 6; id++ {
+			if id > max(from, 1) {
+				out += ","
+			}
+			out += trade(id)
+		}
+		fmt.Fprint(w, out+"]")
+	})
+
+	candles, err := NewBinanceFutures(endpoint).Candles("btcusdt", 1, 0, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(candles) != 3 {
+		t.Fatalf("expected 3 candles, got %d", len(candles))
+	}
+	want := event.Candle{Unix: 11, Open: 103, High: 104, Low: 103, Close: 104, Vbuy: 1, Vsell: 1, Tbuy: 1, Tsell: 1}
+	if candles[1] != want {
+		t.Fatalf("expected %+v, got %+v", want, candles[1])
+	}
+}
+
+func TestBybitKlines(t *testing.T) {
+	endpoint := standIn(t, "/v5/market", func(w http.ResponseWriter, r *http.Request) {
+		if q := r.URL.Query(); q.Get("category") != "linear" || q.Get("interval") != "5" {
+			t.Errorf("unexpected query %s", r.URL.RawQuery)
+		}
+		fmt.Fprint(w, `{"retCode":0,"retMsg":"OK","result":{"list":[
+			["600000","2","3","1","2.5","8","0"],
+			["300000","1","2","1","2","4","0"]
+		]}}`)
+	})
+
+	candles, err := NewBybit(endpoint).Candles("btcusdt", 300, 0, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := event.Candle{Unix: 600, Open: 2, High: 3, Low: 1, Close: 2.5, Vbuy: 4, Vsell: 4}
+	if len(candles) != 2 || candles[0] != want {
+		t.Fatalf("expected %+v first, got %+v", want, candles)
+	}
+}
+
+func TestCoinbasePaging(t *testing.T) {
+	requests := 0
+	endpoint := standIn(t, "", func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.URL.Path != "/products/BTC-USD/candles" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		if requests > 1 {
+			fmt.Fprint(w, `[]`)
+			return
+		}
+		fmt.Fprint(w, `[[120,1,3,2,2.5,6],[60,1,2,1,2,2]]`)
+	})
+
+	candles, err := NewCoinbase(endpoint).Candles("btcusd", 60, 180, 400)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if requests != 2 {
+		t.Fatalf("expected a second page to be requested, got %d requests", requests)
+	}
+	want := event.Candle{Unix: 120, Open: 2, High: 3, Low: 1, Close: 2.5, Vbuy: 3, Vsell: 3}
+	if len(candles) != 2 || candles[0] != want {
+		t.Fatalf("expected %+v first, got %+v", want, candles)
+	}
+}
+
+func TestKrakenOHLC(t *testing.T) {
+	endpoint := standIn(t, "/0/public", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"error":[],"result":{"XXBTZUSD":[
+			[60,"1","2","0.5","1.5","1.2","10",5]
+		],"last":60}}`)
+	})
+
+	candles, err := NewKraken(endpoint).Candles("xbtusd", 60, 0, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := event.Candle{Unix: 60, Open: 1, High: 2, Low: 0.5, Close: 1.5, Vbuy: 5, Vsell: 5, Tbuy: 3, Tsell: 2}
+	if len(candles) != 1 || candles[0] != want {
+		t.Fatalf("expected %+v, got %+v", want, candles)
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	pair := event.NewPair("binancef", "btcusdt")
+	req := event.HistoryRequest{Pair: pair, Timeframe: 60, End: 300, Count: 3}
+	candles := normalize(req, []event.Candle{
+		{Unix: 240, Open: 4, Close: 4},
+		{Unix: 0, Open: 1, Close: 1},
+		{Unix: 120, Open: 2, Close: 3},
+		// At the end of the request, left out.
+		{Unix: 300, Open: 5, Close: 5},
+	}, 270)
+
+	if len(candles) != 3 {
+		t.Fatalf("expected 3 candles, got %d", len(candles))
+	}
+	gap := candles[1]
+	if gap.Unix != 180 || gap.Open != 3 || gap.Close != 3 || gap.Vbuy+gap.Vsell != 0 {
+		t.Fatalf("expected a flat candle at the last close, got %+v", gap)
+	}
+	if candles[0].Pair != pair || candles[0].Timeframe != 60 || !candles[0].Closed {
+		t.Fatalf("expected the candle to be closed with pair and timeframe set, got %+v", candles[0])
+	}
+	if candles[2].Closed {
+		t.Fatal("expected the running candle to be open")
+	}
+}
@@ -0,0 +1,79 @@
+package history
+
+import (
+	"fmt"
+	"strings"
+
+	"marketmonkey/event"
+
+	"github.com/valyala/fastjson"
+)
+
+const krakenEndpoint = "https://api.kraken.com/0/public"
+
+// Kraken fetches the OHLC of the Kraken spot API. It only serves the latest
+// 720 candles of a timeframe, older ones can't be requested.
+type Kraken struct {
+	endpoint string
+}
+
+func NewKraken(endpoint string) *Kraken {
+	return &Kraken{endpoint: endpoint}
+}
+
+var krakenIntervals = map[int64]int{
+	60:    1,
+	300:   5,
+	900:   15,
+	3600:  60,
+	86400: 1440,
+}
+
+func (k *Kraken) Candles(symbol string, timeframe, end int64, count int) ([]event.Candle, error) {
+	interval, ok := krakenIntervals[timeframe]
+	if !ok {
+		return nil, ErrUnsupported
+	}
+	url := fmt.Sprintf("%s/OHLC?pair=%s&interval=%d", k.endpoint, strings.ToUpper(symbol), interval)
+	v, err := getJSON(url)
+	if err != nil {
+		return nil, err
+	}
+	if errs := v.GetArray("error"); len(errs) > 0 {
+		return nil, fmt.Errorf("kraken error: %s", errs[0].GetStringBytes())
+	}
+	result, err := v.Get("result").Object()
+	if err != nil {
+		return nil, err
+	}
+
+	var candles []event.Candle
+	result.Visit(func(key []byte, value *fastjson.Value) {
+		// The result holds the candles under the name of the pair next to
+		// the id of the last one.
+		if string(key) == "last" {
+			return
+		}
+		for _, ohlc := range value.GetArray() {
+			o := ohlc.GetArray()
+			if len(o) < 8 {
+				continue
+			}
+			// Kraken has no taker volume, see Bybit.
+			volume := number(o[6])
+			tbuy, tsell := splitCount(number(o[7]), volume/2, volume)
+			candles = append(candles, event.Candle{
+				Unix:  int64(number(o[0])),
+				Open:  number(o[1]),
+				High:  number(o[2]),
+				Low:   number(o[3]),
+				Close: number(o[4]),
+				Vbuy:  volume / 2,
+				Vsell: volume / 2,
+				Tbuy:  tbuy,
+				Tsell: tsell,
+			})
+		}
+	})
+	return candles, nil
+}
@@ -0,0 +1,218 @@
+package ingress
+
+import (
+	"sync"
+	"time"
+
+	"marketmonkey/event"
+)
+
+type Policy int
+
+const (
+	// PolicyMerge merges trades with the same price and side into the last
+	// buffered trade once the buffer is full.
+	PolicyMerge Policy = iota
+	// PolicyDrop drops incoming trades once the buffer is full.
+	PolicyDrop
+)
+
+type Config struct {
+	// FlushInterval is how often the symbol actor drains the queue.
+	FlushInterval time.Duration
+	// MaxTrades is the capacity of the trade buffer between two flushes.
+	MaxTrades int
+	Policy    Policy
+	// MaxL3Events is the capacity of the order event buffer between two
+	// flushes. Order events can't be merged, the ones beyond it are dropped
+	// and the L3 book resyncs on the gap.
+	MaxL3Events int
+}
+
+var DefaultConfig = Config{
+	FlushInterval: time.Millisecond * 50,
+	MaxTrades:     4096,
+	Policy:        PolicyMerge,
+	MaxL3Events:   65536,
+}
+
+// Stats are the counters of a queue since it was created.
+type Stats struct {
+	BookUpdates int64
+	// Coalesced is the amount of book levels that were overwritten by a
+	// newer update of the same level before they were flushed.
+	Coalesced int64
+	Trades    int64
+	Merged    int64
+	Dropped   int64
+	L3Events  int64
+}
+
+// Queue sits between the websocket loop of a consumer and its symbol actor.
+// Instead of sending every frame into the unbounded mailbox of the actor, the
+// consumer pushes into the queue which the actor drains on an interval.
+//
+// Book updates are coalesced per price level, only the latest size of a
+// level between two flushes is kept, hence the memory is bounded by the
+// amount of distinct levels. Trades are buffered up to MaxTrades, what happens
+// after is decided by the Policy.
+type Queue struct {
+	mu     sync.Mutex
+	config Config
+	pair   event.Pair
+	unix   int64
+	asks   map[float64]float64
+	bids   map[float64]float64
+	trades []event.Trade
+	// order events and the latest snapshot of an L3 feed
+	l3         []event.L3Event
+	l3Snapshot *event.L3Snapshot
+	// the next drained book update clears the book first
+	reset bool
+	stats Stats
+}
+
+func New(pair event.Pair, config Config) *Queue {
+	return &Queue{
+		config: config,
+		pair:   pair,
+		asks:   make(map[float64]float64),
+		bids:   make(map[float64]float64),
+	}
+}
+
+func (q *Queue) Config() Config {
+	return q.config
+}
+
+func (q *Queue) PushBook(msg event.BookUpdate) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.stats.BookUpdates++
+	for _, ask := range msg.Asks {
+		if _, ok := q.asks[ask.Price]; ok {
+			q.stats.Coalesced++
+		}
+		q.asks[ask.Price] = ask.Size
+	}
+	for _, bid := range msg.Bids {
+		if _, ok := q.bids[bid.Price]; ok {
+			q.stats.Coalesced++
+		}
+		q.bids[bid.Price] = bid.Size
+	}
+	if msg.Unix > q.unix {
+		q.unix = msg.Unix
+	}
+}
+
+// Reset drops the pending levels and marks the next drained book update to
+// clear the book. The consumers call it once they reconnected, before they
+// push the levels of the new connection.
+func (q *Queue) Reset() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	clear(q.asks)
+	clear(q.bids)
+	q.reset = true
+}
+
+func (q *Queue) PushTrade(msg event.Trade) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.stats.Trades++
+	if len(q.trades) < q.config.MaxTrades {
+		q.trades = append(q.trades, msg)
+		return
+	}
+	if q.config.Policy == PolicyMerge && len(q.trades) > 0 {
+		last := &q.trades[len(q.trades)-1]
+		if last.Price == msg.Price && last.IsBuy == msg.IsBuy {
+			last.Qty += msg.Qty
+			last.Unix = max(last.Unix, msg.Unix)
+			q.stats.Merged++
+			return
+		}
+	}
+	q.stats.Dropped++
+}
+
+func (q *Queue) PushL3(msg event.L3Event) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.stats.L3Events++
+	if len(q.l3) < q.config.MaxL3Events {
+		q.l3 = append(q.l3, msg)
+		return
+	}
+	q.stats.Dropped++
+}
+
+// PushL3Snapshot replaces the pending snapshot, only the latest one matters.
+func (q *Queue) PushL3Snapshot(msg event.L3Snapshot) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.l3Snapshot = &msg
+}
+
+// DrainL3 returns the order events and the snapshot that were pushed since
+// the last drain. The snapshot is nil when none arrived.
+func (q *Queue) DrainL3() (*event.L3Snapshot, []event.L3Event) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	snapshot := q.l3Snapshot
+	q.l3Snapshot = nil
+	var events []event.L3Event
+	if len(q.l3) > 0 {
+		events = make([]event.L3Event, len(q.l3))
+		copy(events, q.l3)
+		q.l3 = q.l3[:0]
+	}
+	return snapshot, events
+}
+
+// Drain returns everything that was pushed since the last drain. The book
+// update is nil when no levels changed and the book wasn't reset.
+func (q *Queue) Drain() (*event.BookUpdate, []event.Trade) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	var trades []event.Trade
+	if len(q.trades) > 0 {
+		trades = make([]event.Trade, len(q.trades))
+		copy(trades, q.trades)
+		q.trades = q.trades[:0]
+	}
+
+	if len(q.asks) == 0 && len(q.bids) == 0 && !q.reset {
+		return nil, trades
+	}
+	book := &event.BookUpdate{
+		Unix:  q.unix,
+		Pair:  q.pair,
+		Asks:  make([]event.BookEntry, 0, len(q.asks)),
+		Bids:  make([]event.BookEntry, 0, len(q.bids)),
+		Reset: q.reset,
+	}
+	q.reset = false
+	for price, size := range q.asks {
+		book.Asks = append(book.Asks, event.BookEntry{Price: price, Size: size})
+		delete(q.asks, price)
+	}
+	for price, size := range q.bids {
+		book.Bids = append(book.Bids, event.BookEntry{Price: price, Size: size})
+		delete(q.bids, price)
+	}
+	return book, trades
+}
+
+func (q *Queue) Stats() Stats {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.stats
+}
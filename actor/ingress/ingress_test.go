@@ -0,0 +1,177 @@
+package ingress
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+
+	"marketmonkey/event"
+)
+
+func sortedLevels(entries []event.BookEntry) []event.BookEntry {
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Price < entries[j].Price
+	})
+	return entries
+}
+
+func TestQueueBook(t *testing.T) {
+	tests := []struct {
+		name      string
+		updates   []event.BookUpdate
+		reset     bool
+		asks      []event.BookEntry
+		bids      []event.BookEntry
+		coalesced int64
+	}{
+		{
+			name: "distinct levels",
+			updates: []event.BookUpdate{
+				{Asks: []event.BookEntry{{Price: 11, Size: 1}}, Bids: []event.BookEntry{{Price: 9, Size: 1}}},
+				{Asks: []event.BookEntry{{Price: 12, Size: 2}}},
+			},
+			asks: []event.BookEntry{{Price: 11, Size: 1}, {Price: 12, Size: 2}},
+			bids: []event.BookEntry{{Price: 9, Size: 1}},
+		},
+		{
+			name: "latest size of a level",
+			updates: []event.BookUpdate{
+				{Asks: []event.BookEntry{{Price: 11, Size: 1}}, Bids: []event.BookEntry{{Price: 9, Size: 1}}},
+				{Asks: []event.BookEntry{{Price: 11, Size: 3}}, Bids: []event.BookEntry{{Price: 9, Size: 0}}},
+			},
+			asks:      []event.BookEntry{{Price: 11, Size: 3}},
+			bids:      []event.BookEntry{{Price: 9, Size: 0}},
+			coalesced: 2,
+		},
+		{
+			name: "same price on both sides",
+			updates: []event.BookUpdate{
+				{Asks: []event.BookEntry{{Price: 10, Size: 1}}},
+				{Bids: []event.BookEntry{{Price: 10, Size: 2}}},
+			},
+			asks: []event.BookEntry{{Price: 10, Size: 1}},
+			bids: []event.BookEntry{{Price: 10, Size: 2}},
+		},
+		{
+			name:  "reset without levels",
+			reset: true,
+		},
+	}
+	for _, tt := range tests {
+		q := New(event.Pair{}, DefaultConfig)
+		if tt.reset {
+			q.PushBook(event.BookUpdate{Asks: []event.BookEntry{{Price: 11, Size: 1}}})
+			q.Reset()
+		}
+		for _, update := range tt.updates {
+			q.PushBook(update)
+		}
+		book, _ := q.Drain()
+		if book == nil {
+			t.Errorf("%s: no book update", tt.name)
+			continue
+		}
+		if asks := sortedLevels(book.Asks); !reflect.DeepEqual(asks, append([]event.BookEntry{}, tt.asks...)) {
+			t.Errorf("%s: asks %v, want %v", tt.name, asks, tt.asks)
+		}
+		if bids := sortedLevels(book.Bids); !reflect.DeepEqual(bids, append([]event.BookEntry{}, tt.bids...)) {
+			t.Errorf("%s: bids %v, want %v", tt.name, bids, tt.bids)
+		}
+		if book.Reset != tt.reset {
+			t.Errorf("%s: reset %v, want %v", tt.name, book.Reset, tt.reset)
+		}
+		if stats := q.Stats(); stats.Coalesced != tt.coalesced {
+			t.Errorf("%s: coalesced %d, want %d", tt.name, stats.Coalesced, tt.coalesced)
+		}
+		if book, _ := q.Drain(); book != nil {
+			t.Errorf("%s: drained twice", tt.name)
+		}
+	}
+}
+
+func TestQueueTrades(t *testing.T) {
+	tests := []struct {
+		name    string
+		policy  Policy
+		trades  []event.Trade
+		want    []event.Trade
+		merged  int64
+		dropped int64
+	}{
+		{
+			name:   "within the buffer",
+			policy: PolicyDrop,
+			trades: []event.Trade{{Unix: 1, Price: 10, Qty: 1}, {Unix: 2, Price: 10, Qty: 1}},
+			want:   []event.Trade{{Unix: 1, Price: 10, Qty: 1}, {Unix: 2, Price: 10, Qty: 1}},
+		},
+		{
+			name:    "drop",
+			policy:  PolicyDrop,
+			trades:  []event.Trade{{Unix: 1, Price: 10, Qty: 1}, {Unix: 2, Price: 10, Qty: 1}, {Unix: 3, Price: 10, Qty: 1}},
+			want:    []event.Trade{{Unix: 1, Price: 10, Qty: 1}, {Unix: 2, Price: 10, Qty: 1}},
+			dropped: 1,
+		},
+		{
+			name:   "merge",
+			policy: PolicyMerge,
+			trades: []event.Trade{{Unix: 1, Price: 10, Qty: 1}, {Unix: 2, Price: 10, Qty: 1}, {Unix: 3, Price: 10, Qty: 2}},
+			want:   []event.Trade{{Unix: 1, Price: 10, Qty: 1}, {Unix: 3, Price: 10, Qty: 3}},
+			merged: 1,
+		},
+		{
+			name:    "merge at another price",
+			policy:  PolicyMerge,
+			trades:  []event.Trade{{Unix: 1, Price: 10, Qty: 1}, {Unix: 2, Price: 10, Qty: 1}, {Unix: 3, Price: 11, Qty: 1}},
+			want:    []event.Trade{{Unix: 1, Price: 10, Qty: 1}, {Unix: 2, Price: 10, Qty: 1}},
+			dropped: 1,
+		},
+		{
+			name:    "merge at another side",
+			policy:  PolicyMerge,
+			trades:  []event.Trade{{Unix: 1, Price: 10, Qty: 1}, {Unix: 2, Price: 10, Qty: 1}, {Unix: 3, Price: 10, Qty: 1, IsBuy: true}},
+			want:    []event.Trade{{Unix: 1, Price: 10, Qty: 1}, {Unix: 2, Price: 10, Qty: 1}},
+			dropped: 1,
+		},
+	}
+	for _, tt := range tests {
+		q := New(event.Pair{}, Config{MaxTrades: 2, Policy: tt.policy})
+		for _, trade := range tt.trades {
+			q.PushTrade(trade)
+		}
+		book, trades := q.Drain()
+		if book != nil {
+			t.Errorf("%s: book update without levels", tt.name)
+		}
+		if !reflect.DeepEqual(trades, tt.want) {
+			t.Errorf("%s: trades %v, want %v", tt.name, trades, tt.want)
+		}
+		stats := q.Stats()
+		if stats.Trades != int64(len(tt.trades)) || stats.Merged != tt.merged || stats.Dropped != tt.dropped {
+			t.Errorf("%s: %d trades, %d merged, %d dropped, want %d, %d, %d", tt.name,
+				stats.Trades, stats.Merged, stats.Dropped, len(tt.trades), tt.merged, tt.dropped)
+		}
+	}
+}
+
+func TestQueueL3Overflow(t *testing.T) {
+	q := New(event.Pair{}, Config{MaxL3Events: 2})
+	for i := range 3 {
+		q.PushL3(event.L3Event{Seq: int64(i)})
+	}
+	q.PushL3Snapshot(event.L3Snapshot{Seq: 1})
+	q.PushL3Snapshot(event.L3Snapshot{Seq: 2})
+
+	snapshot, events := q.DrainL3()
+	if snapshot == nil || snapshot.Seq != 2 {
+		t.Errorf("snapshot = %v, want the latest", snapshot)
+	}
+	if len(events) != 2 || events[0].Seq != 0 || events[1].Seq != 1 {
+		t.Errorf("events = %v, want the first two", events)
+	}
+	if stats := q.Stats(); stats.L3Events != 3 || stats.Dropped != 1 {
+		t.Errorf("%d events, %d dropped, want 3, 1", stats.L3Events, stats.Dropped)
+	}
+	if snapshot, events := q.DrainL3(); snapshot != nil || events != nil {
+		t.Errorf("drained twice")
+	}
+}
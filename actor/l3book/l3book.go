@@ -0,0 +1,344 @@
+package l3book
+
+import (
+	act "marketmonkey/actor"
+	"marketmonkey/event"
+	"time"
+
+	"github.com/anthdm/hollywood/actor"
+	"github.com/tidwall/btree"
+)
+
+const (
+	// levels per side of the published order detail
+	publishDepth = 20
+	// order events buffered while waiting for a snapshot, beyond it they
+	// are all thrown away and a new snapshot is requested
+	maxPending = 1 << 20
+)
+
+type order struct {
+	id    string
+	isBid bool
+	price float64
+	size  float64
+	// when the order was opened, or when the snapshot was taken for the
+	// orders that were already resting
+	unix int64
+}
+
+type level struct {
+	size float64
+	// orders in the order of the queue
+	orders []*order
+}
+
+// Book maintains the order-by-order book of a venue with an L3 feed. The
+// L2 levels are derived from the orders and sent to the orderbook actor of
+// the symbol, so all the existing streams work unchanged. On top of that it
+// publishes the order count and age per level and answers queue position
+// requests.
+//
+// The book is built from an L3Snapshot, the events are applied in sequence
+// after it. On a gap the consumer is asked for a new snapshot and the events
+// are buffered until it arrives.
+type Book struct {
+	pair   event.Pair
+	orders map[string]*order
+	asks   *btree.Map[float64, *level]
+	bids   *btree.Map[float64, *level]
+	seq    int64
+
+	synced    bool
+	resyncing bool
+	pending   []event.L3Event
+
+	// prices of the levels that changed since the last L2 update
+	changedAsks map[float64]struct{}
+	changedBids map[float64]struct{}
+	lastUnix    int64
+
+	bookPID    *actor.PID
+	publishPID *actor.PID
+}
+
+func New(pair event.Pair) actor.Producer {
+	return func() actor.Receiver {
+		return &Book{
+			pair:        pair,
+			orders:      make(map[string]*order),
+			asks:        btree.NewMap[float64, *level](0),
+			bids:        btree.NewMap[float64, *level](0),
+			changedAsks: make(map[float64]struct{}),
+			changedBids: make(map[float64]struct{}),
+		}
+	}
+}
+
+func (b *Book) Receive(c *actor.Context) {
+	switch msg := c.Message().(type) {
+	case actor.Started:
+		b.bookPID = c.Parent().Child("book/" + b.pair.Symbol)
+		b.publishPID = c.Parent().Child("publish/" + b.pair.Symbol)
+		c.SendRepeat(c.PID(), event.Tick{}, time.Millisecond*200)
+		b.requestSnapshot(c)
+	case event.L3Snapshot:
+		b.applySnapshot(msg)
+		b.requestSnapshot(c)
+		b.publishL2(c)
+	case event.L3Update:
+		for _, ev := range msg.Events {
+			b.apply(ev)
+		}
+		b.requestSnapshot(c)
+		b.publishL2(c)
+	case event.QueuePositionRequest:
+		c.Respond(b.queuePosition(msg.OrderID))
+	case event.Tick:
+		b.publishLevels(c)
+	}
+}
+
+// requestSnapshot asks the consumer for a snapshot while the book is out of
+// sync, unless one is already on its way.
+func (b *Book) requestSnapshot(c *actor.Context) {
+	if b.synced || b.resyncing {
+		return
+	}
+	b.resyncing = true
+	c.Send(act.GetConsumerPID(b.pair.Exchange), event.L3Resync{Pair: b.pair})
+}
+
+func (b *Book) apply(ev event.L3Event) {
+	if !b.synced {
+		if len(b.pending) == maxPending {
+			// The snapshot did not arrive in time, a new one is requested
+			// and has to cover everything buffered so far.
+			b.pending = nil
+			b.resyncing = false
+		}
+		b.pending = append(b.pending, ev)
+		return
+	}
+	if ev.Seq <= b.seq {
+		// Already part of the snapshot.
+		return
+	}
+	if ev.Seq != b.seq+1 {
+		b.synced = false
+		b.pending = append(b.pending, ev)
+		return
+	}
+	b.seq = ev.Seq
+	b.lastUnix = ev.Unix
+
+	switch ev.Kind {
+	case event.L3Open:
+		b.open(&order{
+			id:    ev.OrderID,
+			isBid: ev.IsBid,
+			price: ev.Price,
+			size:  ev.Size,
+			unix:  ev.Unix,
+		})
+	case event.L3Match:
+		if o, ok := b.orders[ev.OrderID]; ok {
+			b.resize(o, o.size-ev.Size)
+		}
+	case event.L3Change:
+		if o, ok := b.orders[ev.OrderID]; ok {
+			b.resize(o, ev.Size)
+		}
+	case event.L3Done:
+		if o, ok := b.orders[ev.OrderID]; ok {
+			b.remove(o)
+		}
+	}
+}
+
+func (b *Book) applySnapshot(msg event.L3Snapshot) {
+	// Every level of the old book is sent again, the ones that are gone are
+	// removed from the L2 book with a size of zero.
+	b.asks.Scan(func(price float64, _ *level) bool {
+		b.changedAsks[price] = struct{}{}
+		return true
+	})
+	b.bids.Scan(func(price float64, _ *level) bool {
+		b.changedBids[price] = struct{}{}
+		return true
+	})
+	b.orders = make(map[string]*order, len(msg.Orders))
+	b.asks.Clear()
+	b.bids.Clear()
+	for _, o := range msg.Orders {
+		b.open(&order{
+			id:    o.ID,
+			isBid: o.IsBid,
+			price: o.Price,
+			size:  o.Size,
+			unix:  msg.Unix,
+		})
+	}
+	b.seq = msg.Seq
+	b.lastUnix = msg.Unix
+	b.synced = true
+	b.resyncing = false
+
+	pending := b.pending
+	b.pending = nil
+	for _, ev := range pending {
+		b.apply(ev)
+	}
+}
+
+func (b *Book) levels(isBid bool) (*btree.Map[float64, *level], map[float64]struct{}) {
+	if isBid {
+		return b.bids, b.changedBids
+	}
+	return b.asks, b.changedAsks
+}
+
+func (b *Book) open(o *order) {
+	levels, changed := b.levels(o.isBid)
+	lvl, ok := levels.Get(o.price)
+	if !ok {
+		lvl = &level{}
+		levels.Set(o.price, lvl)
+	}
+	lvl.orders = append(lvl.orders, o)
+	lvl.size += o.size
+	b.orders[o.id] = o
+	changed[o.price] = struct{}{}
+}
+
+// resize changes the size of an order, it keeps its place in the queue.
+func (b *Book) resize(o *order, size float64) {
+	levels, changed := b.levels(o.isBid)
+	lvl, ok := levels.Get(o.price)
+	if !ok {
+		return
+	}
+	size = max(size, 0)
+	lvl.size += size - o.size
+	o.size = size
+	changed[o.price] = struct{}{}
+}
+
+func (b *Book) remove(o *order) {
+	delete(b.orders, o.id)
+	levels, changed := b.levels(o.isBid)
+	lvl, ok := levels.Get(o.price)
+	if !ok {
+		return
+	}
+	for i, other := range lvl.orders {
+		if other == o {
+			lvl.orders = append(lvl.orders[:i], lvl.orders[i+1:]...)
+			break
+		}
+	}
+	lvl.size -= o.size
+	if len(lvl.orders) == 0 {
+		levels.Delete(o.price)
+	}
+	changed[o.price] = struct{}{}
+}
+
+// publishL2 sends the levels that changed since the last call to the
+// orderbook actor.
+func (b *Book) publishL2(c *actor.Context) {
+	if len(b.changedAsks) == 0 && len(b.changedBids) == 0 {
+		return
+	}
+	msg := event.BookUpdate{
+		Unix: b.lastUnix,
+		Pair: b.pair,
+		Asks: b.changes(b.asks, b.changedAsks),
+		Bids: b.changes(b.bids, b.changedBids),
+	}
+	c.Send(b.bookPID, msg)
+}
+
+func (b *Book) changes(levels *btree.Map[float64, *level], changed map[float64]struct{}) []event.BookEntry {
+	entries := make([]event.BookEntry, 0, len(changed))
+	for price := range changed {
+		size := 0.0
+		if lvl, ok := levels.Get(price); ok {
+			size = max(lvl.size, 0)
+		}
+		entries = append(entries, event.BookEntry{Price: price, Size: size})
+		delete(changed, price)
+	}
+	return entries
+}
+
+func (b *Book) publishLevels(c *actor.Context) {
+	if !b.synced || b.asks.Len() == 0 || b.bids.Len() == 0 {
+		return
+	}
+	now := time.Now().UnixMilli()
+	msg := event.OrderLevels{
+		Pair: b.pair,
+		Unix: now,
+		Asks: make([]event.OrderLevel, 0, publishDepth),
+		Bids: make([]event.OrderLevel, 0, publishDepth),
+	}
+	b.bids.Reverse(func(price float64, lvl *level) bool {
+		msg.Bids = append(msg.Bids, orderLevel(price, lvl, now))
+		return len(msg.Bids) < publishDepth
+	})
+	b.asks.Scan(func(price float64, lvl *level) bool {
+		msg.Asks = append(msg.Asks, orderLevel(price, lvl, now))
+		return len(msg.Asks) < publishDepth
+	})
+	c.Send(b.publishPID, msg)
+}
+
+func orderLevel(price float64, lvl *level, now int64) event.OrderLevel {
+	oldest := now
+	for _, o := range lvl.orders {
+		oldest = min(oldest, o.unix)
+	}
+	// The queue is in time priority, so its middle order is the median age.
+	median := lvl.orders[len(lvl.orders)/2].unix
+	return event.OrderLevel{
+		Price:     price,
+		Size:      max(lvl.size, 0),
+		Orders:    len(lvl.orders),
+		OldestAge: now - oldest,
+		MedianAge: now - median,
+	}
+}
+
+func (b *Book) queuePosition(id string) event.QueuePosition {
+	msg := event.QueuePosition{OrderID: id}
+	o, ok := b.orders[id]
+	if !ok {
+		return msg
+	}
+	levels, _ := b.levels(o.isBid)
+	lvl, ok := levels.Get(o.price)
+	if !ok {
+		return msg
+	}
+	msg.Found = true
+	msg.Price = o.price
+	msg.IsBid = o.isBid
+	msg.Size = o.size
+	msg.Orders = len(lvl.orders)
+	msg.Age = time.Now().UnixMilli() - o.unix
+	for _, other := range lvl.orders {
+		if other == o {
+			break
+		}
+		msg.Ahead += other.size
+		msg.Position++
+	}
+	return msg
+}
+
+// QueuePosition requests the queue position of an order from the L3 book of
+// the given pair.
+func QueuePosition(e *actor.Engine, pair event.Pair, orderID string) (event.QueuePosition, error) {
+	return act.Request[event.QueuePosition](e, act.GetL3BookPID(pair), event.QueuePositionRequest{OrderID: orderID})
+}
@@ -0,0 +1,145 @@
+package l3book
+
+import (
+	"testing"
+
+	"marketmonkey/event"
+)
+
+func newTestBook() *Book {
+	return New(event.NewPair("coinbase", "btcusd"))().(*Book)
+}
+
+func open(seq int64, id string, isBid bool, price, size float64) event.L3Event {
+	return event.L3Event{Kind: event.L3Open, Seq: seq, OrderID: id, IsBid: isBid, Price: price, Size: size}
+}
+
+func levelSize(t *testing.T, b *Book, isBid bool, price float64) float64 {
+	t.Helper()
+	levels, _ := b.levels(isBid)
+	lvl, ok := levels.Get(price)
+	if !ok {
+		return 0
+	}
+	return lvl.size
+}
+
+func TestSnapshotThenReplay(t *testing.T) {
+	b := newTestBook()
+	// Buffered while there is no snapshot, the first one is already part
+	// of it.
+	b.apply(open(10, "c", true, 100, 1))
+	b.apply(open(11, "d", false, 101, 2))
+	b.apply(event.L3Event{Kind: event.L3Match, Seq: 12, OrderID: "a", IsBid: true, Price: 100, Size: 0.5})
+	if b.synced || len(b.pending) != 3 {
+		t.Fatalf("expected 3 pending events before the snapshot, got %d", len(b.pending))
+	}
+
+	b.applySnapshot(event.L3Snapshot{
+		Seq: 10,
+		Orders: []event.L3Order{
+			{ID: "a", IsBid: true, Price: 100, Size: 2},
+			{ID: "b", IsBid: true, Price: 100, Size: 3},
+			{ID: "c", IsBid: true, Price: 100, Size: 1},
+		},
+	})
+	if !b.synced || len(b.pending) != 0 {
+		t.Fatalf("expected the book to be synced without pending events")
+	}
+	if b.seq != 12 {
+		t.Fatalf("expected seq 12, got %d", b.seq)
+	}
+	if size := levelSize(t, b, true, 100); size != 5.5 {
+		t.Fatalf("expected bid level 100 of 5.5, got %v", size)
+	}
+	if size := levelSize(t, b, false, 101); size != 2 {
+		t.Fatalf("expected ask level 101 of 2, got %v", size)
+	}
+	if len(b.orders) != 4 {
+		t.Fatalf("expected 4 orders, got %d", len(b.orders))
+	}
+}
+
+func TestGapTriggersResync(t *testing.T) {
+	b := newTestBook()
+	b.applySnapshot(event.L3Snapshot{Seq: 10})
+	b.apply(open(11, "a", true, 100, 1))
+	if !b.synced || b.resyncing {
+		t.Fatalf("expected the book to stay synced")
+	}
+
+	b.apply(open(13, "b", true, 100, 1))
+	if b.synced {
+		t.Fatalf("expected the gap to unsync the book")
+	}
+	if b.seq != 11 {
+		t.Fatalf("expected the event after the gap not to be applied, seq %d", b.seq)
+	}
+	b.apply(open(14, "c", true, 100, 1))
+	if len(b.pending) != 2 {
+		t.Fatalf("expected 2 pending events, got %d", len(b.pending))
+	}
+
+	// The new snapshot covers 13, only 14 is replayed on top of it.
+	b.resyncing = true
+	b.applySnapshot(event.L3Snapshot{
+		Seq: 13,
+		Orders: []event.L3Order{
+			{ID: "a", IsBid: true, Price: 100, Size: 1},
+			{ID: "b", IsBid: true, Price: 100, Size: 1},
+		},
+	})
+	if !b.synced || b.resyncing || b.seq != 14 {
+		t.Fatalf("expected the book synced at seq 14, got synced %v seq %d", b.synced, b.seq)
+	}
+	if size := levelSize(t, b, true, 100); size != 3 {
+		t.Fatalf("expected bid level 100 of 3, got %v", size)
+	}
+}
+
+func TestPendingOverflowResyncs(t *testing.T) {
+	b := newTestBook()
+	b.resyncing = true
+	for i := 0; i < maxPending; i++ {
+		b.pending = append(b.pending, event.L3Event{Seq: int64(i)})
+	}
+	b.apply(open(int64(maxPending), "a", true, 100, 1))
+	if len(b.pending) != 1 || b.resyncing {
+		t.Fatalf("expected the buffer dropped and a new snapshot due, got %d pending", len(b.pending))
+	}
+}
+
+func TestQueuePosition(t *testing.T) {
+	b := newTestBook()
+	b.applySnapshot(event.L3Snapshot{
+		Seq: 1,
+		Orders: []event.L3Order{
+			{ID: "a", IsBid: false, Price: 101, Size: 2},
+			{ID: "b", IsBid: false, Price: 101, Size: 3},
+			{ID: "c", IsBid: false, Price: 101, Size: 4},
+		},
+	})
+	expect := func(position int, ahead float64) {
+		t.Helper()
+		pos := b.queuePosition("c")
+		if !pos.Found || pos.Position != position || pos.Ahead != ahead {
+			t.Fatalf("expected position %d with %v ahead, got %+v", position, ahead, pos)
+		}
+	}
+	expect(2, 5)
+
+	b.apply(event.L3Event{Kind: event.L3Match, Seq: 2, OrderID: "a", Price: 101, Size: 1.5})
+	expect(2, 3.5)
+
+	// A change keeps the order in its place.
+	b.apply(event.L3Event{Kind: event.L3Change, Seq: 3, OrderID: "b", Price: 101, Size: 1})
+	expect(2, 1.5)
+
+	b.apply(event.L3Event{Kind: event.L3Done, Seq: 4, OrderID: "a", Price: 101})
+	expect(1, 1)
+
+	b.apply(event.L3Event{Kind: event.L3Done, Seq: 5, OrderID: "c", Price: 101})
+	if pos := b.queuePosition("c"); pos.Found {
+		t.Fatalf("expected the done order to be gone, got %+v", pos)
+	}
+}
@@ -6,28 +6,28 @@ import (
 	"sort"
 )
 
-func (o *Orderbook) calculateHeatmap() event.Heatmap {
+// calculateHeatmap sums the levels into buckets of the given grouping in
+// ticks, the heatmap is published under it.
+func (o *Orderbook) calculateHeatmap(grouping int64) event.Heatmap {
 	depth := 500
-	bidMap := map[float64]float64{}
+	bidMap := map[int64]float64{}
 	maxSize := 0.0
 	unix := o.lastUnix / 1000
 
-	o.bids.Descend(1000000, func(price float64, size float64) bool {
+	o.bids.Reverse(func(tick int64, size float64) bool {
 		if len(bidMap) == depth {
 			return false
 		}
-		groupedPrice := math.Floor(price/o.priceGroup) * o.priceGroup
-		bidMap[groupedPrice] += size
+		bidMap[floorGroup(tick, grouping)] += size
 		return true
 	})
 
-	askMap := map[float64]float64{}
-	o.asks.Ascend(0, func(price float64, size float64) bool {
+	askMap := map[int64]float64{}
+	o.asks.Scan(func(tick int64, size float64) bool {
 		if len(askMap) == depth {
 			return false
 		}
-		groupedPrice := math.Floor(price/o.priceGroup) * o.priceGroup
-		askMap[groupedPrice] += size
+		askMap[floorGroup(tick, grouping)] += size
 		return true
 	})
 
@@ -43,30 +43,31 @@ func (o *Orderbook) calculateHeatmap() event.Heatmap {
 	}
 
 	return event.Heatmap{
-		PriceGroup: o.priceGroup,
+		PriceGroup: o.ticks.toPrice(grouping),
+		Grouping:   grouping,
 		Unix:       unix,
 		Pair:       o.pair,
-		Levels:     flattenAndSort(bidMap, askMap, maxSize),
+		Levels:     o.flattenAndSort(bidMap, askMap, maxSize),
 	}
 }
 
-func flattenAndSort(bids map[float64]float64, asks map[float64]float64, maxSize float64) []event.HeatmapLevel {
+func (o *Orderbook) flattenAndSort(bids map[int64]float64, asks map[int64]float64, maxSize float64) []event.HeatmapLevel {
 	levels := make([]event.HeatmapLevel, len(bids)+len(asks))
 
 	i := 0
-	for price, size := range asks {
+	for tick, size := range asks {
 		val := math.Log10(size+1) / math.Log10(maxSize+1)
 		levels[i] = event.HeatmapLevel{
-			Price:     price,
+			Price:     o.ticks.toPrice(tick),
 			Size:      size,
 			Intensity: clamp(val, 0, 1),
 		}
 		i++
 	}
-	for price, size := range bids {
+	for tick, size := range bids {
 		val := math.Log10(size+1) / math.Log10(maxSize+1)
 		levels[i] = event.HeatmapLevel{
-			Price:     price,
+			Price:     o.ticks.toPrice(tick),
 			Size:      size,
 			Intensity: clamp(val, 0, 1),
 		}
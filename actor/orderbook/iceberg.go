@@ -0,0 +1,125 @@
+package orderbook
+
+import (
+	"marketmonkey/event"
+	"marketmonkey/settings"
+	"time"
+
+	"github.com/anthdm/hollywood/actor"
+)
+
+type icebergLevel struct {
+	id        uint64
+	isBid     bool
+	displayed float64
+	traded    float64
+	refills   int
+	firstHit  int64
+	lastHit   int64
+	// hidden size of the last publish
+	published float64
+}
+
+type icebergKey struct {
+	tick  int64
+	isBid bool
+}
+
+// icebergs correlates the trades with the book updates of the levels they
+// hit. A level that prints more than it ever displayed, or keeps refilling
+// after being hit, is likely an iceberg order.
+type icebergs struct {
+	config settings.IcebergConfig
+	levels map[icebergKey]*icebergLevel
+	nextID uint64
+}
+
+func newIcebergs(config settings.IcebergConfig) *icebergs {
+	return &icebergs{
+		config: config,
+		levels: make(map[icebergKey]*icebergLevel),
+	}
+}
+
+// addTrade records a trade against the resting side of the given tick,
+// displayed is the size of the level before the trade.
+func (i *icebergs) addTrade(tick int64, qty float64, isBid bool, displayed float64) {
+	key := icebergKey{tick, isBid}
+	now := time.Now().UnixMilli()
+	level, ok := i.levels[key]
+	if !ok {
+		if displayed == 0 {
+			// We don't know the level, it is outside the window or the
+			// book is lagging behind the trades.
+			return
+		}
+		i.nextID++
+		level = &icebergLevel{
+			id:       i.nextID,
+			isBid:    key.isBid,
+			firstHit: now,
+		}
+		i.levels[key] = level
+	}
+	level.displayed = max(level.displayed, displayed)
+	level.traded += qty
+	level.lastHit = now
+}
+
+func (i *icebergs) updateLevel(tick int64, prev, size float64, isBid bool) {
+	level, ok := i.levels[icebergKey{tick, isBid}]
+	if !ok {
+		return
+	}
+	if size > prev && prev < level.displayed {
+		level.refills++
+	}
+	level.displayed = max(level.displayed, size)
+}
+
+func (i *icebergs) isIceberg(level *icebergLevel) bool {
+	if level.traded <= level.displayed {
+		return false
+	}
+	return level.traded >= level.displayed*i.config.MinRatio || level.refills >= i.config.MinRefills
+}
+
+// flush returns the icebergs that were detected or grew since the last
+// flush and forgets the levels that were not hit within the window.
+func (i *icebergs) flush(pair event.Pair, ticks ticks) []event.Iceberg {
+	now := time.Now().UnixMilli()
+	expiry := now - i.config.Window.Milliseconds()
+	var msgs []event.Iceberg
+	for key, level := range i.levels {
+		if level.lastHit < expiry {
+			delete(i.levels, key)
+			continue
+		}
+		if !i.isIceberg(level) {
+			continue
+		}
+		hidden := level.traded - level.displayed
+		if hidden <= level.published {
+			continue
+		}
+		level.published = hidden
+		msgs = append(msgs, event.Iceberg{
+			ID:        level.id,
+			Pair:      pair,
+			Unix:      level.firstHit,
+			Price:     ticks.toPrice(key.tick),
+			IsBid:     level.isBid,
+			Displayed: level.displayed,
+			Traded:    level.traded,
+			Hidden:    hidden,
+			Refills:   level.refills,
+		})
+	}
+	return msgs
+}
+
+func (o *Orderbook) publishIcebergs(c *actor.Context) {
+	for _, msg := range o.icebergs.flush(o.pair, o.ticks) {
+		c.Send(o.publishPID, msg)
+	}
+}
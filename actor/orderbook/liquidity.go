@@ -0,0 +1,184 @@
+package orderbook
+
+import (
+	"marketmonkey/event"
+	"time"
+
+	"github.com/anthdm/hollywood/actor"
+)
+
+// Trades and reductions of their level are matched for this long in either
+// order, the venues do not guarantee that the print arrives before the book
+// update. A reduction is only counted as pulled once nothing matched it
+// within the window.
+const tradeMatchWindow = time.Second
+
+// levelKey addresses a book level, trades are keyed by the side they hit.
+type levelKey struct {
+	tick  int64
+	isBid bool
+}
+
+type tradedLevel struct {
+	qty  float64
+	unix int64
+}
+
+// reduction is the part of a level reduction no trade matched yet.
+type reduction struct {
+	size float64
+	unix int64
+}
+
+type liquidityKey struct {
+	kind  event.LiquidityKind
+	tick  int64
+	isBid bool
+}
+
+// liquidity classifies the size changes of the book levels. A reduction of a
+// level is consumed as far as it is covered by the trades on that side and
+// price within the match window, the rest of it was pulled. Levels are keyed
+// by their tick index.
+type liquidity struct {
+	traded  map[levelKey]*tradedLevel
+	pending map[levelKey][]reduction
+	changes map[liquidityKey]float64
+	// called with every reduction once it is classified
+	resolveFn func(tick int64, isBid bool, consumed, pulled float64)
+}
+
+func newLiquidity(resolveFn func(tick int64, isBid bool, consumed, pulled float64)) *liquidity {
+	return &liquidity{
+		traded:    make(map[levelKey]*tradedLevel),
+		pending:   make(map[levelKey][]reduction),
+		changes:   make(map[liquidityKey]float64),
+		resolveFn: resolveFn,
+	}
+}
+
+// addTrade records a trade that hit the level at tick on the given side at
+// now in milliseconds. It consumes the pending reductions of the level first,
+// the rest is kept for the reductions that are still to come.
+func (l *liquidity) addTrade(tick int64, qty float64, isBid bool, now int64) {
+	key := levelKey{tick, isBid}
+	pending := l.pending[key]
+	for len(pending) > 0 && qty > 0 {
+		consumed := min(qty, pending[0].size)
+		qty -= consumed
+		pending[0].size -= consumed
+		l.consume(key, consumed)
+		if pending[0].size <= 0 {
+			pending = pending[1:]
+		}
+	}
+	if len(pending) == 0 {
+		delete(l.pending, key)
+	} else {
+		l.pending[key] = pending
+	}
+	if qty <= 0 {
+		return
+	}
+
+	level, ok := l.traded[key]
+	if !ok {
+		level = &tradedLevel{}
+		l.traded[key] = level
+	}
+	level.qty += qty
+	level.unix = now
+}
+
+// classify records the change of a level from prev to size at now in
+// milliseconds. A reduction is consumed as far as recent trades cover it, the
+// rest is held back until a late trade matches it or the match window passed.
+func (l *liquidity) classify(tick int64, prev, size float64, isBid bool, now int64) {
+	delta := size - prev
+	if delta > 0 {
+		l.changes[liquidityKey{event.LiquidityAdded, tick, isBid}] += delta
+		return
+	}
+	if delta == 0 {
+		return
+	}
+	key := levelKey{tick, isBid}
+	removed := -delta
+	if level, ok := l.traded[key]; ok {
+		consumed := min(removed, level.qty)
+		level.qty -= consumed
+		removed -= consumed
+		l.consume(key, consumed)
+	}
+	if removed > 0 {
+		l.pending[key] = append(l.pending[key], reduction{
+			size: removed,
+			unix: now,
+		})
+	}
+}
+
+// isPending reports whether the level has reductions that are not yet
+// classified.
+func (l *liquidity) isPending(tick int64, isBid bool) bool {
+	return len(l.pending[levelKey{tick, isBid}]) > 0
+}
+
+func (l *liquidity) consume(key levelKey, size float64) {
+	if size <= 0 {
+		return
+	}
+	l.changes[liquidityKey{event.LiquidityConsumed, key.tick, key.isBid}] += size
+	l.resolveFn(key.tick, key.isBid, size, 0)
+}
+
+// flush returns the changes since the last flush. The reductions no trade
+// matched within the window are pulled and the trades that are too old to
+// be matched expire.
+func (l *liquidity) flush(ticks ticks, now int64) []event.LiquidityChange {
+	expiry := now - tradeMatchWindow.Milliseconds()
+	for key, level := range l.traded {
+		if level.unix < expiry || level.qty <= 0 {
+			delete(l.traded, key)
+		}
+	}
+	for key, pending := range l.pending {
+		i := 0
+		for i < len(pending) && pending[i].unix < expiry {
+			l.changes[liquidityKey{event.LiquidityPulled, key.tick, key.isBid}] += pending[i].size
+			l.resolveFn(key.tick, key.isBid, 0, pending[i].size)
+			i++
+		}
+		if i == len(pending) {
+			delete(l.pending, key)
+		} else {
+			l.pending[key] = pending[i:]
+		}
+	}
+	if len(l.changes) == 0 {
+		return nil
+	}
+	changes := make([]event.LiquidityChange, 0, len(l.changes))
+	for key, size := range l.changes {
+		changes = append(changes, event.LiquidityChange{
+			Kind:  key.kind,
+			Price: ticks.toPrice(key.tick),
+			Size:  size,
+			IsBid: key.isBid,
+		})
+		delete(l.changes, key)
+	}
+	return changes
+}
+
+func (o *Orderbook) publishLiquidity(c *actor.Context) {
+	changes := o.liquidity.flush(o.ticks, time.Now().UnixMilli())
+	if len(changes) == 0 {
+		return
+	}
+	c.Send(o.publishPID, event.Liquidity{
+		Pair:    o.pair,
+		Unix:    o.lastUnix,
+		Changes: changes,
+	})
+}
@@ -0,0 +1,132 @@
+package orderbook
+
+import (
+	"testing"
+
+	"marketmonkey/event"
+)
+
+// liquidityStep is a trade, a level change or a flush at unix in
+// milliseconds. Trades and level changes are at tick 100.
+type liquidityStep struct {
+	unix  int64
+	trade float64
+	// the level changed from prev to size
+	prev, size float64
+	isBid      bool
+	flush      bool
+}
+
+func TestLiquidityClassify(t *testing.T) {
+	tests := []struct {
+		name     string
+		steps    []liquidityStep
+		added    float64
+		consumed float64
+		pulled   float64
+	}{
+		{
+			name:  "added",
+			steps: []liquidityStep{{unix: 0, prev: 2, size: 5, isBid: true}},
+			added: 3,
+		},
+		{
+			name: "trade before the reduction",
+			steps: []liquidityStep{
+				{unix: 0, trade: 3, isBid: true},
+				{unix: 100, prev: 5, size: 2, isBid: true},
+			},
+			consumed: 3,
+		},
+		{
+			name: "trade after the reduction",
+			steps: []liquidityStep{
+				{unix: 0, prev: 5, size: 2, isBid: true},
+				{unix: 900, trade: 3, isBid: true},
+			},
+			consumed: 3,
+		},
+		{
+			name: "reduction without a trade",
+			steps: []liquidityStep{
+				{unix: 0, prev: 5, size: 2},
+				{unix: 500, flush: true},
+				{unix: 1001, flush: true},
+			},
+			pulled: 3,
+		},
+		{
+			name: "trade after the window",
+			steps: []liquidityStep{
+				{unix: 0, prev: 5, size: 2},
+				{unix: 1001, flush: true},
+				{unix: 1002, trade: 3},
+			},
+			pulled: 3,
+		},
+		{
+			name: "trade on the other side",
+			steps: []liquidityStep{
+				{unix: 0, trade: 3, isBid: true},
+				{unix: 100, prev: 5, size: 2},
+				{unix: 1200, flush: true},
+			},
+			pulled: 3,
+		},
+		{
+			name: "partially traded",
+			steps: []liquidityStep{
+				{unix: 0, trade: 1, isBid: true},
+				{unix: 100, prev: 5, size: 0, isBid: true},
+				{unix: 200, trade: 2, isBid: true},
+				{unix: 1200, flush: true},
+			},
+			consumed: 3,
+			pulled:   2,
+		},
+		{
+			name: "expired trade",
+			steps: []liquidityStep{
+				{unix: 0, trade: 3},
+				{unix: 1001, flush: true},
+				{unix: 1100, prev: 5, size: 2},
+				{unix: 2200, flush: true},
+			},
+			pulled: 3,
+		},
+	}
+	for _, tt := range tests {
+		var resolved [2]float64
+		l := newLiquidity(func(_ int64, _ bool, consumed, pulled float64) {
+			resolved[0] += consumed
+			resolved[1] += pulled
+		})
+		sums := map[event.LiquidityKind]float64{}
+		flush := func(unix int64) {
+			for _, change := range l.flush(newTicks(1), unix) {
+				sums[change.Kind] += change.Size
+			}
+		}
+		var last int64
+		for _, step := range tt.steps {
+			switch {
+			case step.flush:
+				flush(step.unix)
+			case step.trade > 0:
+				l.addTrade(100, step.trade, step.isBid, step.unix)
+			default:
+				l.classify(100, step.prev, step.size, step.isBid, step.unix)
+			}
+			last = step.unix
+		}
+		flush(last)
+		if sums[event.LiquidityAdded] != tt.added || sums[event.LiquidityConsumed] != tt.consumed || sums[event.LiquidityPulled] != tt.pulled {
+			t.Errorf("%s: added %v, consumed %v, pulled %v, want %v, %v, %v", tt.name,
+				sums[event.LiquidityAdded], sums[event.LiquidityConsumed], sums[event.LiquidityPulled],
+				tt.added, tt.consumed, tt.pulled)
+		}
+		if resolved != [2]float64{tt.consumed, tt.pulled} {
+			t.Errorf("%s: resolved consumed %v and pulled %v, want %v and %v", tt.name, resolved[0], resolved[1], tt.consumed, tt.pulled)
+		}
+	}
+}
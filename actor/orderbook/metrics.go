@@ -0,0 +1,81 @@
+package orderbook
+
+import (
+	"marketmonkey/event"
+	"marketmonkey/settings"
+	"time"
+
+	"github.com/anthdm/hollywood/actor"
+)
+
+func (o *Orderbook) publishMetrics(c *actor.Context) {
+	if o.asks.Len() == 0 || o.bids.Len() == 0 {
+		return
+	}
+	c.Send(o.publishPID, o.calculateMetrics(settings.BookMetrics))
+}
+
+func (o *Orderbook) calculateMetrics(config settings.BookMetricsConfig) event.BookMetrics {
+	bestBidTick, bestBidSize, _ := o.bids.Max()
+	bestAskTick, bestAskSize, _ := o.asks.Min()
+	bestBid := o.ticks.toPrice(bestBidTick)
+	bestAsk := o.ticks.toPrice(bestAskTick)
+
+	msg := event.BookMetrics{
+		Pair:        o.pair,
+		Unix:        time.Now().UnixMilli(),
+		Mid:         (bestBid + bestAsk) / 2,
+		SpreadTicks: float64(bestAskTick - bestBidTick),
+	}
+	if total := bestBidSize + bestAskSize; total > 0 {
+		msg.Microprice = (bestBid*bestAskSize + bestAsk*bestBidSize) / total
+	}
+
+	var bidSize, bidNotional float64
+	i := 0
+	o.bids.Reverse(func(tick int64, size float64) bool {
+		if i == config.Levels {
+			return false
+		}
+		bidSize += size
+		bidNotional += o.ticks.toPrice(tick) * size
+		i++
+		return true
+	})
+	var askSize, askNotional float64
+	i = 0
+	o.asks.Scan(func(tick int64, size float64) bool {
+		if i == config.Levels {
+			return false
+		}
+		askSize += size
+		askNotional += o.ticks.toPrice(tick) * size
+		i++
+		return true
+	})
+	if total := bidSize + askSize; total > 0 {
+		msg.Imbalance = (bidSize - askSize) / total
+		// Like the microprice, each side is weighted by the size of the
+		// opposite one, the mid leans towards the thinner side.
+		msg.WeightedMid = (bidNotional/bidSize*askSize + askNotional/askSize*bidSize) / total
+	}
+
+	band := msg.Mid * config.DepthBps / 10000
+	lower := o.ticks.toTick(msg.Mid - band)
+	upper := o.ticks.toTick(msg.Mid + band)
+	o.bids.Reverse(func(tick int64, size float64) bool {
+		if tick < lower {
+			return false
+		}
+		msg.BidDepth += size
+		return true
+	})
+	o.asks.Scan(func(tick int64, size float64) bool {
+		if tick > upper {
+			return false
+		}
+		msg.AskDepth += size
+		return true
+	})
+	return msg
+}
@@ -1,88 +1,243 @@
 package orderbook
 
 import (
+	act "marketmonkey/actor"
 	"marketmonkey/event"
 	"marketmonkey/settings"
+	"math"
 	"time"
 
 	"github.com/anthdm/hollywood/actor"
 	"github.com/tidwall/btree"
 )
 
+// The window is re-centered once the price moved this fraction of its half
+// width away from the center.
+const recenterThreshold = 0.25
+
 type Orderbook struct {
-	pair       event.Pair
-	asks       *btree.Map[float64, float64]
-	bids       *btree.Map[float64, float64]
-	lastPrice  float64
-	upperPrice float64
-	lowerPrice float64
-	priceGroup float64
+	pair event.Pair
+	// levels by tick index
+	asks      *btree.Map[int64, float64]
+	bids      *btree.Map[int64, float64]
+	lastPrice float64
+	// bounds of the depth window in ticks
+	upperTick int64
+	lowerTick int64
+	// default grouping of the heatmap in ticks, it is always computed so it
+	// has a history to backfill
+	heatmapGroup int64
+	tickSize     float64
+	ticks        ticks
+	window       settings.DepthWindow
+
+	// center of the current depth window
+	center float64
+
+	// subscribed groupings in ticks per stream with their subscriber count
+	groupings map[event.Stream]map[int64]int
+	// the last heatmaps per grouping for the backfill of new subscribers
+	heatmaps map[int64]*act.History[event.Heatmap]
 
 	publishPID *actor.PID
 	lastUnix   int64
+	// number of changes to the book, the applied updates and the evictions
+	// of the levels that fell out of the depth window
+	seq uint64
+
+	liquidity *liquidity
+	icebergs  *icebergs
+	walls     *walls
 }
 
 func New(pair event.Pair) actor.Producer {
 	return func() actor.Receiver {
 		symbol := settings.Markets[pair.Exchange].Symbols[pair.Symbol]
-		return &Orderbook{
-			pair:       pair,
-			asks:       btree.NewMap[float64, float64](0),
-			bids:       btree.NewMap[float64, float64](0),
-			priceGroup: symbol.TickSize * 50, // TODO: not quite sure about that.
+		o := &Orderbook{
+			pair:         pair,
+			heatmapGroup: settings.DefaultPriceGrouping,
+			tickSize:     settings.TickSize(pair),
+			ticks:        newTicks(settings.TickSize(pair)),
+			window:       symbol.DepthWindow,
+			groupings:    make(map[event.Stream]map[int64]int),
+			heatmaps:     make(map[int64]*act.History[event.Heatmap]),
 		}
+		o.reset()
+		return o
 	}
 }
 
+// reset drops all levels and what was tracked about them. The depth window
+// is centered again by the next update.
+func (o *Orderbook) reset() {
+	o.asks = btree.NewMap[int64, float64](0)
+	o.bids = btree.NewMap[int64, float64](0)
+	o.center = 0
+	o.walls = newWalls(settings.Walls)
+	o.liquidity = newLiquidity(o.walls.updateLevel)
+	o.icebergs = newIcebergs(settings.Icebergs)
+}
+
 func (o *Orderbook) Receive(c *actor.Context) {
 	switch msg := c.Message().(type) {
 	case actor.Started:
 		c.SendRepeat(c.PID(), event.Tick{}, time.Millisecond*200)
 		c.SendRepeat(c.PID(), event.TickHeatmap{}, time.Millisecond*200)
+		c.SendRepeat(c.PID(), event.TickMetrics{}, settings.BookMetrics.Interval)
 		o.publishPID = c.Parent().Child("publish/" + o.pair.Symbol)
 	case event.Trade:
-		if o.lastPrice == 0 {
-			o.lastPrice = msg.Price
-			o.calculateDepth()
-		}
 		o.lastPrice = msg.Price
+		o.updateDepth(msg.Price)
+		tick := o.ticks.toTick(msg.Price)
+		o.liquidity.addTrade(tick, msg.Qty, !msg.IsBuy, time.Now().UnixMilli())
+		o.icebergs.addTrade(tick, msg.Qty, !msg.IsBuy, o.levelSize(tick, !msg.IsBuy))
 	case event.BookUpdate:
-		if o.lastPrice > 0 {
+		if msg.Reset {
+			o.reset()
+			o.seq++
+		}
+		if o.center == 0 {
+			// Snapshots usually arrive before the first trade, center the
+			// window on the touch so they are not thrown away.
+			o.updateDepth(touch(msg))
+		}
+		if o.center > 0 {
 			o.processUpdate(msg)
+			o.seq++
 		}
 		o.lastUnix = msg.Unix
+	case event.BookSnapshotRequest:
+		c.Respond(o.snapshot(msg))
+	case event.GroupingSub:
+		if o.groupings[msg.Stream] == nil {
+			o.groupings[msg.Stream] = make(map[int64]int)
+		}
+		o.groupings[msg.Stream][msg.Grouping]++
+	case event.GroupingUnsub:
+		if o.groupings[msg.Stream][msg.Grouping] > 0 {
+			o.groupings[msg.Stream][msg.Grouping]--
+		}
+		if o.groupings[msg.Stream][msg.Grouping] == 0 {
+			delete(o.groupings[msg.Stream], msg.Grouping)
+			if msg.Stream == event.StreamHeatmap && msg.Grouping != o.heatmapGroup {
+				delete(o.heatmaps, msg.Grouping)
+			}
+		}
+	case event.Backfill:
+		act.Backfill(c, o.publishPID, msg, o.heatmaps[msg.Timeframe].Last(msg.Count))
 	case event.Tick:
 		o.publish(c)
+		o.publishLiquidity(c)
+		o.publishIcebergs(c)
+		o.publishWalls(c)
 	case event.TickHeatmap:
 		o.publishHeatmap(c)
+		o.publishDepth(c)
+	case event.TickMetrics:
+		o.publishMetrics(c)
 	}
 }
 
-// TODO: this depends on the symbol I guess...
-func (o *Orderbook) calculateDepth() {
-	o.upperPrice = o.lastPrice + 2000
-	o.lowerPrice = o.lastPrice - 2000
+// updateDepth re-centers the depth window on the given price once it moved
+// far enough from the current center and evicts the levels that fell out of
+// it. Levels that come into the window are filled by the following updates.
+func (o *Orderbook) updateDepth(price float64) {
+	if price <= 0 {
+		return
+	}
+	halfWidth := o.window.HalfWidth(price, o.tickSize)
+	if o.center > 0 && math.Abs(price-o.center) < halfWidth*recenterThreshold {
+		return
+	}
+	o.center = price
+	o.upperTick = o.ticks.toTick(price + halfWidth)
+	o.lowerTick = o.ticks.toTick(price - halfWidth)
+	if evict(o.asks, o.lowerTick, o.upperTick)+evict(o.bids, o.lowerTick, o.upperTick) > 0 {
+		o.seq++
+	}
 }
 
-func (o *Orderbook) processUpdate(msg event.BookUpdate) {
-	for _, ask := range msg.Asks {
-		if ask.Size == 0 {
-			o.asks.Delete(ask.Price)
-			continue
+// evict deletes all levels outside of [lower, upper] and returns how many.
+func evict(levels *btree.Map[int64, float64], lower, upper int64) int {
+	var evicted []int64
+	levels.Descend(lower, func(tick int64, _ float64) bool {
+		if tick < lower {
+			evicted = append(evicted, tick)
 		}
-		if ask.Price <= o.upperPrice && ask.Price >= o.lowerPrice {
-			o.asks.Set(ask.Price, ask.Size)
+		return true
+	})
+	levels.Ascend(upper, func(tick int64, _ float64) bool {
+		if tick > upper {
+			evicted = append(evicted, tick)
 		}
+		return true
+	})
+	for _, tick := range evicted {
+		levels.Delete(tick)
 	}
-	for _, bid := range msg.Bids {
-		if bid.Size == 0 {
-			o.bids.Delete(bid.Price)
-			continue
+	return len(evicted)
+}
+
+// touch returns the mid of the best bid and ask of the update, or the best
+// price of the one side that is present.
+func touch(msg event.BookUpdate) float64 {
+	var ask, bid float64
+	for _, entry := range msg.Asks {
+		if entry.Size > 0 && (ask == 0 || entry.Price < ask) {
+			ask = entry.Price
 		}
-		if bid.Price <= o.upperPrice && bid.Price >= o.lowerPrice {
-			o.bids.Set(bid.Price, bid.Size)
+	}
+	for _, entry := range msg.Bids {
+		if entry.Size > 0 && entry.Price > bid {
+			bid = entry.Price
 		}
 	}
+	switch {
+	case ask > 0 && bid > 0:
+		return (ask + bid) / 2
+	case ask > 0:
+		return ask
+	}
+	return bid
+}
+
+func (o *Orderbook) processUpdate(msg event.BookUpdate) {
+	// The first update is the snapshot, there is nothing to diff against.
+	classify := o.seq > 0
+	now := time.Now().UnixMilli()
+	for _, ask := range msg.Asks {
+		o.setLevel(o.asks, ask, false, classify, now)
+	}
+	for _, bid := range msg.Bids {
+		o.setLevel(o.bids, bid, true, classify, now)
+	}
+}
+
+func (o *Orderbook) setLevel(levels *btree.Map[int64, float64], entry event.BookEntry, isBid, classify bool, now int64) {
+	tick := o.ticks.toTick(entry.Price)
+	// Set and Delete return the previous size, one walk of the tree each.
+	var prev float64
+	if entry.Size == 0 {
+		prev, _ = levels.Delete(tick)
+	} else if tick <= o.upperTick && tick >= o.lowerTick {
+		prev, _ = levels.Set(tick, entry.Size)
+	} else {
+		return
+	}
+	if classify {
+		o.liquidity.classify(tick, prev, entry.Size, isBid, now)
+	}
+	o.icebergs.updateLevel(tick, prev, entry.Size, isBid)
+}
+
+// levelSize returns the displayed size at the given tick.
+func (o *Orderbook) levelSize(tick int64, isBid bool) float64 {
+	if isBid {
+		size, _ := o.bids.Get(tick)
+		return size
+	}
+	size, _ := o.asks.Get(tick)
+	return size
 }
 
 func (o *Orderbook) publishHeatmap(c *actor.Context) {
@@ -90,18 +245,87 @@ func (o *Orderbook) publishHeatmap(c *actor.Context) {
 		return
 	}
 
-	msg := o.calculateHeatmap()
+	heatmap := o.calculateHeatmap(o.heatmapGroup)
+	o.addHistory(heatmap)
+	c.Send(o.publishPID, heatmap)
+	for grouping := range o.groupings[event.StreamHeatmap] {
+		if grouping == o.heatmapGroup {
+			continue
+		}
+		heatmap := o.calculateHeatmap(grouping)
+		o.addHistory(heatmap)
+		c.Send(o.publishPID, heatmap)
+	}
+}
 
-	c.Send(o.publishPID, msg)
+// addHistory keeps the last heatmap of every second per grouping.
+func (o *Orderbook) addHistory(heatmap event.Heatmap) {
+	history, ok := o.heatmaps[heatmap.Grouping]
+	if !ok {
+		history = act.NewHistory[event.Heatmap](settings.History.Heatmaps)
+		o.heatmaps[heatmap.Grouping] = history
+	}
+	history.Add(heatmap.Unix, heatmap)
 }
 
 func (o *Orderbook) publish(c *actor.Context) {
 	if o.asks.Len() == 0 || o.bids.Len() == 0 {
 		return
 	}
+
+	c.Send(o.publishPID, o.calculateOrderbook(0))
+	for grouping := range o.groupings[event.StreamOrderbook] {
+		c.Send(o.publishPID, o.calculateOrderbook(grouping))
+	}
+}
+
+// publishDepth publishes the levels within settings.DepthRange of the mid
+// while the depth stream has subscribers.
+func (o *Orderbook) publishDepth(c *actor.Context) {
+	if len(o.groupings[event.StreamDepth]) == 0 {
+		return
+	}
+	if o.asks.Len() == 0 || o.bids.Len() == 0 {
+		return
+	}
+	bestBid, _, _ := o.bids.Max()
+	bestAsk, _, _ := o.asks.Min()
+	mid := (o.ticks.toPrice(bestBid) + o.ticks.toPrice(bestAsk)) / 2
+	band := mid * settings.DepthRange / 100
+	lower := o.ticks.toTick(mid - band)
+	upper := o.ticks.toTick(mid + band)
+
+	msg := event.Depth{
+		Pair: o.pair,
+		Unix: o.lastUnix,
+		Mid:  mid,
+		Asks: make([]event.BookEntry, 0),
+		Bids: make([]event.BookEntry, 0),
+	}
+	o.bids.Reverse(func(tick int64, size float64) bool {
+		if tick < lower {
+			return false
+		}
+		msg.Bids = append(msg.Bids, event.BookEntry{Price: o.ticks.toPrice(tick), Size: size})
+		return true
+	})
+	o.asks.Scan(func(tick int64, size float64) bool {
+		if tick > upper {
+			return false
+		}
+		msg.Asks = append(msg.Asks, event.BookEntry{Price: o.ticks.toPrice(tick), Size: size})
+		return true
+	})
+	c.Send(o.publishPID, msg)
+}
+
+// calculateOrderbook returns the top levels of the book, summed into buckets
+// of the given grouping in ticks. A grouping of zero returns the raw levels.
+func (o *Orderbook) calculateOrderbook(grouping int64) event.Orderbook {
 	msg := event.Orderbook{
 		Pair:      o.pair,
 		LastPrice: o.lastPrice,
+		Grouping:  grouping,
 		AskPrices: make([]float64, 0),
 		AskSizes:  make([]float64, 0),
 		AskSums:   make([]float64, 0),
@@ -110,31 +334,82 @@ func (o *Orderbook) publish(c *actor.Context) {
 		BidSums:   make([]float64, 0),
 	}
 	depth := 7
-	i := 0
 	sum := 0.0
-	o.bids.Descend(1000000, func(price float64, size float64) bool {
-		if i == depth {
-			return false
-		}
-		sum += size
-		msg.BidPrices = append(msg.BidPrices, price)
-		msg.BidSizes = append(msg.BidSizes, size)
+	for _, level := range o.groupBids(grouping, depth) {
+		sum += level.Size
+		msg.BidPrices = append(msg.BidPrices, level.Price)
+		msg.BidSizes = append(msg.BidSizes, level.Size)
 		msg.BidSums = append(msg.BidSums, sum)
-		i++
-		return true
-	})
+	}
 	sum = 0
-	i = 0
-	o.asks.Ascend(0, func(price float64, size float64) bool {
-		if i == depth {
-			return false
-		}
-		sum += size
-		msg.AskPrices = append(msg.AskPrices, price)
-		msg.AskSizes = append(msg.AskSizes, size)
+	for _, level := range o.groupAsks(grouping, depth) {
+		sum += level.Size
+		msg.AskPrices = append(msg.AskPrices, level.Price)
+		msg.AskSizes = append(msg.AskSizes, level.Size)
 		msg.AskSums = append(msg.AskSums, sum)
-		i++
-		return true
+	}
+	return msg
+}
+
+// Snapshot requests the current book of the given pair from its orderbook
+// actor.
+func Snapshot(e *actor.Engine, pair event.Pair, req event.BookSnapshotRequest) (event.BookSnapshot, error) {
+	return act.Request[event.BookSnapshot](e, act.GetBookPID(pair), req)
+}
+
+func (o *Orderbook) snapshot(req event.BookSnapshotRequest) event.BookSnapshot {
+	return event.BookSnapshot{
+		Pair:      o.pair,
+		Unix:      o.lastUnix,
+		Seq:       o.seq,
+		Grouping:  req.Grouping,
+		LastPrice: o.lastPrice,
+		Asks:      o.groupAsks(req.Grouping, req.Depth),
+		Bids:      o.groupBids(req.Grouping, req.Depth),
+	}
+}
+
+// groupBids returns the bids from the best price down, summed into buckets
+// of the given grouping in ticks. Bids are floored into their bucket, so a
+// bucket never crosses the spread. A depth of zero returns all levels.
+func (o *Orderbook) groupBids(grouping int64, depth int) []event.BookEntry {
+	levels := make([]event.BookEntry, 0)
+	last := int64(-1)
+	o.bids.Reverse(func(tick int64, size float64) bool {
+		if grouping > 0 {
+			tick = floorGroup(tick, grouping)
+		}
+		return o.addLevel(&levels, &last, tick, size, depth)
 	})
-	c.Send(o.publishPID, msg)
+	return levels
+}
+
+// groupAsks returns the asks from the best price up, summed into buckets of
+// the given grouping in ticks. Asks are ceiled into their bucket.
+func (o *Orderbook) groupAsks(grouping int64, depth int) []event.BookEntry {
+	levels := make([]event.BookEntry, 0)
+	last := int64(-1)
+	o.asks.Scan(func(tick int64, size float64) bool {
+		if grouping > 0 {
+			tick = ceilGroup(tick, grouping)
+		}
+		return o.addLevel(&levels, &last, tick, size, depth)
+	})
+	return levels
+}
+
+// addLevel adds the size to the last level when it is on the same tick, else
+// it appends a new level. It returns false once the depth is exceeded.
+func (o *Orderbook) addLevel(levels *[]event.BookEntry, last *int64, tick int64, size float64, depth int) bool {
+	n := len(*levels)
+	if n > 0 && *last == tick {
+		(*levels)[n-1].Size += size
+		return true
+	}
+	if depth > 0 && n == depth {
+		return false
+	}
+	*levels = append(*levels, event.BookEntry{Price: o.ticks.toPrice(tick), Size: size})
+	*last = tick
+	return true
 }
@@ -0,0 +1,135 @@
+package orderbook
+
+import (
+	"marketmonkey/event"
+	"marketmonkey/settings"
+	"math/rand"
+	"testing"
+
+	"github.com/tidwall/btree"
+)
+
+const (
+	benchTickSize = 0.1
+	benchMid      = 64000.0
+	benchLevels   = 2000
+)
+
+// benchUpdates returns diff updates around the mid like the venues send them,
+// with prices computed in float so they carry the usual rounding noise.
+func benchUpdates(n int) []event.BookUpdate {
+	r := rand.New(rand.NewSource(1))
+	updates := make([]event.BookUpdate, n)
+	for i := range updates {
+		var msg event.BookUpdate
+		for j := 0; j < 20; j++ {
+			offset := float64(r.Intn(benchLevels)+1) * benchTickSize
+			size := r.Float64() * 10
+			if r.Intn(4) == 0 {
+				size = 0
+			}
+			msg.Asks = append(msg.Asks, event.BookEntry{Price: benchMid + offset, Size: size})
+			msg.Bids = append(msg.Bids, event.BookEntry{Price: benchMid - offset, Size: size})
+		}
+		updates[i] = msg
+	}
+	return updates
+}
+
+// floatBook is the book as it was keyed by float prices, kept as the
+// baseline of the benchmarks.
+type floatBook struct {
+	asks  *btree.Map[float64, float64]
+	bids  *btree.Map[float64, float64]
+	upper float64
+	lower float64
+}
+
+// processUpdate is the update of the float keyed book as it was.
+func (b *floatBook) processUpdate(msg event.BookUpdate) {
+	for _, ask := range msg.Asks {
+		if ask.Size == 0 {
+			b.asks.Delete(ask.Price)
+			continue
+		}
+		if ask.Price <= b.upper && ask.Price >= b.lower {
+			b.asks.Set(ask.Price, ask.Size)
+		}
+	}
+	for _, bid := range msg.Bids {
+		if bid.Size == 0 {
+			b.bids.Delete(bid.Price)
+			continue
+		}
+		if bid.Price <= b.upper && bid.Price >= b.lower {
+			b.bids.Set(bid.Price, bid.Size)
+		}
+	}
+}
+
+func newBenchOrderbook() *Orderbook {
+	walls := newWalls(settings.Walls)
+	o := &Orderbook{
+		asks:      btree.NewMap[int64, float64](0),
+		bids:      btree.NewMap[int64, float64](0),
+		tickSize:  benchTickSize,
+		ticks:     newTicks(benchTickSize),
+		window:    settings.DepthWindow{Percent: 5},
+		liquidity: newLiquidity(walls.updateLevel),
+		icebergs:  newIcebergs(settings.Icebergs),
+		walls:     walls,
+	}
+	o.updateDepth(benchMid)
+	return o
+}
+
+func BenchmarkBookUpdateFloat(b *testing.B) {
+	updates := benchUpdates(1024)
+	halfWidth := settings.DepthWindow{Percent: 5}.HalfWidth(benchMid, benchTickSize)
+	book := &floatBook{
+		asks:  btree.NewMap[float64, float64](0),
+		bids:  btree.NewMap[float64, float64](0),
+		upper: benchMid + halfWidth,
+		lower: benchMid - halfWidth,
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		book.processUpdate(updates[i%len(updates)])
+	}
+}
+
+func BenchmarkBookUpdateTicks(b *testing.B) {
+	updates := benchUpdates(1024)
+	o := newBenchOrderbook()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		o.processUpdate(updates[i%len(updates)])
+	}
+}
+
+// BenchmarkBookUpdateTicksClassified includes the liquidity and wall
+// classification of the diffs, which the float baseline never had.
+func BenchmarkBookUpdateTicksClassified(b *testing.B) {
+	updates := benchUpdates(1024)
+	o := newBenchOrderbook()
+	o.seq = 1
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		o.processUpdate(updates[i%len(updates)])
+	}
+}
+
+func BenchmarkCalculateHeatmap(b *testing.B) {
+	o := newBenchOrderbook()
+	for _, msg := range benchUpdates(1024) {
+		o.processUpdate(msg)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		o.calculateHeatmap(50)
+	}
+}
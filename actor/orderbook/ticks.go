@@ -0,0 +1,54 @@
+package orderbook
+
+import (
+	"math"
+
+	"marketmonkey/settings"
+)
+
+// ticks converts between prices and tick indices. The book stores every
+// level by its tick index, so prices that differ by float noise end up on
+// the same level and groupings are exact integer divisions. Prices are only
+// converted when they enter and leave the book.
+type ticks struct {
+	size float64
+	// perUnit is the amount of ticks per unit of price when the tick size
+	// divides one, dividing by it rounds to the nearest decimal price.
+	perUnit float64
+}
+
+func newTicks(size float64) ticks {
+	if size <= 0 {
+		size = settings.FallbackTickSize
+	}
+	t := ticks{size: size}
+	if perUnit := math.Round(1 / size); math.Abs(perUnit*size-1) < 1e-9 {
+		t.perUnit = perUnit
+	}
+	return t
+}
+
+func (t ticks) toTick(price float64) int64 {
+	if t.perUnit > 0 {
+		return int64(math.Round(price * t.perUnit))
+	}
+	return int64(math.Round(price / t.size))
+}
+
+func (t ticks) toPrice(tick int64) float64 {
+	if t.perUnit > 0 {
+		return float64(tick) / t.perUnit
+	}
+	return float64(tick) * t.size
+}
+
+// floorGroup returns the first tick of the group of the given tick.
+func floorGroup(tick, group int64) int64 {
+	return tick / group * group
+}
+
+// ceilGroup rounds the tick up to the next multiple of the group, a tick on
+// a multiple is returned as is.
+func ceilGroup(tick, group int64) int64 {
+	return (tick + group - 1) / group * group
+}
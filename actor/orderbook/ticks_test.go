@@ -0,0 +1,129 @@
+package orderbook
+
+import (
+	"strconv"
+	"testing"
+)
+
+// decimal returns n shifted by the given decimal places as parsed from its
+// decimal notation, which is the price a venue sends for it.
+func decimal(n int64, places int) float64 {
+	s := strconv.FormatInt(n, 10)
+	for len(s) <= places {
+		s = "0" + s
+	}
+	price, err := strconv.ParseFloat(s[:len(s)-places]+"."+s[len(s)-places:], 64)
+	if err != nil {
+		panic(err)
+	}
+	return price
+}
+
+func TestToTick(t *testing.T) {
+	tests := []struct {
+		size  float64
+		price float64
+		tick  int64
+	}{
+		{0.1, 64000.3, 640003},
+		{0.1, 0.1 + 0.2, 3},
+		{0.01, 1.1 * 1.1, 121},
+		{0.001, 1.005, 1005},
+		{0.25, 100.75, 403},
+		{0.3, 0.9, 3},
+		{0, 0.03, 3},
+	}
+	for _, tt := range tests {
+		if tick := newTicks(tt.size).toTick(tt.price); tick != tt.tick {
+			t.Errorf("toTick(%v) with tick size %v = %d, want %d", tt.price, tt.size, tick, tt.tick)
+		}
+	}
+}
+
+func TestToPrice(t *testing.T) {
+	tests := []struct {
+		size  float64
+		tick  int64
+		price float64
+	}{
+		{0.1, 640003, 64000.3},
+		{0.1, 3, 0.3},
+		{0.001, 1005, 1.005},
+		{0.25, 403, 100.75},
+		{0.5, 129, 64.5},
+	}
+	for _, tt := range tests {
+		if price := newTicks(tt.size).toPrice(tt.tick); price != tt.price {
+			t.Errorf("toPrice(%d) with tick size %v = %v, want %v", tt.tick, tt.size, price, tt.price)
+		}
+	}
+}
+
+// TestTickRoundTrip checks that the decimal tick sizes convert to the exact
+// decimal prices and back, without the noise of multiplying by the size.
+func TestTickRoundTrip(t *testing.T) {
+	sizes := []struct {
+		size   float64
+		places int
+	}{
+		{0.1, 1},
+		{0.01, 2},
+		{0.001, 3},
+		{0.0001, 4},
+	}
+	for _, s := range sizes {
+		ticks := newTicks(s.size)
+		for _, start := range []int64{0, 99_000, 6_400_000} {
+			for n := start; n < start+10_000; n++ {
+				want := decimal(n, s.places)
+				if price := ticks.toPrice(n); price != want {
+					t.Fatalf("toPrice(%d) with tick size %v = %v, want %v", n, s.size, price, want)
+				}
+				if tick := ticks.toTick(want); tick != n {
+					t.Fatalf("toTick(%v) with tick size %v = %d, want %d", want, s.size, tick, n)
+				}
+			}
+		}
+	}
+}
+
+func TestFloorGroup(t *testing.T) {
+	tests := []struct{ tick, group, want int64 }{
+		{123, 50, 100},
+		{100, 50, 100},
+		{149, 50, 100},
+		{7, 1, 7},
+		{0, 10, 0},
+	}
+	for _, tt := range tests {
+		if got := floorGroup(tt.tick, tt.group); got != tt.want {
+			t.Errorf("floorGroup(%d, %d) = %d, want %d", tt.tick, tt.group, got, tt.want)
+		}
+	}
+}
+
+func TestCeilGroup(t *testing.T) {
+	tests := []struct{ tick, group, want int64 }{
+		{123, 50, 150},
+		{100, 50, 100},
+		{101, 50, 150},
+		{7, 1, 7},
+		{0, 10, 0},
+	}
+	for _, tt := range tests {
+		if got := ceilGroup(tt.tick, tt.group); got != tt.want {
+			t.Errorf("ceilGroup(%d, %d) = %d, want %d", tt.tick, tt.group, got, tt.want)
+		}
+	}
+}
+
+// The asks are ceiled and the bids floored, so a bucket never crosses the
+// spread even when both touch the same group.
+func TestGroupSpread(t *testing.T) {
+	bid, ask := int64(640012), int64(640013)
+	for _, group := range []int64{1, 5, 10, 50, 100} {
+		if b, a := floorGroup(bid, group), ceilGroup(ask, group); b >= a {
+			t.Errorf("group %d: bid bucket %d not below ask bucket %d", group, b, a)
+		}
+	}
+}
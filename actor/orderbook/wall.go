@@ -0,0 +1,161 @@
+package orderbook
+
+import (
+	"marketmonkey/event"
+	"marketmonkey/settings"
+	"time"
+
+	"github.com/anthdm/hollywood/actor"
+	"github.com/tidwall/btree"
+)
+
+type wallKey struct {
+	tick  int64
+	isBid bool
+}
+
+type trackedWall struct {
+	wall     event.Wall
+	consumed float64
+	pulled   float64
+	changed  bool
+}
+
+// walls tracks the levels that are much larger than the average level of
+// their side, from the moment they appear until they are pulled or filled.
+type walls struct {
+	config settings.WallConfig
+	walls  map[wallKey]*trackedWall
+	nextID uint64
+}
+
+func newWalls(config settings.WallConfig) *walls {
+	return &walls{
+		config: config,
+		walls:  make(map[wallKey]*trackedWall),
+	}
+}
+
+// updateLevel records how much of a wall was consumed by trades or pulled.
+func (w *walls) updateLevel(tick int64, isBid bool, consumed, pulled float64) {
+	if wall, ok := w.walls[wallKey{tick, isBid}]; ok {
+		wall.consumed += consumed
+		wall.pulled += pulled
+	}
+}
+
+// update ends the walls that are gone or shrunk and starts new ones. A wall
+// only ends once pending reports no unclassified reductions of its level. It
+// returns the walls that changed since the last update.
+func (w *walls) update(bids, asks *btree.Map[int64, float64], ticks ticks, now int64, pending func(tick int64, isBid bool) bool) []event.Wall {
+	bidAvg := w.averageSize(bids, true)
+	askAvg := w.averageSize(asks, false)
+
+	var changed []event.Wall
+	for key, wall := range w.walls {
+		levels, avg := asks, askAvg
+		if key.isBid {
+			levels, avg = bids, bidAvg
+		}
+		size, _ := levels.Get(key.tick)
+		if size < avg*w.config.Ratio/2 {
+			if pending(key.tick, key.isBid) {
+				// Wait for the late prints to tell filled from pulled.
+				continue
+			}
+			wall.wall.End = now
+			wall.wall.Size = size
+			wall.wall.State = event.WallPulled
+			if wall.consumed > 0 && wall.consumed >= wall.pulled {
+				wall.wall.State = event.WallFilled
+			}
+			changed = append(changed, wall.wall)
+			delete(w.walls, key)
+			continue
+		}
+		if size != wall.wall.Size {
+			wall.wall.Size = size
+			wall.wall.PeakSize = max(wall.wall.PeakSize, size)
+			wall.changed = true
+		}
+		if wall.changed {
+			changed = append(changed, wall.wall)
+			wall.changed = false
+		}
+	}
+
+	changed = append(changed, w.detect(bids, true, bidAvg, ticks, now)...)
+	changed = append(changed, w.detect(asks, false, askAvg, ticks, now)...)
+	return changed
+}
+
+func (w *walls) detect(levels *btree.Map[int64, float64], isBid bool, avg float64, ticks ticks, now int64) []event.Wall {
+	var started []event.Wall
+	w.scan(levels, isBid, func(tick int64, size float64) {
+		key := wallKey{tick, isBid}
+		if _, ok := w.walls[key]; ok || size < avg*w.config.Ratio {
+			return
+		}
+		w.nextID++
+		wall := &trackedWall{
+			wall: event.Wall{
+				ID:       w.nextID,
+				Price:    ticks.toPrice(tick),
+				IsBid:    isBid,
+				Start:    now,
+				Size:     size,
+				PeakSize: size,
+				State:    event.WallActive,
+			},
+		}
+		w.walls[key] = wall
+		started = append(started, wall.wall)
+	})
+	return started
+}
+
+func (w *walls) averageSize(levels *btree.Map[int64, float64], isBid bool) float64 {
+	sum, n := 0.0, 0
+	w.scan(levels, isBid, func(_ int64, size float64) {
+		sum += size
+		n++
+	})
+	if n == 0 {
+		return 0
+	}
+	return sum / float64(n)
+}
+
+// scan walks the top levels of a side from the touch outwards.
+func (w *walls) scan(levels *btree.Map[int64, float64], isBid bool, fn func(tick int64, size float64)) {
+	i := 0
+	iter := func(tick int64, size float64) bool {
+		if i == w.config.Levels {
+			return false
+		}
+		fn(tick, size)
+		i++
+		return true
+	}
+	if isBid {
+		levels.Reverse(iter)
+	} else {
+		levels.Scan(iter)
+	}
+}
+
+func (o *Orderbook) publishWalls(c *actor.Context) {
+	if o.asks.Len() == 0 || o.bids.Len() == 0 {
+		return
+	}
+	now := time.Now().UnixMilli()
+	changed := o.walls.update(o.bids, o.asks, o.ticks, now, o.liquidity.isPending)
+	if len(changed) == 0 {
+		return
+	}
+	c.Send(o.publishPID, event.Walls{
+		Pair:  o.pair,
+		Unix:  now,
+		Walls: changed,
+	})
+}
@@ -0,0 +1,216 @@
+package profile
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	act "marketmonkey/actor"
+	"marketmonkey/event"
+	"marketmonkey/settings"
+
+	"github.com/anthdm/hollywood/actor"
+)
+
+type volume struct {
+	buy  float64
+	sell float64
+}
+
+// Profile keeps the volume traded per price of a pair in slices of
+// settings.VolumeProfile.Resolution, so profiles of any time range within
+// the retention can be summed up on request. A slice holds the volume per
+// tick.
+type Profile struct {
+	pair     event.Pair
+	tickSize float64
+	slices   *act.Slices[map[int64]*volume]
+}
+
+func New(pair event.Pair) actor.Producer {
+	return func() actor.Receiver {
+		return &Profile{
+			pair:     pair,
+			tickSize: settings.TickSize(pair),
+			slices: act.NewSlices(settings.VolumeProfile.Resolution, settings.VolumeProfile.Retention, func() map[int64]*volume {
+				return make(map[int64]*volume)
+			}),
+		}
+	}
+}
+
+func (p *Profile) Receive(c *actor.Context) {
+	switch msg := c.Message().(type) {
+	case event.Trade:
+		p.add(msg)
+	case event.VolumeProfileRequest:
+		c.Respond(p.profile(msg, time.Now().Unix()))
+	}
+}
+
+func (p *Profile) add(trade event.Trade) {
+	levels, ok := p.slices.At(trade.Unix / 1000)
+	if !ok {
+		return
+	}
+	tick := int64(math.Round(trade.Price / p.tickSize))
+	v, ok := levels[tick]
+	if !ok {
+		v = &volume{}
+		levels[tick] = v
+	}
+	if trade.IsBuy {
+		v.buy += trade.Qty
+	} else {
+		v.sell += trade.Qty
+	}
+}
+
+func (p *Profile) profile(req event.VolumeProfileRequest, now int64) event.VolumeProfile {
+	to := req.To
+	if to == 0 {
+		to = now + 1
+	}
+	grouping := max(req.Grouping, 1)
+	rows := make(map[int64]*volume)
+	for _, s := range p.slices.Since(req.From) {
+		if s.Unix >= to {
+			break
+		}
+		for tick, v := range s.Value {
+			row := floorDiv(tick, grouping)
+			sum, ok := rows[row]
+			if !ok {
+				sum = &volume{}
+				rows[row] = sum
+			}
+			sum.buy += v.buy
+			sum.sell += v.sell
+		}
+	}
+
+	msg := event.VolumeProfile{
+		Pair:     p.pair,
+		From:     req.From,
+		To:       req.To,
+		Grouping: grouping,
+		Levels:   make([]event.VolumeProfileLevel, 0, len(rows)),
+	}
+	for row, v := range rows {
+		msg.Levels = append(msg.Levels, event.VolumeProfileLevel{
+			Price: float64(row*grouping) * p.tickSize,
+			Buy:   v.buy,
+			Sell:  v.sell,
+		})
+	}
+	sort.Slice(msg.Levels, func(i, j int) bool {
+		return msg.Levels[i].Price < msg.Levels[j].Price
+	})
+	analyze(&msg, float64(grouping)*p.tickSize)
+	return msg
+}
+
+// analyze finds the POC, the value area and the volume nodes of a profile.
+// Its levels are sorted and spaced by the given row height, the rows without
+// volume in between are missing. They are only looked up by their row index,
+// so a sparse profile over a wide price range stays cheap.
+func analyze(msg *event.VolumeProfile, rowHeight float64) {
+	if len(msg.Levels) == 0 {
+		return
+	}
+	first := msg.Levels[0].Price
+	row := func(price float64) int {
+		return int(math.Round((price - first) / rowHeight))
+	}
+	volume := func(i int) float64 {
+		return msg.Levels[i].Buy + msg.Levels[i].Sell
+	}
+	n := row(msg.Levels[len(msg.Levels)-1].Price) + 1
+	volumes := make(map[int]float64, len(msg.Levels))
+	total := 0.0
+	poc := 0
+	for i, level := range msg.Levels {
+		volumes[row(level.Price)] = volume(i)
+		total += volume(i)
+		if volume(i) > volume(poc) {
+			poc = i
+		}
+	}
+	msg.POC = msg.Levels[poc].Price
+
+	// The value area grows from the POC towards the side with more volume
+	// next to it, until it holds its share of the total. The empty rows in
+	// between are stepped over.
+	low, high := poc, poc
+	inArea := volume(poc)
+	last := len(msg.Levels) - 1
+	for inArea < total*settings.VolumeProfile.ValueArea && (low > 0 || high < last) {
+		below, above := -1.0, -1.0
+		if low > 0 {
+			below = volume(low - 1)
+		}
+		if high < last {
+			above = volume(high + 1)
+		}
+		if above >= below {
+			high++
+			inArea += above
+		} else {
+			low--
+			inArea += below
+		}
+	}
+	msg.VAL = msg.Levels[low].Price
+	msg.VAH = msg.Levels[high].Price
+
+	// A node is a row with more or less volume than the average and than
+	// every row within the window around it. An empty row can only be a low
+	// volume node when it is a gap of a single row.
+	mean := total / float64(n)
+	window := settings.VolumeProfile.NodeWindow
+	pocRow := row(msg.POC)
+	isNode := func(i int, v float64) (isHigh, isLow bool) {
+		if i == pocRow || i < window || i >= n-window {
+			return false, false
+		}
+		isHigh, isLow = v > mean, v < mean
+		for j := i - window; j <= i+window && (isHigh || isLow); j++ {
+			if j == i {
+				continue
+			}
+			isHigh = isHigh && v > volumes[j]
+			isLow = isLow && v < volumes[j]
+		}
+		return isHigh, isLow
+	}
+	prev := -1
+	for k, level := range msg.Levels {
+		i := row(level.Price)
+		if i-prev == 2 {
+			if _, isLow := isNode(i-1, 0); isLow {
+				msg.LVNs = append(msg.LVNs, first+float64(i-1)*rowHeight)
+			}
+		}
+		prev = i
+		isHigh, isLow := isNode(i, volume(k))
+		if isHigh {
+			msg.HVNs = append(msg.HVNs, level.Price)
+		}
+		if isLow {
+			msg.LVNs = append(msg.LVNs, level.Price)
+		}
+	}
+}
+
+func floorDiv(a, b int64) int64 {
+	q := a / b
+	if a%b != 0 && a < 0 {
+		q--
+	}
+	return q
+}
+
+// Request asks the profile actor of the given pair for a volume profile.
+func Request(e *actor.Engine, pair event.Pair, req event.VolumeProfileRequest) (event.VolumeProfile, error) {
+	return act.Request[event.VolumeProfile](e, act.GetProfilePID(pair), req)
+}
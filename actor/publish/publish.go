@@ -54,7 +54,34 @@ func (p *Publish) Receive(c *actor.Context) {
 	case event.Heatmap:
 		p.broadcast(event.StreamHeatmap, msg)
 	case event.Candle:
+		if msg.Bar != event.BarTime {
+			p.broadcast(msg.Bar.Stream(), msg)
+			return
+		}
 		p.broadcast(event.StreamCandles, msg)
+		if msg.Closed {
+			p.broadcast(event.StreamClosedCandles, msg)
+		}
+	case event.Spread:
+		p.broadcast(event.StreamSpread, msg)
+	case event.FeedHealth:
+		p.broadcast(event.StreamFeedHealth, msg)
+	case event.BookMetrics:
+		p.broadcast(event.StreamBookMetrics, msg)
+	case event.Liquidity:
+		p.broadcast(event.StreamLiquidity, msg)
+	case event.Iceberg:
+		p.broadcast(event.StreamIceberg, msg)
+	case event.Walls:
+		p.broadcast(event.StreamWalls, msg)
+	case event.Depth:
+		p.broadcast(event.StreamDepth, msg)
+	case event.OrderLevels:
+		p.broadcast(event.StreamOrderLevels, msg)
+	case event.Footprint:
+		p.broadcast(event.StreamFootprint, msg)
+	case event.CVD:
+		p.broadcast(event.StreamCVD, msg)
 	}
 }
 
@@ -68,6 +95,9 @@ func (p *Publish) broadcast(stream event.Stream, msg event.TimeFramer) {
 	}
 }
 
+// CreateRouteKey returns the key subscribers of the given stream are routed
+// by. The timeframe is the candle timeframe for candles and the price
+// grouping in ticks for the orderbook and heatmap streams.
 func CreateRouteKey(pair event.Pair, stream event.Stream, timeframe int64) uint32 {
 	key := []byte(pair.Exchange)
 	key = append(key, pair.Symbol...)
@@ -4,6 +4,7 @@ import (
 	act "marketmonkey/actor"
 	"marketmonkey/actor/publish"
 	"marketmonkey/event"
+	"marketmonkey/settings"
 
 	"github.com/anthdm/hollywood/actor"
 )
@@ -11,6 +12,43 @@ import (
 type Stream struct {
 	Stream    event.Stream
 	Timeframe int64
+	// Grouping is the price grouping in ticks of the orderbook and heatmap
+	// streams. Zero subscribes to the heatmap of
+	// settings.DefaultPriceGrouping, which is always computed.
+	Grouping int64
+	// Backfill is the amount of past messages to receive before the live
+	// ones. Only the candle, activity bar, CVD, footprint and heatmap streams
+	// keep a history. The activity bars are only sampled while they have
+	// subscribers, their history starts with the first one.
+	Backfill int
+}
+
+// param is the timeframe of the route key, which is the grouping for the
+// orderbook and heatmap streams.
+func (s Stream) param() int64 {
+	if s.Grouping > 0 {
+		return s.Grouping
+	}
+	if s.Stream == event.StreamHeatmap {
+		return settings.DefaultPriceGrouping
+	}
+	return s.Timeframe
+}
+
+func (s Stream) routeKey(pair event.Pair) uint32 {
+	return publish.CreateRouteKey(pair, s.Stream, s.param())
+}
+
+// onDemand reports whether the orderbook actor only computes the stream
+// while it has subscribers, see event.GroupingSub.
+func (s Stream) onDemand() bool {
+	return s.Grouping > 0 || s.Stream == event.StreamDepth
+}
+
+// isActivityBar reports whether the stream is of activity bars, the trade
+// actor only samples them while they have subscribers, see event.BarSub.
+func (s Stream) isActivityBar() bool {
+	return event.BarTypeOf(s.Stream) != event.BarTime
 }
 
 type Session struct {
@@ -31,24 +69,60 @@ func New(eventCh chan any, pair event.Pair, streams []Stream) actor.Producer {
 	}
 }
 
+func (s *Session) historyPID(stream Stream) *actor.PID {
+	switch stream.Stream {
+	case event.StreamCandles, event.StreamClosedCandles, event.StreamFootprint, event.StreamCVD:
+		return act.GetTradePID(s.pair)
+	case event.StreamHeatmap:
+		return act.GetBookPID(s.pair)
+	}
+	if stream.isActivityBar() {
+		return act.GetTradePID(s.pair)
+	}
+	return nil
+}
+
 func (s *Session) Receive(c *actor.Context) {
 	switch msg := c.Message().(type) {
 	case actor.Started:
-		keys := make([]uint32, len(s.streams))
+		keys := make([]uint32, 0, len(s.streams))
 		for i := 0; i < len(s.streams); i++ {
 			stream := s.streams[i]
-			keys[i] = publish.CreateRouteKey(s.pair, stream.Stream, stream.Timeframe)
+			if stream.onDemand() {
+				c.Send(act.GetBookPID(s.pair), event.GroupingSub{Stream: stream.Stream, Grouping: stream.Grouping})
+			}
+			if stream.isActivityBar() {
+				c.Send(act.GetTradePID(s.pair), event.BarSub{Stream: stream.Stream, Param: stream.Timeframe})
+			}
+			if pid := s.historyPID(stream); pid != nil && stream.Backfill > 0 {
+				// The history actor subscribes us after the backfill, so
+				// it arrives before the live messages.
+				c.Send(pid, event.Backfill{
+					Key:       stream.routeKey(s.pair),
+					Stream:    stream.Stream,
+					Timeframe: stream.param(),
+					Count:     stream.Backfill,
+				})
+				continue
+			}
+			keys = append(keys, stream.routeKey(s.pair))
 		}
 		c.Send(s.publishPID, event.PubSub{Streams: keys})
 	case actor.Stopped:
 		keys := make([]uint32, len(s.streams))
 		for i := 0; i < len(s.streams); i++ {
 			stream := s.streams[i]
-			keys[i] = publish.CreateRouteKey(s.pair, stream.Stream, stream.Timeframe)
+			keys[i] = stream.routeKey(s.pair)
+			if stream.onDemand() {
+				c.Send(act.GetBookPID(s.pair), event.GroupingUnsub{Stream: stream.Stream, Grouping: stream.Grouping})
+			}
+			if stream.isActivityBar() {
+				c.Send(act.GetTradePID(s.pair), event.BarUnsub{Stream: stream.Stream, Param: stream.Timeframe})
+			}
 		}
 		c.Send(s.publishPID, event.PubUnsub{Streams: keys})
 		close(s.eventCh)
-	case event.Orderbook, event.Trade, event.Heatmap, event.Candle:
+	case event.Orderbook, event.Trade, event.Heatmap, event.Candle, event.Spread, event.FeedHealth, event.BookMetrics, event.Liquidity, event.Iceberg, event.Walls, event.Depth, event.OrderLevels, event.HistoryCandles, event.Footprint, event.CVD:
 		s.eventCh <- msg
 	}
 }
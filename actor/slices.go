@@ -0,0 +1,77 @@
+package act
+
+import "sort"
+
+// Slice is the value summed up within one resolution interval starting at
+// Unix.
+type Slice[T any] struct {
+	Unix  int64
+	Value T
+}
+
+// Slices keeps a value per resolution interval for as long as the retention,
+// so the actors can sum up any time range within it on request.
+type Slices[T any] struct {
+	resolution int64
+	retention  int64
+	newFn      func() T
+	// ordered by time, the oldest first
+	slices []Slice[T]
+}
+
+func NewSlices[T any](resolution, retention int64, newFn func() T) *Slices[T] {
+	return &Slices[T]{
+		resolution: resolution,
+		retention:  retention,
+		newFn:      newFn,
+	}
+}
+
+// At returns the value of the interval the given unix time in seconds falls
+// in. A time past the last interval starts a new one and expires the ones
+// past the retention. A late time of an interval that is not kept returns
+// false.
+func (s *Slices[T]) At(unix int64) (T, bool) {
+	unix = unix / s.resolution * s.resolution
+	n := len(s.slices)
+	switch {
+	case n > 0 && s.slices[n-1].Unix == unix:
+		return s.slices[n-1].Value, true
+	case n > 0 && s.slices[n-1].Unix > unix:
+		i := sort.Search(n, func(i int) bool { return s.slices[i].Unix >= unix })
+		if s.slices[i].Unix != unix {
+			var zero T
+			return zero, false
+		}
+		return s.slices[i].Value, true
+	}
+	s.expire(unix)
+	value := s.newFn()
+	s.slices = append(s.slices, Slice[T]{Unix: unix, Value: value})
+	return value, true
+}
+
+func (s *Slices[T]) expire(now int64) {
+	cutoff := now - s.retention
+	i := 0
+	for i < len(s.slices) && s.slices[i].Unix < cutoff {
+		i++
+	}
+	if i > 0 {
+		s.slices = append(s.slices[:0], s.slices[i:]...)
+	}
+}
+
+// Since returns the intervals from the one the given unix time in seconds
+// falls in on, the oldest first. They must not be modified.
+func (s *Slices[T]) Since(from int64) []Slice[T] {
+	i := sort.Search(len(s.slices), func(i int) bool {
+		return s.slices[i].Unix+s.resolution > from
+	})
+	return s.slices[i:]
+}
+
+// Resolution returns the length of an interval in seconds.
+func (s *Slices[T]) Resolution() int64 {
+	return s.resolution
+}
@@ -0,0 +1,162 @@
+package spread
+
+import (
+	"time"
+
+	act "marketmonkey/actor"
+	"marketmonkey/actor/publish"
+	"marketmonkey/event"
+	"marketmonkey/settings"
+
+	"github.com/anthdm/hollywood/actor"
+)
+
+// The quotes of a venue that did not update within this time are left out,
+// so a dead feed drops out of the spreads instead of freezing in them.
+const quoteTimeout = time.Second * 5
+
+// Spread spawns a monitor for every asset of the spread market.
+type Spread struct{}
+
+func New() actor.Producer {
+	return func() actor.Receiver {
+		return &Spread{}
+	}
+}
+
+func (s *Spread) Receive(c *actor.Context) {
+	switch c.Message().(type) {
+	case actor.Started:
+		for name, config := range settings.SpreadMonitors {
+			pair := event.NewPair(settings.Spread, name)
+			// Spawned as "symbol" so the publish actor lives under the
+			// path act.GetPublishPID expects.
+			c.SpawnChild(NewMonitor(pair, config), "symbol", actor.WithID(pair.Symbol))
+		}
+	}
+}
+
+// Monitor tracks the top of the book of the same asset on multiple venues
+// and publishes the spreads between them and the futures basis.
+type Monitor struct {
+	pair       event.Pair
+	config     settings.SpreadMonitor
+	sources    []event.Pair
+	quotes     map[event.Pair]event.VenueQuote
+	publishPID *actor.PID
+}
+
+func NewMonitor(pair event.Pair, config settings.SpreadMonitor) actor.Producer {
+	return func() actor.Receiver {
+		return &Monitor{
+			pair:   pair,
+			config: config,
+			quotes: make(map[event.Pair]event.VenueQuote),
+		}
+	}
+}
+
+func (m *Monitor) Receive(c *actor.Context) {
+	switch msg := c.Message().(type) {
+	case actor.Started:
+		m.publishPID = c.SpawnChild(publish.New(m.pair), "publish", actor.WithID(m.pair.Symbol))
+		m.sources = m.collectSources()
+		for _, pair := range m.sources {
+			key := publish.CreateRouteKey(pair, event.StreamOrderbook, 0)
+			c.Send(act.GetPublishPID(pair), event.PubSub{Streams: []uint32{key}})
+		}
+		c.SendRepeat(c.PID(), event.Tick{}, time.Millisecond*500)
+	case actor.Stopped:
+		for _, pair := range m.sources {
+			key := publish.CreateRouteKey(pair, event.StreamOrderbook, 0)
+			c.Send(act.GetPublishPID(pair), event.PubUnsub{Streams: []uint32{key}})
+		}
+	case event.Orderbook:
+		if len(msg.BidPrices) == 0 || len(msg.AskPrices) == 0 {
+			return
+		}
+		m.quotes[msg.Pair] = event.VenueQuote{
+			Venue: msg.Pair,
+			Bid:   msg.BidPrices[0],
+			Ask:   msg.AskPrices[0],
+			Unix:  time.Now().UnixMilli(),
+		}
+	case event.Tick:
+		c.Send(m.publishPID, m.calculate(time.Now()))
+	}
+}
+
+func (m *Monitor) collectSources() []event.Pair {
+	seen := map[event.Pair]bool{}
+	sources := []event.Pair{}
+	all := append([]settings.Source{m.config.Futures, m.config.Spot}, m.config.Venues...)
+	for _, src := range all {
+		pair := event.NewPair(src.Exchange, src.Symbol)
+		if src.Exchange == "" || seen[pair] {
+			continue
+		}
+		seen[pair] = true
+		sources = append(sources, pair)
+	}
+	return sources
+}
+
+func (m *Monitor) calculate(now time.Time) event.Spread {
+	msg := event.Spread{
+		Pair:    m.pair,
+		Unix:    now.Unix(),
+		Quotes:  make([]event.VenueQuote, len(m.config.Venues)),
+		Spreads: make([][]float64, len(m.config.Venues)),
+	}
+	for i, src := range m.config.Venues {
+		pair := event.NewPair(src.Exchange, src.Symbol)
+		quote, ok := m.quote(pair, now)
+		if !ok {
+			quote = event.VenueQuote{Venue: pair}
+		}
+		msg.Quotes[i] = quote
+	}
+	for i, bid := range msg.Quotes {
+		msg.Spreads[i] = make([]float64, len(msg.Quotes))
+		for j, ask := range msg.Quotes {
+			if i == j || bid.Bid == 0 || ask.Ask == 0 {
+				continue
+			}
+			mid := (bid.Mid() + ask.Mid()) / 2
+			msg.Spreads[i][j] = (bid.Bid - ask.Ask) / mid * 10000
+		}
+	}
+
+	futures, okFutures := m.quote(event.NewPair(m.config.Futures.Exchange, m.config.Futures.Symbol), now)
+	spot, okSpot := m.quote(event.NewPair(m.config.Spot.Exchange, m.config.Spot.Symbol), now)
+	if okFutures && okSpot {
+		msg.HasBasis = true
+		msg.Basis = futures.Mid() - spot.Mid()
+		msg.BasisPerc = msg.Basis / spot.Mid() * 100
+		if m.config.Expiry > 0 {
+			msg.BasisAnnualized = annualize(msg.BasisPerc, m.config.Expiry, now)
+		}
+	}
+	return msg
+}
+
+// quote returns the quote of a venue unless it timed out.
+func (m *Monitor) quote(pair event.Pair, now time.Time) (event.VenueQuote, bool) {
+	quote, ok := m.quotes[pair]
+	if !ok || now.UnixMilli()-quote.Unix > quoteTimeout.Milliseconds() {
+		return event.VenueQuote{}, false
+	}
+	return quote, true
+}
+
+// annualize scales the basis of a dated future to a yearly rate, based on
+// the time left till expiry. A perpetual does not converge at a known time,
+// its basis is not annualized.
+func annualize(basisPerc float64, expiry int64, now time.Time) float64 {
+	period := time.Unix(expiry, 0).Sub(now)
+	if period <= 0 {
+		return 0
+	}
+	year := time.Hour * 24 * 365
+	return basisPerc * float64(year) / float64(period)
+}
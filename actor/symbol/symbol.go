@@ -1,10 +1,17 @@
 package symbol
 
 import (
+	"time"
+
+	act "marketmonkey/actor"
+	"marketmonkey/actor/ingress"
+	"marketmonkey/actor/l3book"
 	"marketmonkey/actor/orderbook"
+	"marketmonkey/actor/profile"
 	"marketmonkey/actor/publish"
 	"marketmonkey/actor/stat"
 	"marketmonkey/actor/trade"
+	"marketmonkey/actor/vwap"
 	"marketmonkey/event"
 
 	"github.com/anthdm/hollywood/actor"
@@ -16,12 +23,27 @@ type Symbol struct {
 	bookPID    *actor.PID
 	publishPID *actor.PID
 	tradePID   *actor.PID
+	profilePID *actor.PID
+	vwapPID    *actor.PID
+	// spawned with the first order event, only venues with an L3 feed
+	// push them
+	l3PID *actor.PID
+	queue *ingress.Queue
+
+	// feed stats since the last report to the health monitor
+	messages  int64
+	lastUnix  int64
+	lastStats ingress.Stats
 }
 
-func New(pair event.Pair) actor.Producer {
+// New returns a symbol actor for the given pair. Consumers push the frames of
+// their websocket into the given queue, which is drained on an interval. When
+// the queue is nil the messages are expected to be sent to the actor directly.
+func New(pair event.Pair, queue *ingress.Queue) actor.Producer {
 	return func() actor.Receiver {
 		return &Symbol{
-			pair: pair,
+			pair:  pair,
+			queue: queue,
 		}
 	}
 }
@@ -30,13 +52,26 @@ func (s *Symbol) Receive(c *actor.Context) {
 	switch c.Message().(type) {
 	case actor.Started:
 		s.start(c)
+		c.SendRepeat(c.PID(), event.Tick{}, time.Second)
+		if s.queue != nil {
+			c.SendRepeat(c.PID(), event.TickIngress{}, s.queue.Config().FlushInterval)
+		}
 	case event.Trade:
+		s.onMessage()
 		c.Forward(s.bookPID)
 		c.Forward(s.tradePID)
+		c.Forward(s.profilePID)
+		c.Forward(s.vwapPID)
 	case event.Stat:
+		s.onMessage()
 		c.Forward(s.statPID)
 	case event.BookUpdate:
+		s.onMessage()
 		c.Forward(s.bookPID)
+	case event.TickIngress:
+		s.drain(c)
+	case event.Tick:
+		s.report(c)
 	}
 }
 
@@ -44,5 +79,57 @@ func (s *Symbol) start(c *actor.Context) {
 	s.statPID = c.SpawnChild(stat.New(s.pair), "stat", actor.WithID(s.pair.Symbol))
 	s.bookPID = c.SpawnChild(orderbook.New(s.pair), "book", actor.WithID(s.pair.Symbol))
 	s.tradePID = c.SpawnChild(trade.New(s.pair), "trade", actor.WithID(s.pair.Symbol))
+	s.profilePID = c.SpawnChild(profile.New(s.pair), "profile", actor.WithID(s.pair.Symbol))
+	s.vwapPID = c.SpawnChild(vwap.New(s.pair), "vwap", actor.WithID(s.pair.Symbol))
 	s.publishPID = c.SpawnChild(publish.New(s.pair), "publish", actor.WithID(s.pair.Symbol))
 }
+
+func (s *Symbol) onMessage() {
+	s.messages++
+	s.lastUnix = time.Now().UnixMilli()
+}
+
+// drain forwards everything the consumer pushed since the last tick. Trades
+// go first, so the book sees the prints before the levels they consumed.
+func (s *Symbol) drain(c *actor.Context) {
+	book, trades := s.queue.Drain()
+	for _, trade := range trades {
+		c.Send(s.bookPID, trade)
+		c.Send(s.tradePID, trade)
+		c.Send(s.profilePID, trade)
+		c.Send(s.vwapPID, trade)
+	}
+	if book != nil {
+		c.Send(s.bookPID, *book)
+	}
+	snapshot, events := s.queue.DrainL3()
+	if (snapshot != nil || len(events) > 0) && s.l3PID == nil {
+		s.l3PID = c.SpawnChild(l3book.New(s.pair), "l3book", actor.WithID(s.pair.Symbol))
+	}
+	if snapshot != nil {
+		c.Send(s.l3PID, *snapshot)
+	}
+	if len(events) > 0 {
+		c.Send(s.l3PID, event.L3Update{Pair: s.pair, Events: events})
+	}
+	if book != nil || len(trades) > 0 || len(events) > 0 {
+		s.lastUnix = time.Now().UnixMilli()
+	}
+}
+
+func (s *Symbol) report(c *actor.Context) {
+	msg := event.FeedStats{
+		Pair:     s.pair,
+		Messages: s.messages,
+		LastUnix: s.lastUnix,
+	}
+	if s.queue != nil {
+		stats := s.queue.Stats()
+		msg.Messages += stats.BookUpdates + stats.Trades + stats.L3Events -
+			s.lastStats.BookUpdates - s.lastStats.Trades - s.lastStats.L3Events
+		msg.Dropped = stats.Dropped - s.lastStats.Dropped
+		s.lastStats = stats
+	}
+	c.Send(act.GetHealthPID(), msg)
+	s.messages = 0
+}
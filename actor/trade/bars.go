@@ -0,0 +1,176 @@
+package trade
+
+import (
+	"math"
+
+	"marketmonkey/event"
+)
+
+// maxBricks is the most renko bricks a single trade emits. On a move over
+// more of them, e.g. with a brick size far below the price, the brick in
+// progress starts over at the trade instead.
+const maxBricks = 100
+
+// barKey addresses the activity bars of a type and parameter.
+type barKey struct {
+	bar   event.BarType
+	param int64
+}
+
+// ActivitySampler builds the bars that close by the trading activity instead
+// of by time, see event.BarType. The bar in progress is emitted with every
+// trade and once more with Closed set when it closes.
+type ActivitySampler struct {
+	pair     event.Pair
+	bar      event.BarType
+	param    int64
+	tickSize float64
+	// the bar in progress, nil until the first trade
+	candle *event.Candle
+	seq    int64
+	// the close of the last renko brick and the direction it went
+	brick    float64
+	brickDir int
+	// the notional traded in the bar in progress
+	notional   float64
+	handleFunc func(event.Candle)
+}
+
+func NewActivitySampler(pair event.Pair, bar event.BarType, param int64, tickSize float64, fn func(event.Candle)) *ActivitySampler {
+	return &ActivitySampler{
+		pair:       pair,
+		bar:        bar,
+		param:      max(param, 1),
+		tickSize:   tickSize,
+		handleFunc: fn,
+	}
+}
+
+func (s *ActivitySampler) ProcessTrade(trade event.Trade) {
+	if s.bar == event.BarRenko {
+		s.processRenko(trade)
+		return
+	}
+	if s.bar == event.BarRange && s.candle != nil {
+		high := math.Max(s.candle.High, trade.Price)
+		low := math.Min(s.candle.Low, trade.Price)
+		if high-low > s.rangeSize()+s.tickSize/2 {
+			s.close()
+		}
+	}
+	if s.candle == nil {
+		s.open(trade.Unix/1000, trade.Price)
+	}
+	s.add(trade)
+
+	var closes bool
+	switch s.bar {
+	case event.BarTick:
+		closes = s.candle.Tbuy+s.candle.Tsell >= float64(s.param)
+	case event.BarVolume:
+		closes = s.candle.Vbuy+s.candle.Vsell >= float64(s.param)
+	case event.BarDollar:
+		closes = s.notional >= float64(s.param)
+	}
+	if closes {
+		s.close()
+		return
+	}
+	s.handleFunc(*s.candle)
+}
+
+func (s *ActivitySampler) rangeSize() float64 {
+	return float64(s.param) * s.tickSize
+}
+
+func (s *ActivitySampler) open(unix int64, price float64) {
+	s.candle = &event.Candle{
+		Pair:      s.pair,
+		Timeframe: s.param,
+		Unix:      unix,
+		Open:      price,
+		Close:     price,
+		High:      price,
+		Low:       price,
+		Bar:       s.bar,
+		Seq:       s.seq,
+	}
+	s.notional = 0
+}
+
+func (s *ActivitySampler) add(trade event.Trade) {
+	candle := s.candle
+	candle.Close = trade.Price
+	candle.High = math.Max(candle.High, trade.Price)
+	candle.Low = math.Min(candle.Low, trade.Price)
+	if trade.IsBuy {
+		candle.Vbuy += trade.Qty
+		candle.Tbuy++
+	} else {
+		candle.Vsell += trade.Qty
+		candle.Tsell++
+	}
+	s.notional += trade.Qty * trade.Price
+}
+
+func (s *ActivitySampler) close() {
+	s.candle.Closed = true
+	s.handleFunc(*s.candle)
+	s.candle = nil
+	s.seq++
+}
+
+// processRenko adds the trade to the brick in progress. A brick is done once
+// the price moved its size beyond the last brick, or twice its size against
+// the direction of the last brick. A move over several sizes emits several
+// bricks, the volume goes to the first one.
+func (s *ActivitySampler) processRenko(trade event.Trade) {
+	size := s.rangeSize()
+	unix := trade.Unix / 1000
+	if s.candle == nil {
+		s.brick = math.Floor(trade.Price/size) * size
+		s.open(unix, s.brick)
+	}
+	if math.Abs(trade.Price-s.brick) > maxBricks*size {
+		// It never reached a brick, it keeps its seq and volume.
+		s.brick = math.Floor(trade.Price/size) * size
+		s.brickDir = 0
+		s.candle.Open = s.brick
+		s.candle.High = s.brick
+		s.candle.Low = s.brick
+		s.candle.Close = s.brick
+	}
+	s.add(trade)
+
+	for {
+		var from, to float64
+		switch {
+		case s.brickDir >= 0 && trade.Price >= s.brick+size:
+			from, to = s.brick, s.brick+size
+		case s.brickDir < 0 && trade.Price >= s.brick+2*size:
+			from, to = s.brick+size, s.brick+2*size
+		case s.brickDir <= 0 && trade.Price <= s.brick-size:
+			from, to = s.brick, s.brick-size
+		case s.brickDir > 0 && trade.Price <= s.brick-2*size:
+			from, to = s.brick-size, s.brick-2*size
+		default:
+			s.handleFunc(*s.candle)
+			return
+		}
+		s.candle.Open = from
+		s.candle.Close = to
+		s.candle.High = math.Max(from, to)
+		s.candle.Low = math.Min(from, to)
+		s.close()
+
+		s.brick = to
+		s.brickDir = 1
+		if to < from {
+			s.brickDir = -1
+		}
+		s.open(unix, to)
+		s.candle.Close = trade.Price
+		s.candle.High = math.Max(to, trade.Price)
+		s.candle.Low = math.Min(to, trade.Price)
+	}
+}
@@ -0,0 +1,115 @@
+package trade
+
+import (
+	"testing"
+
+	"marketmonkey/event"
+)
+
+func TestActivitySamplerClose(t *testing.T) {
+	// bar is the open and close of a candle and its seq and trade count
+	type bar struct {
+		open, close float64
+		seq         int64
+		trades      float64
+	}
+	tests := []struct {
+		name   string
+		bar    event.BarType
+		prices []float64
+		// the amount of closed bars and the last of them
+		closed     int
+		lastClosed bar
+		// the bar in progress
+		open bar
+	}{
+		{
+			name:   "range within its size",
+			bar:    event.BarRange,
+			prices: []float64{100, 105, 110},
+			open:   bar{100, 110, 0, 3},
+		},
+		{
+			name:       "range up beyond its size",
+			bar:        event.BarRange,
+			prices:     []float64{100, 111},
+			closed:     1,
+			lastClosed: bar{100, 100, 0, 1},
+			open:       bar{111, 111, 1, 1},
+		},
+		{
+			name:       "range down and up beyond its size",
+			bar:        event.BarRange,
+			prices:     []float64{100, 95, 106},
+			closed:     1,
+			lastClosed: bar{100, 95, 0, 2},
+			open:       bar{106, 106, 1, 1},
+		},
+		{
+			name:   "renko within a brick",
+			bar:    event.BarRenko,
+			prices: []float64{100, 105, 91},
+			open:   bar{100, 91, 0, 3},
+		},
+		{
+			name:       "renko brick up",
+			bar:        event.BarRenko,
+			prices:     []float64{100, 110},
+			closed:     1,
+			lastClosed: bar{100, 110, 0, 2},
+			open:       bar{110, 110, 1, 0},
+		},
+		{
+			name:       "renko several bricks",
+			bar:        event.BarRenko,
+			prices:     []float64{100, 125},
+			closed:     2,
+			lastClosed: bar{110, 120, 1, 0},
+			open:       bar{120, 125, 2, 0},
+		},
+		{
+			name:       "renko reversal needs two bricks",
+			bar:        event.BarRenko,
+			prices:     []float64{100, 115, 95, 89},
+			closed:     2,
+			lastClosed: bar{100, 90, 1, 2},
+			open:       bar{90, 89, 2, 0},
+		},
+		{
+			name:       "renko at the brick cap",
+			bar:        event.BarRenko,
+			prices:     []float64{100, 1100},
+			closed:     maxBricks,
+			lastClosed: bar{1090, 1100, maxBricks - 1, 0},
+			open:       bar{1100, 1100, maxBricks, 0},
+		},
+		{
+			name:   "renko beyond the brick cap",
+			bar:    event.BarRenko,
+			prices: []float64{100, 105, 1205},
+			open:   bar{1200, 1205, 0, 3},
+		},
+	}
+	for _, tt := range tests {
+		var closed int
+		var lastClosed, open bar
+		s := NewActivitySampler(event.Pair{}, tt.bar, 10, 1, func(c event.Candle) {
+			b := bar{c.Open, c.Close, c.Seq, c.Tbuy + c.Tsell}
+			if c.Closed {
+				closed++
+				lastClosed = b
+			} else {
+				open = b
+			}
+		})
+		for i, price := range tt.prices {
+			s.ProcessTrade(event.Trade{Unix: int64(i) * 1000, Price: price, Qty: 1, IsBuy: true})
+		}
+		if closed != tt.closed || lastClosed != tt.lastClosed {
+			t.Errorf("%s: %d closed, last %v, want %d, last %v", tt.name, closed, lastClosed, tt.closed, tt.lastClosed)
+		}
+		if open != tt.open {
+			t.Errorf("%s: open bar %v, want %v", tt.name, open, tt.open)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package trade
+
+import (
+	"marketmonkey/event"
+	"marketmonkey/settings"
+)
+
+// CVDSampler accumulates the volume delta of the trades into the bars of a
+// timeframe. Like the CandleSampler it is advanced by a timer, so every bar
+// is emitted once more with Closed set and the bars without trades are flat.
+type CVDSampler struct {
+	pair      event.Pair
+	timeframe int64
+	// the venues of an aggregated pair that are perpetual futures
+	perps map[string]bool
+	// the bar in progress, nil until the first trade
+	bar        *event.CVD
+	handleFunc func(event.CVD)
+}
+
+func NewCVDSampler(pair event.Pair, timeframe int64, fn func(event.CVD)) *CVDSampler {
+	perps := make(map[string]bool)
+	if pair.Exchange == settings.Aggregated {
+		for _, src := range settings.Aggregates[pair.Symbol].Sources {
+			perps[src.Exchange] = src.Perp
+		}
+	}
+	return &CVDSampler{
+		pair:       pair,
+		timeframe:  timeframe,
+		perps:      perps,
+		handleFunc: fn,
+	}
+}
+
+func (s *CVDSampler) ProcessTrade(trade event.Trade) {
+	unix := trade.Unix / 1000
+	if s.bar == nil {
+		s.bar = &event.CVD{
+			Pair:      s.pair,
+			Timeframe: s.timeframe,
+			Unix:      unix / s.timeframe * s.timeframe,
+		}
+	}
+	s.Advance(unix)
+
+	delta := trade.Qty
+	if !trade.IsBuy {
+		delta = -delta
+	}
+	bar := s.bar
+	bar.Close += delta
+	bar.High = max(bar.High, bar.Close)
+	bar.Low = min(bar.Low, bar.Close)
+	if trade.Venue != "" {
+		if s.perps[trade.Venue] {
+			bar.Perp += delta
+		} else {
+			bar.Spot += delta
+		}
+	}
+	s.handleFunc(*bar)
+}
+
+// Advance closes the bar in progress once the given unix time in seconds is
+// past its interval, see CandleSampler.Advance.
+func (s *CVDSampler) Advance(unix int64) {
+	if s.bar == nil {
+		return
+	}
+	start := unix / s.timeframe * s.timeframe
+	if s.bar.Unix+s.timeframe > start {
+		return
+	}
+
+	closed := *s.bar
+	closed.Closed = true
+	s.handleFunc(closed)
+
+	next := closed.Unix + s.timeframe
+	if skip := (start-next)/s.timeframe - int64(settings.History.Candles); skip > 0 {
+		next += skip * s.timeframe
+	}
+	prev := closed
+	for ; next < start; next += s.timeframe {
+		prev = s.flat(prev, next, true)
+		s.handleFunc(prev)
+	}
+
+	bar := s.flat(prev, start, false)
+	s.bar = &bar
+	s.handleFunc(bar)
+}
+
+// flat returns the bar at unix that continues from prev without trades. The
+// session start is moved up when the bar starts a new session.
+func (s *CVDSampler) flat(prev event.CVD, unix int64, closed bool) event.CVD {
+	bar := prev
+	bar.Unix = unix
+	bar.Open = prev.Close
+	bar.High = prev.Close
+	bar.Low = prev.Close
+	bar.Closed = closed
+	if unix/settings.SessionLength != prev.Unix/settings.SessionLength {
+		bar.SessionStart = prev.Close
+		bar.SpotSessionStart = prev.Spot
+		bar.PerpSessionStart = prev.Perp
+	}
+	return bar
+}
@@ -0,0 +1,71 @@
+package trade
+
+import (
+	"math"
+	"testing"
+
+	"marketmonkey/event"
+)
+
+func TestCVDSamplerSession(t *testing.T) {
+	const day = 86400
+	// cvdStep is a trade of qty at unix in seconds, or a timer tick when
+	// qty is zero
+	type cvdStep struct {
+		unix  int64
+		qty   float64
+		venue string
+	}
+	tests := []struct {
+		name  string
+		steps []cvdStep
+		// the bar in progress
+		want event.CVD
+	}{
+		{
+			name:  "first session",
+			steps: []cvdStep{{unix: 100, qty: 2, venue: "spot"}, {unix: 4000, qty: -1, venue: "perp"}},
+			want:  event.CVD{Unix: 3600, Open: 2, High: 2, Low: 1, Close: 1, Spot: 2, Perp: -1},
+		},
+		{
+			name:  "new session by a trade",
+			steps: []cvdStep{{unix: day - 100, qty: 2, venue: "spot"}, {unix: day + 100, qty: -3, venue: "perp"}},
+			want: event.CVD{
+				Unix: day, Open: 2, High: 2, Low: -1, Close: -1, Spot: 2, Perp: -3,
+				SessionStart: 2, SpotSessionStart: 2,
+			},
+		},
+		{
+			name:  "new session by the timer",
+			steps: []cvdStep{{unix: day - 100, qty: -2, venue: "perp"}, {unix: day + 3600}},
+			want: event.CVD{
+				Unix: day + 3600, Open: -2, High: -2, Low: -2, Close: -2, Perp: -2,
+				SessionStart: -2, PerpSessionStart: -2,
+			},
+		},
+		{
+			name:  "session start kept within the session",
+			steps: []cvdStep{{unix: day - 100, qty: 2}, {unix: day + 100, qty: 1}, {unix: day + 7200, qty: 1}},
+			want: event.CVD{
+				Unix: day + 7200, Open: 3, High: 4, Low: 3, Close: 4,
+				SessionStart: 2,
+			},
+		},
+	}
+	for _, tt := range tests {
+		var last event.CVD
+		s := NewCVDSampler(event.Pair{}, 3600, func(bar event.CVD) { last = bar })
+		s.perps = map[string]bool{"perp": true}
+		for _, step := range tt.steps {
+			if step.qty == 0 {
+				s.Advance(step.unix)
+				continue
+			}
+			s.ProcessTrade(event.Trade{Unix: step.unix * 1000, Qty: math.Abs(step.qty), IsBuy: step.qty > 0, Venue: step.venue})
+		}
+		tt.want.Timeframe = 3600
+		if last != tt.want {
+			t.Errorf("%s: bar = %+v, want %+v", tt.name, last, tt.want)
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package trade
+
+import (
+	"sort"
+
+	"marketmonkey/event"
+)
+
+type footprintBar struct {
+	unix   int64
+	levels map[float64]*event.FootprintLevel
+	// traded since the last emit
+	changed bool
+}
+
+// FootprintSampler sums the traded volume per price and aggressor side for
+// the bars of a timeframe. The trades are at the prices of the venue, the
+// charts group them into larger buckets. Unlike the candles the bars are
+// only emitted by Flush, a bar can have thousands of levels.
+type FootprintSampler struct {
+	pair      event.Pair
+	timeframe int64
+	bar       *footprintBar
+	// start of the first interval after the last closed bar, late trades
+	// are folded into it instead of reopening a closed bar
+	next       int64
+	handleFunc func(event.Footprint)
+}
+
+func NewFootprintSampler(pair event.Pair, timeframe int64, fn func(event.Footprint)) *FootprintSampler {
+	return &FootprintSampler{
+		pair:       pair,
+		timeframe:  timeframe,
+		handleFunc: fn,
+	}
+}
+
+func (s *FootprintSampler) ProcessTrade(trade event.Trade) {
+	unix := max(trade.Unix/1000/s.timeframe*s.timeframe, s.next)
+	if s.bar != nil && s.bar.unix+s.timeframe <= unix {
+		s.close()
+	}
+	if s.bar == nil {
+		s.bar = &footprintBar{
+			unix:   unix,
+			levels: make(map[float64]*event.FootprintLevel),
+		}
+	}
+	level, ok := s.bar.levels[trade.Price]
+	if !ok {
+		level = &event.FootprintLevel{Price: trade.Price}
+		s.bar.levels[trade.Price] = level
+	}
+	if trade.IsBuy {
+		level.Ask += trade.Qty
+	} else {
+		level.Bid += trade.Qty
+	}
+	s.bar.changed = true
+}
+
+// Flush emits the bar when it traded since the last flush, and closes it
+// once the given unix time in seconds is past its interval.
+func (s *FootprintSampler) Flush(unix int64) {
+	if s.bar == nil {
+		return
+	}
+	if s.bar.unix+s.timeframe <= unix {
+		s.close()
+		return
+	}
+	if s.bar.changed {
+		s.handleFunc(s.footprint(false))
+		s.bar.changed = false
+	}
+}
+
+func (s *FootprintSampler) close() {
+	s.handleFunc(s.footprint(true))
+	s.next = s.bar.unix + s.timeframe
+	s.bar = nil
+}
+
+func (s *FootprintSampler) footprint(closed bool) event.Footprint {
+	msg := event.Footprint{
+		Pair:      s.pair,
+		Timeframe: s.timeframe,
+		Unix:      s.bar.unix,
+		Levels:    make([]event.FootprintLevel, 0, len(s.bar.levels)),
+		Closed:    closed,
+	}
+	pocVolume := 0.0
+	for _, level := range s.bar.levels {
+		msg.Levels = append(msg.Levels, *level)
+		msg.Delta += level.Ask - level.Bid
+		if volume := level.Ask + level.Bid; volume > pocVolume {
+			pocVolume = volume
+			msg.POC = level.Price
+		}
+	}
+	sort.Slice(msg.Levels, func(i, j int) bool {
+		return msg.Levels[i].Price < msg.Levels[j].Price
+	})
+	return msg
+}
@@ -0,0 +1,84 @@
+package trade
+
+import (
+	"reflect"
+	"testing"
+
+	"marketmonkey/event"
+)
+
+func TestFootprintSampler(t *testing.T) {
+	// bar is the unix time and the levels of an emitted footprint
+	type bar struct {
+		unix   int64
+		closed bool
+		levels []event.FootprintLevel
+	}
+	tests := []struct {
+		name  string
+		steps []samplerStep
+		bars  []bar
+	}{
+		{
+			name:  "flushed without trades",
+			steps: []samplerStep{{unix: 60_000, price: 10, isBuy: true}, {unix: 61, advance: true}, {unix: 62, advance: true}},
+			bars:  []bar{{60, false, []event.FootprintLevel{{Price: 10, Ask: 1}}}},
+		},
+		{
+			name: "levels by side",
+			steps: []samplerStep{
+				{unix: 60_000, price: 10, isBuy: true},
+				{unix: 61_000, price: 10},
+				{unix: 62_000, price: 9},
+				{unix: 63, advance: true},
+			},
+			bars: []bar{{60, false, []event.FootprintLevel{{Price: 9, Bid: 1}, {Price: 10, Bid: 1, Ask: 1}}}},
+		},
+		{
+			name:  "closed by the timer",
+			steps: []samplerStep{{unix: 60_000, price: 10, isBuy: true}, {unix: 120, advance: true}},
+			bars:  []bar{{60, true, []event.FootprintLevel{{Price: 10, Ask: 1}}}},
+		},
+		{
+			name: "closed by a trade",
+			steps: []samplerStep{
+				{unix: 60_000, price: 10, isBuy: true},
+				{unix: 125_000, price: 11, isBuy: true},
+				{unix: 126, advance: true},
+			},
+			bars: []bar{
+				{60, true, []event.FootprintLevel{{Price: 10, Ask: 1}}},
+				{120, false, []event.FootprintLevel{{Price: 11, Ask: 1}}},
+			},
+		},
+		{
+			name: "late trade folded into the next bar",
+			steps: []samplerStep{
+				{unix: 60_000, price: 10, isBuy: true},
+				{unix: 120, advance: true},
+				{unix: 119_000, price: 9},
+				{unix: 121, advance: true},
+			},
+			bars: []bar{
+				{60, true, []event.FootprintLevel{{Price: 10, Ask: 1}}},
+				{120, false, []event.FootprintLevel{{Price: 9, Bid: 1}}},
+			},
+		},
+	}
+	for _, tt := range tests {
+		var bars []bar
+		s := NewFootprintSampler(event.Pair{}, 60, func(f event.Footprint) {
+			bars = append(bars, bar{f.Unix, f.Closed, f.Levels})
+		})
+		for _, step := range tt.steps {
+			if step.advance {
+				s.Flush(step.unix)
+			} else {
+				s.ProcessTrade(event.Trade{Unix: step.unix, Price: step.price, Qty: 1, IsBuy: step.isBuy})
+			}
+		}
+		if !reflect.DeepEqual(bars, tt.bars) {
+			t.Errorf("%s: footprints %v, want %v", tt.name, bars, tt.bars)
+		}
+	}
+}
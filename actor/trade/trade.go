@@ -2,27 +2,65 @@ package trade
 
 import (
 	"math"
+	"time"
 
+	act "marketmonkey/actor"
 	"marketmonkey/event"
 	"marketmonkey/settings"
 
 	"github.com/anthdm/hollywood/actor"
 )
 
+const (
+	// closeInterval is how often the samplers are checked for candles to
+	// close.
+	closeInterval = time.Millisecond * 250
+	closeDelay    = time.Millisecond * 500
+	// activityIdleTimeout is how long the activity bars are kept sampling
+	// after their last subscriber left, so a chart that resubscribes keeps
+	// its history.
+	activityIdleTimeout = time.Minute
+)
+
+// activityBars are the activity bars of a type and parameter, sampled while
+// they have subscribers.
+type activityBars struct {
+	sampler *ActivitySampler
+	subs    int
+	// when the last subscriber left
+	idleSince time.Time
+}
+
 type Trade struct {
 	pair       event.Pair
 	publishPID *actor.PID
 	samplers   map[int64]*CandleSampler
-	lastUnix   int64
-	lastPrice  float64
-	ctx        *actor.Context
+	footprints map[int64]*FootprintSampler
+	cvds       map[int64]*CVDSampler
+	activity   map[barKey]*activityBars
+	// the last candles, footprints and CVD bars per timeframe for the
+	// backfill of new subscribers
+	history          map[int64]*act.History[event.Candle]
+	footprintHistory map[int64]*act.History[event.Footprint]
+	cvdHistory       map[int64]*act.History[event.CVD]
+	activityHistory  map[barKey]*act.History[event.Candle]
+	lastUnix         int64
+	lastPrice        float64
+	ctx              *actor.Context
 }
 
 func New(pair event.Pair) actor.Producer {
 	return func() actor.Receiver {
 		return &Trade{
-			pair:     pair,
-			samplers: make(map[int64]*CandleSampler),
+			pair:             pair,
+			samplers:         make(map[int64]*CandleSampler),
+			footprints:       make(map[int64]*FootprintSampler),
+			cvds:             make(map[int64]*CVDSampler),
+			activity:         make(map[barKey]*activityBars),
+			history:          make(map[int64]*act.History[event.Candle]),
+			footprintHistory: make(map[int64]*act.History[event.Footprint]),
+			cvdHistory:       make(map[int64]*act.History[event.CVD]),
+			activityHistory:  make(map[barKey]*act.History[event.Candle]),
 		}
 	}
 }
@@ -33,10 +71,13 @@ func (t *Trade) Receive(c *actor.Context) {
 		t.ctx = c
 		for _, tf := range settings.TickIntervals {
 			if !tf.Disabled {
-				t.samplers[tf.Interval] = NewCandleSampler(tf.Interval, t.onCandle)
+				t.samplers[tf.Interval] = NewCandleSampler(t.pair, tf.Interval, t.onCandle)
+				t.footprints[tf.Interval] = NewFootprintSampler(t.pair, tf.Interval, t.onFootprint)
+				t.cvds[tf.Interval] = NewCVDSampler(t.pair, tf.Interval, t.onCVD)
 			}
 		}
 		t.publishPID = c.Parent().Child("publish/" + t.pair.Symbol)
+		c.SendRepeat(c.PID(), event.Tick{}, closeInterval)
 	case event.Trade:
 		if msg.Unix > t.lastUnix || t.lastPrice == 0 {
 			t.lastUnix = msg.Unix / 1000
@@ -46,72 +87,213 @@ func (t *Trade) Receive(c *actor.Context) {
 		for _, sampler := range t.samplers {
 			sampler.ProcessTrades([]event.Trade{msg})
 		}
+		for _, sampler := range t.footprints {
+			sampler.ProcessTrade(msg)
+		}
+		for _, sampler := range t.cvds {
+			sampler.ProcessTrade(msg)
+		}
+		for _, bars := range t.activity {
+			bars.sampler.ProcessTrade(msg)
+		}
+	case event.Tick:
+		// The venues stamp the trades, give the last ones of an interval
+		// some time to arrive before it is closed.
+		now := time.Now().Add(-closeDelay).Unix()
+		for _, sampler := range t.samplers {
+			sampler.Advance(now)
+		}
+		for _, sampler := range t.footprints {
+			sampler.Flush(now)
+		}
+		for _, sampler := range t.cvds {
+			sampler.Advance(now)
+		}
+		t.expireActivity(time.Now())
+	case event.BarSub:
+		key := barKey{bar: event.BarTypeOf(msg.Stream), param: msg.Param}
+		bars, ok := t.activity[key]
+		if !ok {
+			bars = &activityBars{
+				sampler: NewActivitySampler(t.pair, key.bar, key.param, settings.TickSize(t.pair), t.onActivityBar),
+			}
+			t.activity[key] = bars
+		}
+		bars.subs++
+	case event.BarUnsub:
+		key := barKey{bar: event.BarTypeOf(msg.Stream), param: msg.Param}
+		if bars, ok := t.activity[key]; ok && bars.subs > 0 {
+			bars.subs--
+			if bars.subs == 0 {
+				bars.idleSince = time.Now()
+			}
+		}
+	case event.Backfill:
+		switch bar := event.BarTypeOf(msg.Stream); {
+		case msg.Stream == event.StreamFootprint:
+			act.Backfill(c, t.publishPID, msg, t.footprintHistory[msg.Timeframe].Last(msg.Count))
+		case msg.Stream == event.StreamCVD:
+			act.Backfill(c, t.publishPID, msg, t.cvdHistory[msg.Timeframe].Last(msg.Count))
+		case bar != event.BarTime:
+			key := barKey{bar: bar, param: msg.Timeframe}
+			act.Backfill(c, t.publishPID, msg, t.activityHistory[key].Last(msg.Count))
+		case msg.Stream == event.StreamClosedCandles:
+			act.Backfill(c, t.publishPID, msg, closedCandles(t.history[msg.Timeframe].Last(settings.History.Candles)))
+		default:
+			act.Backfill(c, t.publishPID, msg, t.history[msg.Timeframe].Last(msg.Count))
+		}
+	}
+}
+
+// expireActivity drops the activity bars and their history once they had no
+// subscribers for activityIdleTimeout.
+func (t *Trade) expireActivity(now time.Time) {
+	for key, bars := range t.activity {
+		if bars.subs == 0 && now.Sub(bars.idleSince) > activityIdleTimeout {
+			delete(t.activity, key)
+			delete(t.activityHistory, key)
+		}
+	}
+}
+
+func closedCandles(candles []event.Candle) []event.Candle {
+	closed := make([]event.Candle, 0, len(candles))
+	for _, candle := range candles {
+		if candle.Closed {
+			closed = append(closed, candle)
+		}
 	}
+	return closed
+}
+
+// addHistory adds the message to the history of k, which keeps the last
+// capacity intervals.
+func addHistory[K comparable, T any](histories map[K]*act.History[T], k K, capacity int, key int64, msg T) {
+	history, ok := histories[k]
+	if !ok {
+		history = act.NewHistory[T](capacity)
+		histories[k] = history
+	}
+	history.Add(key, msg)
 }
 
 func (t *Trade) onCandle(candle event.Candle) {
+	addHistory(t.history, candle.Timeframe, settings.History.Candles, candle.Unix, candle)
+	t.ctx.Send(t.publishPID, candle)
+}
+
+func (t *Trade) onFootprint(footprint event.Footprint) {
+	addHistory(t.footprintHistory, footprint.Timeframe, settings.History.Footprints, footprint.Unix, footprint)
+	t.ctx.Send(t.publishPID, footprint)
+}
+
+func (t *Trade) onActivityBar(candle event.Candle) {
+	key := barKey{bar: candle.Bar, param: candle.Timeframe}
+	addHistory(t.activityHistory, key, settings.History.Candles, candle.Seq, candle)
 	t.ctx.Send(t.publishPID, candle)
 }
 
+func (t *Trade) onCVD(bar event.CVD) {
+	addHistory(t.cvdHistory, bar.Timeframe, settings.History.Candles, bar.Unix, bar)
+	t.ctx.Send(t.publishPID, bar)
+}
+
+// CandleSampler builds the candles of a timeframe from the trades. Besides
+// the trades it is driven by a timer through Advance, so intervals without
+// trades become flat candles and every candle is emitted once more with
+// Closed set when its interval is over.
 type CandleSampler struct {
-	timeframe  int64
+	pair      event.Pair
+	timeframe int64
+	// the candle in progress, nil until the first trade
 	candle     *event.Candle
 	handleFunc func(event.Candle)
 }
 
-func NewCandleSampler(timeframe int64, fn func(c event.Candle)) *CandleSampler {
+func NewCandleSampler(pair event.Pair, timeframe int64, fn func(c event.Candle)) *CandleSampler {
 	return &CandleSampler{
+		pair:       pair,
 		timeframe:  timeframe,
-		candle:     &event.Candle{},
 		handleFunc: fn,
 	}
 }
 
 func (s *CandleSampler) ProcessTrades(trades []event.Trade) {
 	for _, trade := range trades {
-		unix := trade.Unix / 1000 / s.timeframe * s.timeframe
-		if s.candle.Unix > 0 && s.candle.Unix+s.timeframe <= unix {
+		unix := trade.Unix / 1000
+		if s.candle == nil {
 			s.candle = &event.Candle{
-				Open:      trade.Price,
+				Pair:      s.pair,
 				Timeframe: s.timeframe,
+				Unix:      unix / s.timeframe * s.timeframe,
 			}
 		}
-		if s.candle.Unix == 0 {
-			s.candle.Unix = unix
-			s.candle.Timeframe = s.timeframe
-		}
-		s.candle.Close = trade.Price
-		s.candle.High = math.Max(trade.Price, s.candle.High)
-		s.candle.Low = math.Min(trade.Price, s.candle.Low)
-		if s.candle.Open == 0 {
-			s.candle.Open = trade.Price
-		}
-		if s.candle.Low == 0 {
-			s.candle.Low = trade.Price
+		s.Advance(unix)
+
+		candle := s.candle
+		if candle.Tbuy+candle.Tsell == 0 {
+			// The first trade replaces the prices of a flat candle.
+			candle.Open = trade.Price
+			candle.High = trade.Price
+			candle.Low = trade.Price
 		}
+		candle.Close = trade.Price
+		candle.High = math.Max(trade.Price, candle.High)
+		candle.Low = math.Min(trade.Price, candle.Low)
 		// TODO: round this
 		if trade.IsBuy {
-			s.candle.Vbuy = s.candle.Vbuy + trade.Qty
-			s.candle.Tbuy++
+			candle.Vbuy = candle.Vbuy + trade.Qty
+			candle.Tbuy++
 		} else {
-			s.candle.Vsell = s.candle.Vsell + trade.Qty
-			s.candle.Tsell++
+			candle.Vsell = candle.Vsell + trade.Qty
+			candle.Tsell++
 		}
 
-		candle := event.Candle{
-			Pair:      trade.Pair,
-			Open:      s.candle.Open,
-			Close:     s.candle.Close,
-			High:      s.candle.High,
-			Low:       s.candle.Low,
-			Timeframe: s.candle.Timeframe,
-			Vbuy:      s.candle.Vbuy,
-			Vsell:     s.candle.Vsell,
-			Unix:      s.candle.Unix,
-			Tbuy:      s.candle.Tbuy,
-			Tsell:     s.candle.Tsell,
-		}
+		s.handleFunc(*candle)
+	}
+}
+
+// Advance closes the candle in progress once the given unix time in seconds
+// is past its interval. The intervals in between are emitted as closed flat
+// candles at the last close, and a flat candle is started for the interval
+// of unix.
+func (s *CandleSampler) Advance(unix int64) {
+	if s.candle == nil {
+		return
+	}
+	start := unix / s.timeframe * s.timeframe
+	if s.candle.Unix+s.timeframe > start {
+		return
+	}
+
+	closed := *s.candle
+	closed.Closed = true
+	s.handleFunc(closed)
+
+	next := closed.Unix + s.timeframe
+	if skip := (start-next)/s.timeframe - int64(settings.History.Candles); skip > 0 {
+		// Nobody keeps more candles than that, don't emit what would be
+		// dropped anyway.
+		next += skip * s.timeframe
+	}
+	for ; next < start; next += s.timeframe {
+		s.handleFunc(s.flat(next, closed.Close, true))
+	}
+
+	candle := s.flat(start, closed.Close, false)
+	s.candle = &candle
+	s.handleFunc(candle)
+}
 
-		s.handleFunc(candle)
+func (s *CandleSampler) flat(unix int64, price float64, closed bool) event.Candle {
+	return event.Candle{
+		Pair:      s.pair,
+		Timeframe: s.timeframe,
+		Unix:      unix,
+		Open:      price,
+		Close:     price,
+		High:      price,
+		Low:       price,
+		Closed:    closed,
 	}
 }
@@ -0,0 +1,107 @@
+package trade
+
+import (
+	"reflect"
+	"testing"
+
+	"marketmonkey/event"
+)
+
+// samplerStep is a trade at unix in milliseconds, or a timer tick at unix in
+// seconds when advance is set.
+type samplerStep struct {
+	unix    int64
+	price   float64
+	isBuy   bool
+	advance bool
+}
+
+func TestCandleSamplerAdvance(t *testing.T) {
+	// bar is the unix time, close and trade count of a closed candle
+	type bar struct {
+		unix   int64
+		close  float64
+		trades float64
+	}
+	tests := []struct {
+		name   string
+		steps  []samplerStep
+		closed []bar
+		// the unix time of the candle in progress
+		open int64
+	}{
+		{
+			name:  "within the interval",
+			steps: []samplerStep{{unix: 60_000, price: 10}, {unix: 119_000, price: 11}, {unix: 119, advance: true}},
+			open:  60,
+		},
+		{
+			name:   "closed by a trade",
+			steps:  []samplerStep{{unix: 60_000, price: 10}, {unix: 120_000, price: 11}},
+			closed: []bar{{60, 10, 1}},
+			open:   120,
+		},
+		{
+			name:   "closed by the timer",
+			steps:  []samplerStep{{unix: 60_000, price: 10}, {unix: 120, advance: true}},
+			closed: []bar{{60, 10, 1}},
+			open:   120,
+		},
+		{
+			name:   "gap filled by the timer",
+			steps:  []samplerStep{{unix: 60_000, price: 10}, {unix: 245, advance: true}},
+			closed: []bar{{60, 10, 1}, {120, 10, 0}, {180, 10, 0}},
+			open:   240,
+		},
+		{
+			name:   "gap filled by a trade",
+			steps:  []samplerStep{{unix: 60_000, price: 10}, {unix: 61_000, price: 12}, {unix: 180_000, price: 11}},
+			closed: []bar{{60, 12, 2}, {120, 12, 0}},
+			open:   180,
+		},
+		{
+			name:   "flat candle traded",
+			steps:  []samplerStep{{unix: 60_000, price: 10}, {unix: 120, advance: true}, {unix: 130_000, price: 9}, {unix: 180, advance: true}},
+			closed: []bar{{60, 10, 1}, {120, 9, 1}},
+			open:   180,
+		},
+	}
+	for _, tt := range tests {
+		var closed []bar
+		var last event.Candle
+		s := NewCandleSampler(event.Pair{}, 60, func(c event.Candle) {
+			if c.Closed {
+				closed = append(closed, bar{c.Unix, c.Close, c.Tbuy + c.Tsell})
+			} else {
+				last = c
+			}
+		})
+		for _, step := range tt.steps {
+			if step.advance {
+				s.Advance(step.unix)
+			} else {
+				s.ProcessTrades([]event.Trade{{Unix: step.unix, Price: step.price, Qty: 1, IsBuy: step.isBuy}})
+			}
+		}
+		if !reflect.DeepEqual(closed, tt.closed) {
+			t.Errorf("%s: closed %v, want %v", tt.name, closed, tt.closed)
+		}
+		if last.Unix != tt.open {
+			t.Errorf("%s: open candle at %v, want %v", tt.name, last.Unix, tt.open)
+		}
+	}
+}
+
+func TestCandleSamplerFlatPrices(t *testing.T) {
+	var last event.Candle
+	s := NewCandleSampler(event.Pair{}, 60, func(c event.Candle) { last = c })
+	s.ProcessTrades([]event.Trade{{Unix: 60_000, Price: 10, Qty: 1}})
+	s.Advance(120)
+	if last.Open != 10 || last.High != 10 || last.Low != 10 || last.Closed {
+		t.Errorf("flat candle = %+v, want open, high and low at 10", last)
+	}
+	s.ProcessTrades([]event.Trade{{Unix: 121_000, Price: 8, Qty: 1}})
+	if last.Open != 8 || last.High != 8 || last.Low != 8 {
+		t.Errorf("first trade of a flat candle = %+v, want open, high and low at 8", last)
+	}
+}
@@ -0,0 +1,120 @@
+package vwap
+
+import (
+	"math"
+	"time"
+
+	act "marketmonkey/actor"
+	"marketmonkey/event"
+	"marketmonkey/settings"
+
+	"github.com/anthdm/hollywood/actor"
+)
+
+// sum sums up the trades of one resolution interval, the volume, the volume
+// times the price and times the price squared.
+type sum struct {
+	volume float64
+	pv     float64
+	p2v    float64
+}
+
+func (s *sum) add(other *sum) {
+	s.volume += other.volume
+	s.pv += other.pv
+	s.p2v += other.p2v
+}
+
+// VWAP keeps the traded volume of a pair in slices of
+// settings.VWAP.Resolution, so the volume weighted average price and its
+// deviation from any anchor within the retention can be summed up on
+// request. The chart, alerts and strategies all read the same values.
+type VWAP struct {
+	pair   event.Pair
+	slices *act.Slices[*sum]
+}
+
+func New(pair event.Pair) actor.Producer {
+	return func() actor.Receiver {
+		return &VWAP{
+			pair: pair,
+			slices: act.NewSlices(settings.VWAP.Resolution, settings.VWAP.Retention, func() *sum {
+				return &sum{}
+			}),
+		}
+	}
+}
+
+func (v *VWAP) Receive(c *actor.Context) {
+	switch msg := c.Message().(type) {
+	case event.Trade:
+		v.add(msg)
+	case event.VWAPRequest:
+		c.Respond(v.vwap(msg, time.Now().Unix()))
+	}
+}
+
+func (v *VWAP) add(trade event.Trade) {
+	s, ok := v.slices.At(trade.Unix / 1000)
+	if !ok {
+		return
+	}
+	s.volume += trade.Qty
+	s.pv += trade.Price * trade.Qty
+	s.p2v += trade.Price * trade.Price * trade.Qty
+}
+
+func (v *VWAP) vwap(req event.VWAPRequest, now int64) event.VWAP {
+	step := max(req.Step, v.slices.Resolution())
+	to := req.To
+	if to == 0 {
+		to = now + 1
+	}
+	msg := event.VWAP{
+		Pair:    v.pair,
+		From:    req.From,
+		To:      req.To,
+		Step:    step,
+		Session: req.Session,
+	}
+
+	slices := v.slices.Since(req.From)
+	if len(slices) == 0 {
+		return msg
+	}
+	// Nothing is kept before the first slice.
+	from := max(req.From, slices[0].Unix)
+
+	var total sum
+	session := int64(-1)
+	reset := func(unix int64) {
+		if req.Session && unix/settings.SessionLength != session {
+			session = unix / settings.SessionLength
+			total = sum{}
+		}
+	}
+	i := 0
+	for unix := from / step * step; unix < to; unix += step {
+		reset(unix)
+		for ; i < len(slices) && slices[i].Unix < unix+step; i++ {
+			reset(slices[i].Unix)
+			total.add(slices[i].Value)
+		}
+		if total.volume == 0 {
+			continue
+		}
+		mean := total.pv / total.volume
+		variance := max(total.p2v/total.volume-mean*mean, 0)
+		msg.Points = append(msg.Points, event.VWAPPoint{
+			Unix:   unix,
+			VWAP:   mean,
+			StdDev: math.Sqrt(variance),
+		})
+	}
+	return msg
+}
+
+// Request asks the vwap actor of the given pair for the VWAP.
+func Request(e *actor.Engine, pair event.Pair, req event.VWAPRequest) (event.VWAP, error) {
+	return act.Request[event.VWAP](e, act.GetVWAPPID(pair), req)
+}
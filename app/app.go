@@ -20,6 +20,7 @@ type App struct {
 
 	contentContainer *widget.Container
 	engine           *actor.Engine
+	feeds            *feedHealth
 }
 
 func New(e *actor.Engine) *App {
@@ -48,6 +49,7 @@ func New(e *actor.Engine) *App {
 		},
 		contentContainer: content,
 		engine:           e,
+		feeds:            newFeedHealth(e),
 	}
 
 	root.AddChild(NewMenuBarWidget(), content, NewStatusBarWidget())
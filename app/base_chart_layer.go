@@ -7,6 +7,7 @@ import (
 	"math"
 	"time"
 
+	"marketmonkey/actor/history"
 	"marketmonkey/actor/session"
 	"marketmonkey/event"
 	"marketmonkey/settings"
@@ -17,15 +18,32 @@ import (
 	"golang.org/x/image/colornames"
 )
 
+// How long a failed history request waits before it is retried.
+const historyRetryInterval = 5 * time.Second
+
 type BaseChartLayer struct {
 	sessionPID *actor.PID
 	eventCh    chan any
 	candles    []event.Candle
+	// the Heikin-Ashi candles of the candles up to the last update
+	heikinAshi []event.Candle
 	isDirty    bool
 	image      *ebiten.Image
 	triImage   *ebiten.Image
 	visible    bool
 
+	// a history request is in flight
+	loadingHistory bool
+	// the venue has no older candles
+	historyDone bool
+	// when a failed history request is retried, zero unless one failed
+	retryAt time.Time
+	// bars that were prepended since the last update
+	prepended int
+	// the latest history candle when the history came before the live
+	// candles, the live updates of it are merged into it
+	mergeUnix int64
+
 	chart *ChartWidget
 }
 
@@ -42,33 +60,152 @@ func NewBaseChartLayer(chart *ChartWidget) *BaseChartLayer {
 	}
 	l.chart = chart
 
-	streams := []session.Stream{{
-		Stream:    event.StreamCandles,
-		Timeframe: chart.interval,
-	}}
-
 	l.chart.intervalChangeEvent.AddHandler(l.onIntervalChange)
+	l.chart.barTypeChangeEvent.AddHandler(l.onBarTypeChange)
 	l.chart.chartTypeChangeEvent.AddHandler(l.onChartTypeChange)
 
-	l.sessionPID = app.engine.Spawn(session.New(l.eventCh, l.chart.pair, streams), "session")
+	l.sessionPID = app.engine.Spawn(session.New(l.eventCh, l.chart.pair, l.streams()), "session")
+	l.requestHistory(0)
 
 	go l.receiveData()
 
 	return l
 }
 
+// streams returns the candle stream of the interval or the activity bars of
+// the chart.
+func (l *BaseChartLayer) streams() []session.Stream {
+	if l.chart.barType != event.BarTime {
+		return []session.Stream{{
+			Stream:    l.chart.barType.Stream(),
+			Timeframe: l.chart.barParam,
+			Backfill:  settings.History.Candles,
+		}}
+	}
+	return []session.Stream{{
+		Stream:    event.StreamCandles,
+		Timeframe: l.chart.interval,
+		Backfill:  settings.History.Candles,
+	}}
+}
+
+// requestHistory asks for the candles before end, zero for the latest ones.
+// The venues only have the history of the time based candles.
+func (l *BaseChartLayer) requestHistory(end int64) {
+	if l.loadingHistory || l.historyDone || l.chart.barType != event.BarTime || time.Now().Before(l.retryAt) {
+		return
+	}
+	l.loadingHistory = true
+	l.retryAt = time.Time{}
+	history.Request(app.engine, event.HistoryRequest{
+		Pair:      l.chart.pair,
+		Timeframe: l.chart.interval,
+		End:       end,
+		Count:     settings.History.Candles,
+	}, l.sessionPID)
+}
+
+// mergeHistory prepends the history to the live candles. The candle both
+// have is merged, the live ones win for the later ones.
+func (l *BaseChartLayer) mergeHistory(msg event.HistoryCandles) {
+	if msg.Timeframe != l.chart.interval || l.chart.barType != event.BarTime {
+		return
+	}
+	l.loadingHistory = false
+	if msg.Failed {
+		l.retryAt = time.Now().Add(historyRetryInterval)
+		return
+	}
+	candles := msg.Candles
+	if len(l.candles) > 0 {
+		first := l.candles[0]
+		i := 0
+		for i < len(candles) && candles[i].Unix < first.Unix {
+			i++
+		}
+		if i < len(candles) && candles[i].Unix == first.Unix {
+			l.candles[0] = mergeCandle(candles[i], first)
+		}
+		candles = candles[:i]
+		if n := len(candles); n > 0 {
+			price := candles[n-1].Close
+			for unix := candles[n-1].Unix + msg.Timeframe; unix < first.Unix; unix += msg.Timeframe {
+				candles = append(candles, event.Candle{
+					Pair:      first.Pair,
+					Timeframe: msg.Timeframe,
+					Unix:      unix,
+					Open:      price,
+					Close:     price,
+					High:      price,
+					Low:       price,
+					Closed:    true,
+				})
+			}
+		}
+	}
+	if len(candles) == 0 {
+		l.historyDone = true
+		return
+	}
+	if len(l.candles) == 0 {
+		l.mergeUnix = candles[len(candles)-1].Unix
+		l.chart.lastUnix = candles[len(candles)-1].Unix
+		l.chart.lastPrice = candles[len(candles)-1].Close
+	} else {
+		l.prepended += len(candles)
+	}
+	l.candles = append(candles, l.candles...)
+	l.isDirty = true
+}
+
+// mergeCandle merges the history and the live candle of the interval the
+// chart was opened in. Both cover its end but start at different times, the
+// larger volume is the closest to the truth without counting trades twice.
+func mergeCandle(history, live event.Candle) event.Candle {
+	live.Open = history.Open
+	live.High = max(live.High, history.High)
+	live.Low = min(live.Low, history.Low)
+	live.Vbuy = max(live.Vbuy, history.Vbuy)
+	live.Vsell = max(live.Vsell, history.Vsell)
+	live.Tbuy = max(live.Tbuy, history.Tbuy)
+	live.Tsell = max(live.Tsell, history.Tsell)
+	return live
+}
+
 func (l *BaseChartLayer) update(chart *ChartWidget) {
 	if l.image == nil {
 		l.image = ebiten.NewImage(chart.GetWidget().Rect.Dx(), chart.GetWidget().Rect.Dy())
 		l.image.Fill(color.RGBA{R: 0, G: 0, B: 0, A: 0})
 	}
+	if !l.retryAt.IsZero() && time.Now().After(l.retryAt) {
+		end := int64(0)
+		if len(l.candles) > 0 {
+			end = l.candles[0].Unix
+		}
+		l.requestHistory(end)
+	}
 	if len(l.candles) == 0 {
 		return
 	}
+	if l.prepended > 0 {
+		// Keep the view on the same candles.
+		chart.barOffset += float64(l.prepended)
+		l.prepended = 0
+	}
+	if chart.barOffset < 0 {
+		l.requestHistory(l.candles[0].Unix)
+	}
 	if chart.startTime.Unix() != l.candles[0].Unix {
 		fmt.Println("chart start is not in sync with the first candle")
 		chart.startTime = time.Unix(l.candles[0].Unix, 0)
 	}
+	if chart.barType != event.BarTime {
+		chart.firstSeq = l.candles[0].Seq
+		chart.barTimes = chart.barTimes[:0]
+		for _, c := range l.candles {
+			chart.barTimes = append(chart.barTimes, c.Unix)
+		}
+	}
 }
 
 func (l *BaseChartLayer) renderUpdate(chart *ChartWidget) {
@@ -77,7 +214,17 @@ func (l *BaseChartLayer) renderUpdate(chart *ChartWidget) {
 	}
 	l.isDirty = false
 
-	if chart.chartType == chartTypeCandles {
+	if chart.chartType == chartTypeHeikinAshi {
+		l.heikinAshi = l.heikinAshi[:0]
+		for i, c := range l.candles {
+			var prev *event.Candle
+			if i > 0 {
+				prev = &l.heikinAshi[i-1]
+			}
+			l.heikinAshi = append(l.heikinAshi, heikinAshi(prev, c))
+		}
+	}
+	if isCandleChart(chart.chartType) {
 		l.renderVolumes(chart)
 		l.renderCandlesticks(chart)
 	}
@@ -96,7 +243,8 @@ func (l *BaseChartLayer) renderCandlesticks(chart *ChartWidget) {
 	visibleStartBar := chart.barOffset
 	visibleEndBar := visibleStartBar + visibleBars
 	startIndex := int(max(0, visibleStartBar))
-	endIndex := min(len(l.candles)-2, int(visibleEndBar))
+	candles := l.displayCandles(chart)
+	endIndex := min(len(candles)-2, int(visibleEndBar))
 
 	// If no visible data, skip
 	if startIndex > endIndex {
@@ -150,77 +298,29 @@ func (l *BaseChartLayer) renderCandlesticks(chart *ChartWidget) {
 		idxCount += 4
 	}
 
-	clip := func(val float32) float32 {
-		if val < minY {
-			val = minY
-		} else if val > maxY {
-			val = maxY
-		}
-		return val
+	// clippedRect clamps the rects of the candles to the image.
+	clippedRect := func(x, y, w, h float32, col color.Color) {
+		right, bottom := min(x+w, maxX), min(y+h, maxY)
+		x, y = max(x, minX), max(y, minY)
+		addRect(x, y, right-x, bottom-y, col)
 	}
 
 	for i := startIndex; i <= endIndex; i++ {
-		c := l.candles[i]
+		c := candles[i]
 		// Convert candle’s time → bar index
-		barIndex := float64(chart.getBarIndex(c.Unix))
+		barIndex := float64(chart.getCandleIndex(c))
 
-		// The raw left/right in pixels
+		// The raw left in pixels
 		left := minX + float32((barIndex-chart.barOffset)*chart.barWidth)
-		right := left + candleWidth
-
-		// Partially clamp horizontally
-		clippedLeft := float32(math.Max(float64(left), float64(minX)))
-		clippedRight := float32(math.Min(float64(right), float64(maxX)))
-		width := clippedRight - clippedLeft
-		if width <= 0 {
-			// Entirely offscreen horizontally
+		if left+candleWidth < minX || left > maxX {
 			continue
 		}
 
-		// Compute candle Y’s
-		openY := chart.getPriceY(c.Open)
-		closeY := chart.getPriceY(c.Close)
-		highY := chart.getPriceY(c.High)
-		lowY := chart.getPriceY(c.Low)
-
-		// Candle color
-		col := settings.CandleStickGreen
-		if c.Close < c.Open {
-			col = settings.CandleStickRed
-		}
-
-		// Top/bottom numeric values (remember Ebiten’s Y grows downward)
-		topY := float32(math.Min(float64(openY), float64(closeY)))
-		botY := float32(math.Max(float64(openY), float64(closeY)))
-
-		// Clamp wicks & body vertically
-		highY = clip(highY) // clip() ensures within [minY, maxY]
-		lowY = clip(lowY)
-		topY = clip(topY)
-		botY = clip(botY)
-
-		bodyHeight := botY - topY
-		if bodyHeight < 1 {
-			bodyHeight = 1
-		}
-
-		// Draw body using clamped horizontal coords
-		addRect(clippedLeft, topY, width, bodyHeight, col)
-
-		// The center for the wick is the horizontal midpoint of the visible body
-		midX := left + candleWidth*0.5
-		wickX := midX - 0.5
-
-		if wickX < maxX {
-			// Top wick
-			if topY > highY {
-				addRect(wickX, highY, 1, topY-highY, col)
-			}
-			// Bottom wick
-			if botY < lowY {
-				addRect(wickX, botY, 1, lowY-botY, col)
-			}
+		prevClose := c.Open
+		if i > 0 {
+			prevClose = candles[i-1].Close
 		}
+		drawCandle(chart.chartType, c, prevClose, left, candleWidth, chart.getPriceY, clippedRect)
 	}
 
 	if len(vertices) > 0 && len(indices) > 0 {
@@ -241,8 +341,8 @@ func (l *BaseChartLayer) renderLines(chart *ChartWidget) {
 		t1 := l.candles[i]
 		t2 := l.candles[i+1]
 
-		barIndex1 := float64(chart.getBarIndex(t1.Unix))
-		barIndex2 := float64(chart.getBarIndex(t2.Unix))
+		barIndex1 := float64(chart.getCandleIndex(t1))
+		barIndex2 := float64(chart.getCandleIndex(t2))
 
 		// Calculate screen coordinates
 		x1 := minX + float32((barIndex1-chart.barOffset)*chart.barWidth)
@@ -311,7 +411,7 @@ func (l *BaseChartLayer) render(screen *ebiten.Image, chart *ChartWidget) {
 		l.image.Clear()
 		l.renderUpdate(chart)
 	}
-	if chart.chartType == chartTypeCandles {
+	if isCandleChart(chart.chartType) {
 		l.renderLastCandle(screen, chart)
 	}
 	if chart.chartType == chartTypeLine {
@@ -354,7 +454,7 @@ func (l *BaseChartLayer) renderVolumes(chart *ChartWidget) {
 
 	for i := startIndex; i <= endIndex; i++ {
 		c := l.candles[i]
-		barIndex := float64(chart.getBarIndex(c.Unix))
+		barIndex := float64(chart.getCandleIndex(c))
 		left := minX + float32((barIndex-chart.barOffset)*chart.barWidth)
 
 		barHeight := maxY * settings.VolumeBarHeightPerc
@@ -376,8 +476,13 @@ func (l *BaseChartLayer) renderVolumes(chart *ChartWidget) {
 func (l *BaseChartLayer) receiveData() {
 	for ev := range l.eventCh {
 		switch msg := ev.(type) {
+		case event.HistoryCandles:
+			l.mergeHistory(msg)
 		case event.Candle:
-			if l.chart.lastUnix+l.chart.interval <= msg.Unix || len(l.candles) == 0 {
+			if n := len(l.candles); n > 0 && msg.Unix == l.mergeUnix && l.candles[n-1].Unix == msg.Unix {
+				msg = mergeCandle(l.candles[n-1], msg)
+			}
+			if len(l.candles) == 0 || l.isNewBar(msg) {
 				l.candles = append(l.candles, msg)
 				l.chart.lastUnix = msg.Unix
 				l.isDirty = true
@@ -389,6 +494,14 @@ func (l *BaseChartLayer) receiveData() {
 	fmt.Println("stopped receive data loop")
 }
 
+// isNewBar reports whether the candle starts a bar after the last one.
+func (l *BaseChartLayer) isNewBar(msg event.Candle) bool {
+	if msg.Bar != event.BarTime {
+		return msg.Seq > l.candles[len(l.candles)-1].Seq
+	}
+	return l.chart.lastUnix+l.chart.interval <= msg.Unix
+}
+
 func (l *BaseChartLayer) renderLastLine(screen *ebiten.Image, chart *ChartWidget) {
 	rect := chart.screen.GetWidget().Rect
 	minX := float64(rect.Min.X)
@@ -399,8 +512,8 @@ func (l *BaseChartLayer) renderLastLine(screen *ebiten.Image, chart *ChartWidget
 	if len(l.candles) > 1 {
 		c1 := l.candles[len(l.candles)-2]
 		c2 := l.candles[len(l.candles)-1]
-		prevBarIndex := float64(chart.getBarIndex(c1.Unix))
-		currentBarIndex := float64(chart.getBarIndex(c2.Unix))
+		prevBarIndex := float64(chart.getCandleIndex(c1))
+		currentBarIndex := float64(chart.getCandleIndex(c2))
 
 		visibleBars := float64(rect.Dx()) / chart.barWidth
 		visibleStartBar := chart.barOffset
@@ -432,17 +545,28 @@ func (l *BaseChartLayer) onIntervalChange(interval any) {
 		log.Fatal("interval changed failed cast", interval)
 		return
 	}
+	l.resubscribe()
+}
+
+func (l *BaseChartLayer) onBarTypeChange(_ any) {
+	l.resubscribe()
+}
+
+// resubscribe starts over with the candles of the current interval or
+// activity bars of the chart.
+func (l *BaseChartLayer) resubscribe() {
 	app.engine.Poison(l.sessionPID)
 
 	l.eventCh = make(chan any)
-	streams := []session.Stream{{
-		Stream:    event.StreamCandles,
-		Timeframe: l.chart.interval,
-	}}
-
-	l.sessionPID = app.engine.Spawn(session.New(l.eventCh, l.chart.pair, streams), "session")
+	l.sessionPID = app.engine.Spawn(session.New(l.eventCh, l.chart.pair, l.streams()), "session")
 	l.candles = []event.Candle{}
 	l.isDirty = true
+	l.loadingHistory = false
+	l.historyDone = false
+	l.retryAt = time.Time{}
+	l.prepended = 0
+	l.mergeUnix = 0
+	l.requestHistory(0)
 
 	go l.receiveData()
 }
@@ -456,49 +580,103 @@ func (l *BaseChartLayer) renderLastCandle(screen *ebiten.Image, chart *ChartWidg
 
 	i := len(l.candles) - 1
 	c := l.candles[i]
-	barIndex := chart.getBarIndex(c.Unix)
+	prevClose := c.Open
+	if i > 0 {
+		prevClose = l.candles[i-1].Close
+	}
+	// The live bar follows the Heikin-Ashi candles of the last update.
+	if chart.chartType == chartTypeHeikinAshi && i > 0 && i <= len(l.heikinAshi) {
+		prev := l.heikinAshi[i-1]
+		c = heikinAshi(&prev, c)
+		prevClose = prev.Close
+	}
+	barIndex := chart.getCandleIndex(c)
 	x := minX + float32((float64(barIndex)-chart.barOffset)*chart.barWidth)
 
-	openY := chart.getPriceYScreen(c.Open)
-	closeY := chart.getPriceYScreen(c.Close)
-	highY := chart.getPriceYScreen(c.High)
-	lowY := chart.getPriceYScreen(c.Low)
+	// Skip drawing if completely off-screen horizontally
+	if x+candleWidth < minX || x > maxX {
+		return
+	}
+
+	drawCandle(chart.chartType, c, prevClose, x, candleWidth, chart.getPriceYScreen, func(x, y, w, h float32, col color.Color) {
+		right, bottom := min(x+w, maxX), min(y+h, maxY)
+		x, y = max(x, minX), max(y, minY)
+		if right > x && bottom > y {
+			vector.DrawFilledRect(screen, x, y, right-x, bottom-y, col, false)
+		}
+	})
+}
+
+// displayCandles returns the candles as the chart type draws them.
+func (l *BaseChartLayer) displayCandles(chart *ChartWidget) []event.Candle {
+	if chart.chartType == chartTypeHeikinAshi {
+		return l.heikinAshi
+	}
+	return l.candles
+}
 
-	// Determine candle color
+// isCandleChart reports whether the chart type draws a shape per candle.
+func isCandleChart(t chartType) bool {
+	switch t {
+	case chartTypeCandles, chartTypeHeikinAshi, chartTypeHollow, chartTypeOHLC:
+		return true
+	}
+	return false
+}
+
+// heikinAshi returns the Heikin-Ashi candle of c, prev is the Heikin-Ashi
+// candle before it or nil for the first one.
+func heikinAshi(prev *event.Candle, c event.Candle) event.Candle {
+	ha := c
+	ha.Close = (c.Open + c.High + c.Low + c.Close) / 4
+	ha.Open = (c.Open + c.Close) / 2
+	if prev != nil {
+		ha.Open = (prev.Open + prev.Close) / 2
+	}
+	ha.High = max(c.High, ha.Open, ha.Close)
+	ha.Low = min(c.Low, ha.Open, ha.Close)
+	return ha
+}
+
+// drawCandle draws the candle c in the style of the chart type as rects,
+// left and width are the horizontal bounds and y maps prices to pixels.
+// Hollow candles are colored by the close before them and filled when
+// they close below their open.
+func drawCandle(t chartType, c event.Candle, prevClose float64, left, width float32, y func(float64) float32, rect func(x, y, w, h float32, col color.Color)) {
 	col := settings.CandleStickGreen
-	if c.Close < c.Open {
+	if t == chartTypeHollow {
+		if c.Close < prevClose {
+			col = settings.CandleStickRed
+		}
+	} else if c.Close < c.Open {
 		col = settings.CandleStickRed
 	}
 
-	// Body top/bottom
-	topY := float32(math.Min(float64(openY), float64(closeY)))
-	botY := float32(math.Max(float64(openY), float64(closeY)))
+	openY, closeY := y(c.Open), y(c.Close)
+	highY, lowY := y(c.High), y(c.Low)
+	topY, botY := min(openY, closeY), max(openY, closeY)
+	wickX := left + width*0.5 - 0.5
 
-	// Skip drawing if completely off-screen horizontally
-	if x+candleWidth < minX || x > maxX {
+	if t == chartTypeOHLC {
+		rect(wickX, highY, 1, max(lowY-highY, 1), col)
+		rect(left, openY, wickX-left, 1, col)
+		rect(wickX+1, closeY, left+width-wickX-1, 1, col)
 		return
 	}
 
-	// Clamp x coordinates
-	if x < minX {
-		x = minX
+	bodyHeight := max(botY-topY, 1)
+	if t == chartTypeHollow && c.Close >= c.Open {
+		rect(left, topY, width, 1, col)
+		rect(left, topY+bodyHeight-1, width, 1, col)
+		rect(left, topY, 1, bodyHeight, col)
+		rect(left+width-1, topY, 1, bodyHeight, col)
+	} else {
+		rect(left, topY, width, bodyHeight, col)
 	}
-	rightX := x + candleWidth
-	if rightX > maxX {
-		rightX = maxX
+	if topY > highY {
+		rect(wickX, highY, 1, topY-highY, col)
 	}
-
-	// Clamp y coordinates
-	clip := func(val float32) float32 {
-		return float32(math.Max(float64(minY), math.Min(float64(val), float64(maxY))))
+	if botY < lowY {
+		rect(wickX, botY, 1, lowY-botY, col)
 	}
-	highY, lowY = clip(highY), clip(lowY)
-	topY, botY = clip(topY), clip(botY)
-
-	bodyHeight := botY - topY
-	vector.DrawFilledRect(screen, x, topY, rightX-x, bodyHeight, col, false)
-
-	midX := float32(math.Min(float64(x+candleWidth*0.5), float64(maxX)))
-	vector.StrokeLine(screen, midX, highY, midX, topY, 1, col, false)
-	vector.StrokeLine(screen, midX, botY, midX, lowY, 1, col, false)
 }
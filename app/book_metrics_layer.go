@@ -0,0 +1,69 @@
+package app
+
+import (
+	"marketmonkey/actor/session"
+	"marketmonkey/event"
+	"marketmonkey/settings"
+
+	"github.com/anthdm/hollywood/actor"
+	"github.com/hajimehoshi/ebiten/v2"
+)
+
+// The amount of samples kept, a day at the default sampling interval.
+const bookMetricsMaxPoints = 86400
+
+// BookMetricsLayer plots the book imbalance of the pair in a pane below the
+// chart.
+type BookMetricsLayer struct {
+	pair       event.Pair
+	eventCh    chan any
+	sessionPID *actor.PID
+	points     []panePoint
+}
+
+func NewBookMetricsLayer(pair event.Pair) *BookMetricsLayer {
+	eventCh := make(chan any)
+	streams := []session.Stream{{
+		Stream: event.StreamBookMetrics,
+	}}
+	pid := app.engine.Spawn(session.New(eventCh, pair, streams), "session")
+
+	layer := &BookMetricsLayer{
+		pair:       pair,
+		eventCh:    eventCh,
+		sessionPID: pid,
+		points:     []panePoint{},
+	}
+
+	go layer.receiveData()
+
+	return layer
+}
+
+func (l *BookMetricsLayer) receiveData() {
+	for ev := range l.eventCh {
+		switch msg := ev.(type) {
+		case event.BookMetrics:
+			if len(l.points) == bookMetricsMaxPoints {
+				l.points = append(l.points[:0], l.points[1:]...)
+			}
+			l.points = append(l.points, panePoint{Unix: msg.Unix / 1000, Value: msg.Imbalance})
+		}
+	}
+}
+
+func (l *BookMetricsLayer) initialize(_ *ChartWidget) {}
+
+func (l *BookMetricsLayer) update(_ *ChartWidget) {}
+
+func (l *BookMetricsLayer) render(screen *ebiten.Image, chart *ChartWidget) {
+	renderPane(screen, chart, chart.paneRect(l), l.paneLabel(), l.points, settings.ChartPaneLineColor)
+}
+
+func (l *BookMetricsLayer) paneLabel() string {
+	return "Imbalance"
+}
+
+func (l *BookMetricsLayer) delete() {
+	app.engine.Poison(l.sessionPID)
+}
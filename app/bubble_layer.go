@@ -0,0 +1,265 @@
+package app
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+
+	"marketmonkey/actor/session"
+	"marketmonkey/event"
+	"marketmonkey/pkg/ring"
+	"marketmonkey/settings"
+
+	"github.com/anthdm/hollywood/actor"
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/text/v2"
+	"github.com/hajimehoshi/ebiten/v2/vector"
+)
+
+// The amount of trades kept, the oldest are dropped first.
+const bubbleMaxTrades = 50000
+
+// bubbleKey addresses the bubble of a bar and a price row.
+type bubbleKey struct {
+	index int64
+	row   int64
+}
+
+type bubble struct {
+	buy, sell float64
+	notional  float64
+}
+
+// bubbleView is what the bubbles depend on besides the trades, they are
+// summed up again once any of it changes.
+type bubbleView struct {
+	// the unix time in milliseconds of the first bar on the screen
+	from     int64
+	start    int64
+	interval int64
+	barType  event.BarType
+	firstSeq int64
+	bars     int
+	grouping int64
+	merge    bool
+}
+
+// TradeBubbleLayer draws the trades as circles at their bar and price,
+// sized by their volume on a log scale and colored by the aggressor side.
+// The trades are summed up per bar and price row of the chart grouping,
+// the rows below the minimum notional are left out. With merging on, the
+// fills of the same millisecond, side and venue count as one trade at their
+// average price, so a sweep shows up as one bubble.
+type TradeBubbleLayer struct {
+	pair       event.Pair
+	eventCh    chan any
+	sessionPID *actor.PID
+	tickSize   float64
+
+	// the minimum notional of a bubble in quote currency
+	minSize float64
+	merge   bool
+
+	mu     sync.Mutex
+	trades *ring.Buffer[event.Trade]
+	// the amount of trades received and of them summed up in sums
+	received int
+	summed   int
+	// the bubbles of the view, only the render touches them
+	view bubbleView
+	sums map[bubbleKey]*bubble
+	// the last merged fill, it is in the sums and grows with the next
+	// trades of its millisecond
+	fill *event.Trade
+}
+
+func NewTradeBubbleLayer(pair event.Pair, minSize float64, merge bool) *TradeBubbleLayer {
+	eventCh := make(chan any)
+	streams := []session.Stream{{
+		Stream: event.StreamTrades,
+	}}
+	pid := app.engine.Spawn(session.New(eventCh, pair, streams), "session")
+
+	layer := &TradeBubbleLayer{
+		pair:       pair,
+		eventCh:    eventCh,
+		sessionPID: pid,
+		tickSize:   settings.TickSize(pair),
+		minSize:    minSize,
+		merge:      merge,
+		trades:     ring.NewBuffer[event.Trade](bubbleMaxTrades),
+	}
+
+	go layer.receiveData()
+
+	return layer
+}
+
+func (l *TradeBubbleLayer) receiveData() {
+	for ev := range l.eventCh {
+		switch msg := ev.(type) {
+		case event.Trade:
+			l.mu.Lock()
+			l.trades.Push(msg)
+			l.received++
+			l.mu.Unlock()
+		}
+	}
+}
+
+func (l *TradeBubbleLayer) initialize(_ *ChartWidget) {}
+
+func (l *TradeBubbleLayer) update(_ *ChartWidget) {}
+
+// bubbles returns the trades on the screen summed up per bar and price row
+// with the minimum size they are filtered by. The sums are kept, only the
+// trades received since the last call are added to them unless the view
+// changed. The returned bubbles must not be modified.
+func (l *TradeBubbleLayer) bubbles(chart *ChartWidget) (map[bubbleKey]*bubble, float64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	view := bubbleView{
+		from:     chart.getBarUnixAtX(float64(chart.GetWidget().Rect.Min.X)) * 1000,
+		start:    chart.startTime.Unix(),
+		interval: chart.interval,
+		barType:  chart.barType,
+		firstSeq: chart.firstSeq,
+		bars:     len(chart.barTimes),
+		grouping: chart.grouping,
+		merge:    l.merge,
+	}
+	n := l.trades.Len()
+	var first int
+	if view != l.view || l.sums == nil || l.received-l.summed > n {
+		l.view = view
+		l.sums = make(map[bubbleKey]*bubble)
+		l.fill = nil
+		first = sort.Search(n, func(i int) bool {
+			return l.trades.At(i).Unix >= view.from
+		})
+	} else {
+		first = n - (l.received - l.summed)
+	}
+	l.summed = l.received
+
+	rowHeight := float64(max(chart.grouping, 1)) * l.tickSize
+	add := func(trade event.Trade, sign float64) {
+		key := bubbleKey{
+			index: chart.getBarIndex(trade.Unix / 1000),
+			row:   int64(math.Floor(trade.Price / rowHeight)),
+		}
+		b, ok := l.sums[key]
+		if !ok {
+			b = &bubble{}
+			l.sums[key] = b
+		}
+		if trade.IsBuy {
+			b.buy += sign * trade.Qty
+		} else {
+			b.sell += sign * trade.Qty
+		}
+		b.notional += sign * trade.Qty * trade.Price
+	}
+
+	for i := first; i < n; i++ {
+		trade := l.trades.At(i)
+		if trade.Unix < view.from {
+			continue
+		}
+		if !l.merge {
+			add(trade, 1)
+			continue
+		}
+		if fill := l.fill; fill != nil && fill.Unix == trade.Unix && fill.IsBuy == trade.IsBuy && fill.Venue == trade.Venue {
+			// The merged fill moves to its new average price.
+			add(*fill, -1)
+			qty := fill.Qty + trade.Qty
+			fill.Price = (fill.Price*fill.Qty + trade.Price*trade.Qty) / qty
+			fill.Qty = qty
+			add(*fill, 1)
+			continue
+		}
+		l.fill = &trade
+		add(trade, 1)
+	}
+	return l.sums, l.minSize
+}
+
+func (l *TradeBubbleLayer) render(screen *ebiten.Image, chart *ChartWidget) {
+	if chart.startTime.IsZero() {
+		return
+	}
+	rect := chart.GetWidget().Rect
+	dst := screen.SubImage(rect).(*ebiten.Image)
+	rowHeight := float64(max(chart.grouping, 1)) * l.tickSize
+
+	bubbles, minSize := l.bubbles(chart)
+	maxVolume := 0.0
+	for _, b := range bubbles {
+		if b.notional >= minSize {
+			maxVolume = max(maxVolume, b.buy+b.sell)
+		}
+	}
+	if maxVolume == 0 {
+		return
+	}
+
+	maxRadius := min(settings.TradeBubbleMaxRadius, float32(chart.barWidth)*2)
+	for key, b := range bubbles {
+		if b.notional < minSize {
+			continue
+		}
+		x := float32(rect.Min.X) + float32((float64(key.index)-chart.barOffset)*chart.barWidth+chart.barWidth/2)
+		y := chart.getPriceYScreen((float64(key.row) + 0.5) * rowHeight)
+		volume := b.buy + b.sell
+		r := max(maxRadius*float32(math.Log1p(volume)/math.Log1p(maxVolume)), 2*settings.Scale)
+		if x+r < float32(rect.Min.X) || x-r > float32(rect.Max.X) || y+r < float32(rect.Min.Y) || y-r > float32(rect.Max.Y) {
+			continue
+		}
+		col := settings.TradeBubbleBuyColor
+		if b.sell > b.buy {
+			col = settings.TradeBubbleSellColor
+		}
+		vector.DrawFilledCircle(dst, x, y, r, withAlpha(col, 140), true)
+		vector.StrokeCircle(dst, x, y, r, 1, col, true)
+		if r >= 10*settings.Scale {
+			label := formatVolume(volume)
+			w, h := text.Measure(label, settings.FontSM, 0)
+			DrawText(dst, label, settings.FontSM, float64(x)-w/2, float64(y)-h/2, settings.FootprintTextColor)
+		}
+	}
+}
+
+func (l *TradeBubbleLayer) setMinSize(minSize float64) {
+	l.mu.Lock()
+	l.minSize = minSize
+	l.mu.Unlock()
+}
+
+func (l *TradeBubbleLayer) setMerge(merge bool) {
+	l.mu.Lock()
+	l.merge = merge
+	l.mu.Unlock()
+}
+
+func (l *TradeBubbleLayer) delete() {
+	app.engine.Poison(l.sessionPID)
+}
+
+// bubbleLabel returns the label of a minimum bubble size, a negative size
+// turns the bubbles off.
+func bubbleLabel(minSize float64) string {
+	switch {
+	case minSize < 0:
+		return "TB off"
+	case minSize == 0:
+		return "TB all"
+	case minSize >= 1000000:
+		return fmt.Sprintf("TB $%gM", minSize/1000000)
+	case minSize >= 1000:
+		return fmt.Sprintf("TB $%gK", minSize/1000)
+	}
+	return fmt.Sprintf("TB $%g", minSize)
+}
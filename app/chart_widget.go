@@ -5,6 +5,7 @@ import (
 	img "image"
 	"image/color"
 	"math"
+	"sort"
 	"time"
 
 	evt "marketmonkey/event"
@@ -31,6 +32,10 @@ type chartType int
 const (
 	chartTypeCandles chartType = iota
 	chartTypeLine
+	chartTypeFootprint
+	chartTypeHeikinAshi
+	chartTypeHollow
+	chartTypeOHLC
 )
 
 type ChartWidget struct {
@@ -67,15 +72,40 @@ type ChartWidget struct {
 	barOffset    float64
 	interval     int64
 
+	// the activity bars shown instead of the time based candles, BarTime
+	// for the candles of the interval
+	barType  evt.BarType
+	barParam int64
+	// the activity bars are indexed by sequence, barTimes holds the unix
+	// time of each bar from firstSeq on
+	barTimes []int64
+	firstSeq int64
+
 	// last dragged pos
 	lastPos img.Point
 
 	intervalChangeEvent  *event.Event
 	chartTypeChangeEvent *event.Event
+	groupingChangeEvent  *event.Event
+	barTypeChangeEvent   *event.Event
+	// fired with the unix time of the bar clicked with the anchor button
+	anchorEvent *event.Event
+
+	// price grouping in ticks of the heatmap
+	grouping int64
+
+	normChangeEvent *event.Event
+	normalization   heatmapNormalization
 
-	// the chart type of the base layer (candles, line, heiken)
+	// the chart type of the base layer (candles, line, heikin-ashi, hollow,
+	// ohlc or footprint)
 	chartType chartType
 	baseLayer *BaseChartLayer
+
+	// price bucket in ticks of the footprint chart
+	footprintBucket int64
+	// the footprints of the footprint chart type, nil for the other types
+	footprints *FootprintLayer
 }
 
 func NewChartWidget(pair evt.Pair, interval int64) *ChartWidget {
@@ -93,6 +123,12 @@ func NewChartWidget(pair evt.Pair, interval int64) *ChartWidget {
 		barOffset:            -10,
 		intervalChangeEvent:  &event.Event{},
 		chartTypeChangeEvent: &event.Event{},
+		groupingChangeEvent:  &event.Event{},
+		barTypeChangeEvent:   &event.Event{},
+		anchorEvent:          &event.Event{},
+		grouping:             settings.DefaultPriceGrouping,
+		normChangeEvent:      &event.Event{},
+		footprintBucket:      settings.DefaultFootprintBucket,
 		pair:                 pair,
 	}
 	rootContainer := widget.NewContainer(
@@ -167,6 +203,39 @@ func (chart *ChartWidget) AddLayer(layers ...layer) {
 	}
 }
 
+// RemoveLayer deletes the given layer from the chart.
+func (chart *ChartWidget) RemoveLayer(l layer) {
+	for i, layer := range chart.layers {
+		if layer == l {
+			chart.layers = append(chart.layers[:i], chart.layers[i+1:]...)
+			l.delete()
+			chart.isDirty = true
+			return
+		}
+	}
+}
+
+// paneRect returns the screen rect of the pane of the given layer. Panes are
+// stacked from the bottom of the chart in the order they were added.
+func (chart *ChartWidget) paneRect(l paneLayer) img.Rectangle {
+	rect := chart.GetWidget().Rect
+	height := int(settings.ChartPaneHeight)
+	bottom := rect.Max.Y
+	for _, layer := range chart.layers {
+		if layer == l {
+			break
+		}
+		if _, ok := layer.(paneLayer); ok {
+			bottom -= height
+		}
+	}
+	return img.Rect(rect.Min.X, max(rect.Min.Y, bottom-height), rect.Max.X, bottom)
+}
+
+func (chart *ChartWidget) Pair() evt.Pair {
+	return chart.pair
+}
+
 func (chart *ChartWidget) GetWidget() *widget.Widget {
 	return chart.screen.GetWidget()
 }
@@ -221,6 +290,10 @@ func (chart *ChartWidget) onMousePressed(args *widget.WidgetMouseButtonPressedEv
 	if args.Button == settings.PanChartButton {
 		chart.isPanning = true
 	}
+	if args.Button == settings.AnchorChartButton {
+		mx, _ := ebiten.CursorPosition()
+		chart.anchorEvent.Fire(chart.getBarUnixAtX(float64(mx)))
+	}
 }
 
 func (chart *ChartWidget) onMouseReleased(args *widget.WidgetMouseButtonReleasedEventArgs) {
@@ -292,9 +365,17 @@ func (chart *ChartWidget) Render(screen *ebiten.Image) {
 	chart.renderPriceLine(screen)
 
 	for _, layer := range chart.layers {
-		layer.render(screen, chart)
+		if _, ok := layer.(paneLayer); !ok {
+			layer.render(screen, chart)
+		}
 	}
 	chart.baseLayer.render(screen, chart)
+	// Panes are drawn on top of the price area.
+	for _, layer := range chart.layers {
+		if _, ok := layer.(paneLayer); ok {
+			layer.render(screen, chart)
+		}
+	}
 	chart.isDirty = false
 }
 
@@ -407,10 +488,71 @@ func (chart *ChartWidget) getPriceAtY(y float32) float64 {
 }
 
 func (chart *ChartWidget) getBarIndex(unix int64) int64 {
+	if chart.barType != evt.BarTime {
+		// The time maps to the activity bar that was forming at it.
+		i := sort.Search(len(chart.barTimes), func(i int) bool {
+			return chart.barTimes[i] > unix
+		})
+		return int64(max(i-1, 0))
+	}
 	return int64(float64(unix-chart.startTime.Unix()) / float64(chart.interval))
 }
 
+// getCandleIndex returns the bar index of a candle of the base layer.
+func (chart *ChartWidget) getCandleIndex(c evt.Candle) int64 {
+	if c.Bar != evt.BarTime {
+		return c.Seq - chart.firstSeq
+	}
+	return chart.getBarIndex(c.Unix)
+}
+
+// getBarUnix returns the unix time the bar with the given index starts at.
+// The activity bars after the last one have not started yet, they are at
+// the current time.
+func (chart *ChartWidget) getBarUnix(index int64) int64 {
+	if chart.barType != evt.BarTime {
+		if index >= int64(len(chart.barTimes)) {
+			return time.Now().Unix()
+		}
+		if index < 0 || len(chart.barTimes) == 0 {
+			return chart.startTime.Unix()
+		}
+		return chart.barTimes[index]
+	}
+	return chart.startTime.Unix() + index*chart.interval
+}
+
+// getNextBarUnix returns the unix time of the bar after the one at unix.
+func (chart *ChartWidget) getNextBarUnix(unix int64) int64 {
+	if chart.barType != evt.BarTime {
+		return chart.getBarUnix(chart.getBarIndex(unix) + 1)
+	}
+	return unix + chart.interval
+}
+
+// getBarUnixAtX returns the unix time of the bar at the given screen x.
+func (chart *ChartWidget) getBarUnixAtX(x float64) int64 {
+	rect := chart.GetWidget().Rect
+	index := math.Floor((x-float64(rect.Min.X))/chart.barWidth + chart.barOffset)
+	return chart.getBarUnix(int64(index))
+}
+
+// getUnixX returns the screen x of the given unix time in seconds.
+func (chart *ChartWidget) getUnixX(unix int64) float32 {
+	rect := chart.GetWidget().Rect
+	index := float64(unix-chart.startTime.Unix()) / float64(chart.interval)
+	if chart.barType != evt.BarTime {
+		index = float64(chart.getBarIndex(unix))
+	}
+	return float32(rect.Min.X) + float32((index-chart.barOffset)*chart.barWidth)
+}
+
+// getTimeAtX returns the time at the given screen x in seconds since the
+// start time. The activity bars are at the time of their start.
 func (chart *ChartWidget) getTimeAtX(x float64) float64 {
+	if chart.barType != evt.BarTime {
+		return float64(chart.getBarUnixAtX(x) - chart.startTime.Unix())
+	}
 	rect := chart.GetWidget().Rect
 	barIndex := (x-float64(rect.Min.X))/chart.barWidth + chart.barOffset
 	return float64(barIndex) * float64(chart.interval)
@@ -423,9 +565,50 @@ func (chart *ChartWidget) Close(_ *widget.WindowClosedEventArgs) {
 }
 
 func (chart *ChartWidget) onIntervalChange(interval int64) {
+	wasActivity := chart.barType != evt.BarTime
+	chart.barType, chart.barParam = evt.BarTime, 0
 	if chart.interval != interval {
 		chart.interval = interval
 		chart.intervalChangeEvent.Fire(interval)
+	} else if wasActivity {
+		chart.barTypeChangeEvent.Fire(evt.BarTime)
+	}
+}
+
+// onBarsChange switches the base layer to activity bars. The other layers
+// stay on the interval, they are placed on the bars by time.
+func (chart *ChartWidget) onBarsChange(bars settings.BarConfig) {
+	if chart.barType == bars.Bar && chart.barParam == bars.Param {
+		return
+	}
+	chart.barType, chart.barParam = bars.Bar, bars.Param
+	chart.barTimes = nil
+	chart.barTypeChangeEvent.Fire(bars.Bar)
+}
+
+// setChartType switches the type of the base layer. The footprints are a
+// layer of their own that only exists while they are shown.
+func (chart *ChartWidget) setChartType(t chartType) {
+	if chart.chartType == t {
+		return
+	}
+	if chart.footprints != nil {
+		chart.RemoveLayer(chart.footprints)
+		chart.footprints = nil
+	}
+	chart.chartType = t
+	if t == chartTypeFootprint {
+		chart.footprints = NewFootprintLayer(chart.pair)
+		chart.AddLayer(chart.footprints)
+	}
+	chart.chartTypeChangeEvent.Fire(t)
+	chart.isDirty = true
+}
+
+func (chart *ChartWidget) onGroupingChange(grouping int64) {
+	if chart.grouping != grouping {
+		chart.grouping = grouping
+		chart.groupingChangeEvent.Fire(grouping)
 	}
 }
 
@@ -442,36 +625,167 @@ func (chart *ChartWidget) Toolbar() *widget.Container {
 		),
 	)
 
-	comboBox := intervalDropdown(chart.onIntervalChange)
+	comboBox := intervalDropdown(chart.onIntervalChange, chart.onBarsChange)
 	container.AddChild(comboBox)
+	container.AddChild(groupingDropdown(chart.grouping, chart.onGroupingChange))
 
-	buttonLine := newToolbarButton("L")
-	buttonCandle := newToolbarButton("C")
-	buttonCandle.TextColor.Idle = settings.MenuButtonTextColorActive
-	buttonLine.ClickedEvent.AddHandler(func(_ any) {
-		if chart.chartType != chartTypeLine {
-			buttonLine.TextColor.Idle = settings.MenuButtonTextColorActive
-			buttonCandle.TextColor.Idle = settings.MenuButtonTextColorIdle
-			chart.chartType = chartTypeLine
-			chart.chartTypeChangeEvent.Fire(chartTypeLine)
-		} else {
-			buttonLine.TextColor.Idle = settings.MenuButtonTextColorIdle
+	normalizations := []any{}
+	for _, n := range heatmapNormalizations {
+		normalizations = append(normalizations, n)
+	}
+	label := func(e any) string {
+		return e.(heatmapNormalization).String()
+	}
+	container.AddChild(newDropdown(normalizations, chart.normalization, label, func(e any) {
+		if n := e.(heatmapNormalization); n != chart.normalization {
+			chart.normalization = n
+			chart.normChangeEvent.Fire(n)
+		}
+	}))
+
+	chartTypes := []struct {
+		label     string
+		chartType chartType
+	}{
+		{"C", chartTypeCandles},
+		{"L", chartTypeLine},
+		{"HA", chartTypeHeikinAshi},
+		{"HC", chartTypeHollow},
+		{"B", chartTypeOHLC},
+		{"F", chartTypeFootprint},
+	}
+	chartTypeButtons := make([]*widget.Button, len(chartTypes))
+	for i, t := range chartTypes {
+		button := newToolbarButton(t.label)
+		if t.chartType == chart.chartType {
+			button.TextColor.Idle = settings.MenuButtonTextColorActive
 		}
+		button.ClickedEvent.AddHandler(func(_ any) {
+			for _, other := range chartTypeButtons {
+				other.TextColor.Idle = settings.MenuButtonTextColorIdle
+			}
+			button.TextColor.Idle = settings.MenuButtonTextColorActive
+			chart.setChartType(t.chartType)
+		})
+		chartTypeButtons[i] = button
+		container.AddChild(button)
+	}
+	container.AddChild(ticksDropdown(settings.FootprintBuckets, chart.footprintBucket, func(bucket int64) {
+		chart.footprintBucket = bucket
+		chart.isDirty = true
+	}))
+
+	var showMetrics, showPulls, showIcebergs, showWalls bool
+	chart.addLayerToggle(container, "M", &showMetrics, func() layer {
+		return NewBookMetricsLayer(chart.pair)
+	})
+	chart.addLayerToggle(container, "P", &showPulls, func() layer {
+		return NewLiquidityLayer(chart.pair)
+	})
+	chart.addLayerToggle(container, "I", &showIcebergs, func() layer {
+		return NewIcebergLayer(chart.pair)
+	})
+	chart.addLayerToggle(container, "W", &showWalls, func() layer {
+		return NewWallLayer(chart.pair)
 	})
-	buttonCandle.ClickedEvent.AddHandler(func(_ any) {
-		if chart.chartType != chartTypeCandles {
-			buttonCandle.TextColor.Idle = settings.MenuButtonTextColorActive
-			buttonLine.TextColor.Idle = settings.MenuButtonTextColorIdle
-			chart.chartType = chartTypeCandles
-			chart.chartTypeChangeEvent.Fire(chartTypeCandles)
-		} else {
-			buttonCandle.TextColor.Idle = settings.MenuButtonTextColorIdle
+
+	chart.addLayerDropdown(container, []any{profileOff, profileSession, profileVisible, profileFixed}, profileOff, func(mode any) layer {
+		return NewVolumeProfileLayer(chart.pair, mode.(profileMode))
+	})
+	chart.addLayerDropdown(container, []any{cvdOff, cvdSession, cvdContinuous}, cvdOff, func(mode any) layer {
+		return NewCVDLayer(chart.pair, mode.(cvdMode))
+	})
+	chart.addLayerDropdown(container, []any{vwapOff, vwapSession, vwapAnchored}, vwapOff, func(mode any) layer {
+		return NewVWAPLayer(chart.pair, mode.(vwapMode))
+	})
+
+	var bubbles *TradeBubbleLayer
+	bubbleMerge := settings.TradeBubbleMerge
+	// A negative minimum size turns the bubbles off.
+	bubbleSizes := []any{-1.0}
+	for _, size := range settings.TradeBubbleMinSizes {
+		bubbleSizes = append(bubbleSizes, size)
+	}
+	container.AddChild(newDropdown(bubbleSizes, -1.0, func(e any) string {
+		return bubbleLabel(e.(float64))
+	}, func(e any) {
+		switch size := e.(float64); {
+		case size < 0:
+			if bubbles != nil {
+				chart.RemoveLayer(bubbles)
+				bubbles = nil
+			}
+		case bubbles != nil:
+			bubbles.setMinSize(size)
+		default:
+			bubbles = NewTradeBubbleLayer(chart.pair, size, bubbleMerge)
+			chart.AddLayer(bubbles)
+		}
+	}))
+	buttonMerge := newToolbarButton("ms")
+	if bubbleMerge {
+		buttonMerge.TextColor.Idle = settings.MenuButtonTextColorActive
+	}
+	buttonMerge.ClickedEvent.AddHandler(func(_ any) {
+		bubbleMerge = !bubbleMerge
+		buttonMerge.TextColor.Idle = settings.MenuButtonTextColorIdle
+		if bubbleMerge {
+			buttonMerge.TextColor.Idle = settings.MenuButtonTextColorActive
+		}
+		if bubbles != nil {
+			bubbles.setMerge(bubbleMerge)
 		}
 	})
-	container.AddChild(buttonCandle, buttonLine)
+	container.AddChild(buttonMerge)
 	return container
 }
 
+// addLayerToggle adds a button that shows the layer made by newLayer on the
+// chart and removes it again on the next click, enabled tells which of both.
+func (chart *ChartWidget) addLayerToggle(container *widget.Container, label string, enabled *bool, newLayer func() layer) {
+	var current layer
+	button := newToolbarButton(label)
+	set := func(on bool) {
+		*enabled = on
+		if current != nil {
+			chart.RemoveLayer(current)
+			current = nil
+		}
+		button.TextColor.Idle = settings.MenuButtonTextColorIdle
+		if on {
+			current = newLayer()
+			chart.AddLayer(current)
+			button.TextColor.Idle = settings.MenuButtonTextColorActive
+		}
+	}
+	if *enabled {
+		set(true)
+	}
+	button.ClickedEvent.AddHandler(func(_ any) {
+		set(!*enabled)
+	})
+	container.AddChild(button)
+}
+
+// addLayerDropdown adds a dropdown of the modes of a layer. Picking a mode
+// replaces the layer by the one newLayer makes for it, off removes it.
+func (chart *ChartWidget) addLayerDropdown(container *widget.Container, modes []any, off any, newLayer func(mode any) layer) {
+	var current layer
+	label := func(e any) string {
+		return e.(fmt.Stringer).String()
+	}
+	container.AddChild(newDropdown(modes, off, label, func(e any) {
+		if current != nil {
+			chart.RemoveLayer(current)
+			current = nil
+		}
+		if e != off {
+			current = newLayer(e)
+			chart.AddLayer(current)
+		}
+	}))
+}
+
 func pickTimeInterval(secondsVisible float64) time.Duration {
 	steps := []time.Duration{
 		time.Second,
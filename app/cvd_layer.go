@@ -0,0 +1,220 @@
+package app
+
+import (
+	"fmt"
+	"image/color"
+	"math"
+	"sync"
+
+	"marketmonkey/actor/session"
+	"marketmonkey/event"
+	"marketmonkey/settings"
+
+	"github.com/anthdm/hollywood/actor"
+	evt "github.com/ebitenui/ebitenui/event"
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/vector"
+)
+
+type cvdMode int
+
+const (
+	cvdOff cvdMode = iota
+	// the delta resets at the start of every session
+	cvdSession
+	// the delta since the stream started
+	cvdContinuous
+)
+
+func (m cvdMode) String() string {
+	switch m {
+	case cvdSession:
+		return "CVD session"
+	case cvdContinuous:
+		return "CVD"
+	}
+	return "CVD off"
+}
+
+// CVDLayer plots the cumulative volume delta of the chart timeframe in a
+// pane below the chart, as candles or as a line following the chart type.
+// On aggregated pairs the spot and perp deltas are drawn as lines as well.
+type CVDLayer struct {
+	pair       event.Pair
+	mode       cvdMode
+	eventCh    chan any
+	sessionPID *actor.PID
+
+	removeIntervalHandler evt.RemoveHandlerFunc
+
+	mu   sync.Mutex
+	bars []event.CVD
+}
+
+func NewCVDLayer(pair event.Pair, mode cvdMode) *CVDLayer {
+	return &CVDLayer{
+		pair: pair,
+		mode: mode,
+	}
+}
+
+func (l *CVDLayer) subscribe(interval int64) {
+	l.eventCh = make(chan any)
+	streams := []session.Stream{{
+		Stream:    event.StreamCVD,
+		Timeframe: interval,
+		Backfill:  settings.History.Candles,
+	}}
+	l.sessionPID = app.engine.Spawn(session.New(l.eventCh, l.pair, streams), "session")
+
+	l.mu.Lock()
+	l.bars = nil
+	l.mu.Unlock()
+
+	go l.receiveData(l.eventCh)
+}
+
+func (l *CVDLayer) receiveData(eventCh chan any) {
+	for ev := range eventCh {
+		switch msg := ev.(type) {
+		case event.CVD:
+			l.mu.Lock()
+			if n := len(l.bars); n > 0 && l.bars[n-1].Unix == msg.Unix {
+				l.bars[n-1] = msg
+			} else {
+				if n == settings.History.Candles {
+					l.bars = append(l.bars[:0], l.bars[1:]...)
+				}
+				l.bars = append(l.bars, msg)
+			}
+			l.mu.Unlock()
+		}
+	}
+}
+
+func (l *CVDLayer) initialize(chart *ChartWidget) {
+	l.subscribe(chart.interval)
+	l.removeIntervalHandler = chart.intervalChangeEvent.AddHandler(func(interval any) {
+		app.engine.Poison(l.sessionPID)
+		l.subscribe(interval.(int64))
+	})
+}
+
+func (l *CVDLayer) update(_ *ChartWidget) {}
+
+// cvdBar is a bar of the delta in the mode of the layer.
+type cvdBar struct {
+	index                  int64
+	open, high, low, close float64
+	spot, perp             float64
+}
+
+func (l *CVDLayer) render(screen *ebiten.Image, chart *ChartWidget) {
+	rect := chart.paneRect(l)
+	renderPaneBackground(screen, rect)
+
+	visibleBars := float64(rect.Dx()) / chart.barWidth
+	aggregated := l.pair.Exchange == settings.Aggregated
+	bars := []cvdBar{}
+	minValue, maxValue := math.Inf(1), math.Inf(-1)
+	l.mu.Lock()
+	for _, msg := range l.bars {
+		index := chart.getBarIndex(msg.Unix)
+		if float64(index) < chart.barOffset-1 || float64(index) > chart.barOffset+visibleBars+1 {
+			continue
+		}
+		bar := cvdBar{
+			index: index,
+			open:  msg.Open,
+			high:  msg.High,
+			low:   msg.Low,
+			close: msg.Close,
+			spot:  msg.Spot,
+			perp:  msg.Perp,
+		}
+		if l.mode == cvdSession {
+			bar.open -= msg.SessionStart
+			bar.high -= msg.SessionStart
+			bar.low -= msg.SessionStart
+			bar.close -= msg.SessionStart
+			bar.spot -= msg.SpotSessionStart
+			bar.perp -= msg.PerpSessionStart
+		}
+		bars = append(bars, bar)
+		minValue = math.Min(minValue, bar.low)
+		maxValue = math.Max(maxValue, bar.high)
+		if aggregated {
+			minValue = math.Min(minValue, math.Min(bar.spot, bar.perp))
+			maxValue = math.Max(maxValue, math.Max(bar.spot, bar.perp))
+		}
+	}
+	l.mu.Unlock()
+
+	labelX := float64(rect.Min.X) + float64(settings.PanelPadding)
+	labelY := float64(rect.Min.Y) + 4
+	if len(bars) == 0 {
+		DrawText(screen, l.paneLabel(), settings.FontSM, labelX, labelY, settings.ChartPaneLineColor)
+		return
+	}
+	last := bars[len(bars)-1]
+	label := fmt.Sprintf("%s %s", l.paneLabel(), formatVolume(last.close))
+	DrawText(screen, label, settings.FontSM, labelX, labelY, settings.ChartPaneLineColor)
+	if aggregated {
+		DrawText(screen, "spot "+formatVolume(last.spot), settings.FontSM, labelX+120, labelY, settings.CVDSpotColor)
+		DrawText(screen, "perp "+formatVolume(last.perp), settings.FontSM, labelX+220, labelY, settings.CVDPerpColor)
+	}
+	if maxValue == minValue {
+		maxValue++
+		minValue--
+	}
+
+	padding := float32(rect.Dy()) * 0.1
+	height := float32(rect.Dy()) - 2*padding
+	y := func(value float64) float32 {
+		return float32(rect.Max.Y) - padding - float32((value-minValue)/(maxValue-minValue))*height
+	}
+	x := func(index int64) float32 {
+		return float32(rect.Min.X) + float32((float64(index)-chart.barOffset)*chart.barWidth)
+	}
+	if minValue < 0 && maxValue > 0 {
+		DrawDashedLine(screen, float32(rect.Min.X), y(0), float32(rect.Max.X), y(0), 0.5, 5, 5, settings.PanelDividerColor, true)
+	}
+
+	center := float32(chart.barWidth / 2)
+	line := func(value func(cvdBar) float64, col color.Color) {
+		for i := 1; i < len(bars); i++ {
+			x1, x2 := x(bars[i-1].index)+center, x(bars[i].index)+center
+			vector.StrokeLine(screen, x1, y(value(bars[i-1])), x2, y(value(bars[i])), float32(settings.LineChartStrokeWidth), col, true)
+		}
+	}
+	if chart.chartType == chartTypeLine {
+		line(func(b cvdBar) float64 { return b.close }, settings.ChartPaneLineColor)
+	} else {
+		width := float32(chart.barWidth * 0.7)
+		for _, bar := range bars {
+			col := settings.CandleStickGreen
+			if bar.close < bar.open {
+				col = settings.CandleStickRed
+			}
+			bx := x(bar.index) + (float32(chart.barWidth)-width)/2
+			vector.StrokeLine(screen, x(bar.index)+center, y(bar.high), x(bar.index)+center, y(bar.low), 1, col, false)
+			top, bottom := y(math.Max(bar.open, bar.close)), y(math.Min(bar.open, bar.close))
+			vector.DrawFilledRect(screen, bx, top, width, max(bottom-top, 1), col, false)
+		}
+	}
+	if aggregated {
+		line(func(b cvdBar) float64 { return b.spot }, settings.CVDSpotColor)
+		line(func(b cvdBar) float64 { return b.perp }, settings.CVDPerpColor)
+	}
+}
+
+func (l *CVDLayer) paneLabel() string {
+	return l.mode.String()
+}
+
+func (l *CVDLayer) delete() {
+	if l.removeIntervalHandler != nil {
+		l.removeIntervalHandler()
+	}
+	app.engine.Poison(l.sessionPID)
+}
@@ -0,0 +1,282 @@
+package app
+
+import (
+	"fmt"
+	img "image"
+	"image/color"
+	"math"
+
+	"marketmonkey/actor/session"
+	"marketmonkey/event"
+	"marketmonkey/settings"
+
+	"github.com/anthdm/hollywood/actor"
+	"github.com/ebitenui/ebitenui/image"
+	"github.com/ebitenui/ebitenui/widget"
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/vector"
+	"golang.org/x/exp/shiny/materialdesign/colornames"
+)
+
+type depthPoint struct {
+	price    float64
+	sum      float64
+	notional float64
+}
+
+// DepthWidget draws the cumulative size of the bids and asks around the mid
+// of the book as the classic depth chart.
+type DepthWidget struct {
+	*widget.Container
+
+	pair       event.Pair
+	eventCh    chan any
+	sessionPID *actor.PID
+	depth      event.Depth
+	vertImg    *ebiten.Image
+
+	// range around the mid in percent
+	rangePerc float64
+	logScale  bool
+}
+
+func NewDepthWidget(pair event.Pair) *DepthWidget {
+	eventCh := make(chan any)
+	streams := []session.Stream{{
+		Stream: event.StreamDepth,
+	}}
+	pid := app.engine.Spawn(session.New(eventCh, pair, streams), "session")
+
+	container := widget.NewContainer(
+		widget.ContainerOpts.BackgroundImage(
+			image.NewNineSliceColor(settings.PanelBackgroundColor),
+		),
+		widget.ContainerOpts.Layout(widget.NewAnchorLayout()),
+		widget.ContainerOpts.WidgetOpts(
+			widget.WidgetOpts.LayoutData(widget.AnchorLayoutData{
+				StretchHorizontal: true,
+				StretchVertical:   true,
+			}),
+		),
+	)
+
+	vertImg := ebiten.NewImage(1, 1)
+	vertImg.Fill(color.White)
+
+	w := &DepthWidget{
+		Container:  container,
+		pair:       pair,
+		eventCh:    eventCh,
+		sessionPID: pid,
+		vertImg:    vertImg,
+		rangePerc:  settings.DefaultDepthRange,
+	}
+
+	go w.receiveData()
+
+	return w
+}
+
+func (w *DepthWidget) receiveData() {
+	for ev := range w.eventCh {
+		switch msg := ev.(type) {
+		case event.Depth:
+			w.depth = msg
+		}
+	}
+}
+
+// cumulate sums the levels from the mid outwards until the price leaves the
+// visible range.
+func cumulate(levels []event.BookEntry, inRange func(float64) bool) []depthPoint {
+	points := make([]depthPoint, 0, len(levels))
+	sum, notional := 0.0, 0.0
+	for _, level := range levels {
+		if !inRange(level.Price) {
+			break
+		}
+		sum += level.Size
+		notional += level.Size * level.Price
+		points = append(points, depthPoint{price: level.Price, sum: sum, notional: notional})
+	}
+	return points
+}
+
+func (w *DepthWidget) Render(screen *ebiten.Image) {
+	w.Container.Render(screen)
+
+	depth := w.depth
+	if depth.Mid == 0 {
+		return
+	}
+
+	rect := w.GetWidget().Rect
+	padding := int(settings.PanelPadding)
+	labelHeight := int(20 * settings.Scale)
+	area := img.Rect(rect.Min.X+padding, rect.Min.Y+padding+labelHeight, rect.Max.X-padding, rect.Max.Y-padding-labelHeight)
+	if area.Dx() <= 0 || area.Dy() <= 0 {
+		return
+	}
+
+	band := depth.Mid * w.rangePerc / 100
+	minPrice, maxPrice := depth.Mid-band, depth.Mid+band
+	bids := cumulate(depth.Bids, func(price float64) bool { return price >= minPrice })
+	asks := cumulate(depth.Asks, func(price float64) bool { return price <= maxPrice })
+
+	maxSum := 0.0
+	if len(bids) > 0 {
+		maxSum = bids[len(bids)-1].sum
+	}
+	if len(asks) > 0 {
+		maxSum = max(maxSum, asks[len(asks)-1].sum)
+	}
+	if maxSum == 0 {
+		return
+	}
+
+	x := func(price float64) float32 {
+		return float32(area.Min.X) + float32((price-minPrice)/(maxPrice-minPrice))*float32(area.Dx())
+	}
+	y := func(sum float64) float32 {
+		ratio := sum / maxSum
+		if w.logScale {
+			ratio = math.Log10(sum+1) / math.Log10(maxSum+1)
+		}
+		return float32(area.Max.Y) - float32(ratio)*float32(area.Dy())
+	}
+
+	w.drawCurve(screen, bids, x, y, float32(area.Min.X), float32(area.Max.Y), settings.Green)
+	w.drawCurve(screen, asks, x, y, float32(area.Max.X), float32(area.Max.Y), settings.Red)
+
+	midX := x(depth.Mid)
+	DrawDashedLine(screen, midX, float32(area.Min.Y), midX, float32(area.Max.Y), 0.5, 5, 5, colornames.Blue100, true)
+
+	labelY := float64(area.Max.Y) + 4
+	DrawText(screen, fmt.Sprintf("%.2f", minPrice), settings.FontSM, float64(area.Min.X), labelY, color.White)
+	DrawText(screen, fmt.Sprintf("%.2f", depth.Mid), settings.FontSM, float64(midX)-20, labelY, color.White)
+	DrawText(screen, fmt.Sprintf("%.2f", maxPrice), settings.FontSM, float64(area.Max.X)-60, labelY, color.White)
+	DrawText(screen, fmt.Sprintf("%.2f", maxSum), settings.FontSM, float64(area.Min.X), float64(rect.Min.Y+padding), color.White)
+
+	w.renderHover(screen, area, bids, asks, minPrice, maxPrice, depth.Mid)
+}
+
+// drawCurve draws the cumulated levels as a filled step curve that closes at
+// the given edge of the chart.
+func (w *DepthWidget) drawCurve(screen *ebiten.Image, points []depthPoint, x func(float64) float32, y func(float64) float32, edgeX, baseY float32, col color.RGBA) {
+	if len(points) == 0 {
+		return
+	}
+	var path vector.Path
+	path.MoveTo(x(points[0].price), baseY)
+	prevY := baseY
+	for _, point := range points {
+		px := x(point.price)
+		path.LineTo(px, prevY)
+		prevY = y(point.sum)
+		path.LineTo(px, prevY)
+	}
+	path.LineTo(edgeX, prevY)
+	path.LineTo(edgeX, baseY)
+	path.Close()
+
+	vertices, indices := path.AppendVerticesAndIndicesForFilling(nil, nil)
+	for i := range vertices {
+		vertices[i].SrcX = 0.5
+		vertices[i].SrcY = 0.5
+		vertices[i].ColorR = float32(col.R) / 255
+		vertices[i].ColorG = float32(col.G) / 255
+		vertices[i].ColorB = float32(col.B) / 255
+		vertices[i].ColorA = 0.25
+	}
+	screen.DrawTriangles(vertices, indices, w.vertImg, &ebiten.DrawTrianglesOptions{AntiAlias: true})
+
+	vertices, indices = path.AppendVerticesAndIndicesForStroke(nil, nil, &vector.StrokeOptions{Width: float32(settings.LineChartStrokeWidth)})
+	for i := range vertices {
+		vertices[i].SrcX = 0.5
+		vertices[i].SrcY = 0.5
+		vertices[i].ColorR = float32(col.R) / 255
+		vertices[i].ColorG = float32(col.G) / 255
+		vertices[i].ColorB = float32(col.B) / 255
+		vertices[i].ColorA = 1
+	}
+	screen.DrawTriangles(vertices, indices, w.vertImg, &ebiten.DrawTrianglesOptions{AntiAlias: true})
+}
+
+// renderHover shows the cumulative size and notional from the mid up to the
+// price under the cursor.
+func (w *DepthWidget) renderHover(screen *ebiten.Image, area img.Rectangle, bids, asks []depthPoint, minPrice, maxPrice, mid float64) {
+	mx, my := ebiten.CursorPosition()
+	if !img.Pt(mx, my).In(area) {
+		return
+	}
+	price := minPrice + float64(mx-area.Min.X)/float64(area.Dx())*(maxPrice-minPrice)
+
+	var hovered depthPoint
+	points := asks
+	if price < mid {
+		points = bids
+	}
+	for _, point := range points {
+		if (price < mid && point.price < price) || (price >= mid && point.price > price) {
+			break
+		}
+		hovered = point
+	}
+
+	vector.StrokeLine(screen, float32(mx), float32(area.Min.Y), float32(mx), float32(area.Max.Y), 1, settings.ChartCrossHairColor, false)
+	label := fmt.Sprintf("%.2f  size %.2f  notional %.0f", price, hovered.sum, hovered.notional)
+	DrawText(screen, label, settings.FontSM, float64(area.Min.X)+120*float64(settings.Scale), float64(area.Min.Y)-20*float64(settings.Scale), color.White)
+}
+
+func (w *DepthWidget) Toolbar() *widget.Container {
+	container := widget.NewContainer(
+		widget.ContainerOpts.BackgroundImage(
+			image.NewNineSliceColor(settings.PanelBackgroundColor),
+		),
+		widget.ContainerOpts.Layout(widget.NewRowLayout()),
+		widget.ContainerOpts.WidgetOpts(
+			widget.WidgetOpts.LayoutData(widget.RowLayoutData{
+				Position: widget.RowLayoutPositionCenter,
+			}),
+		),
+	)
+
+	entries := []any{}
+	for _, r := range settings.DepthRanges {
+		entries = append(entries, r)
+	}
+	label := func(e any) string {
+		return fmt.Sprintf("%g%%", e.(float64))
+	}
+	container.AddChild(newDropdown(entries, w.rangePerc, label, func(e any) {
+		w.rangePerc = e.(float64)
+	}))
+
+	buttonLog := newToolbarButton("LOG")
+	buttonLog.ClickedEvent.AddHandler(func(_ any) {
+		w.logScale = !w.logScale
+		if w.logScale {
+			buttonLog.TextColor.Idle = settings.MenuButtonTextColorActive
+		} else {
+			buttonLog.TextColor.Idle = settings.MenuButtonTextColorIdle
+		}
+	})
+	container.AddChild(buttonLog)
+	return container
+}
+
+func (w *DepthWidget) PreferredSize() (int, int) {
+	return 0, 0
+}
+
+func (w *DepthWidget) Pair() event.Pair {
+	return w.pair
+}
+
+func (w *DepthWidget) GetWidget() *widget.Widget {
+	return w.Container.GetWidget()
+}
+
+func (w *DepthWidget) Close(_ *widget.WindowClosedEventArgs) {
+	app.engine.Poison(w.sessionPID)
+}
@@ -0,0 +1,105 @@
+package app
+
+import (
+	"image/color"
+	"marketmonkey/actor/session"
+	"marketmonkey/event"
+	"marketmonkey/settings"
+	"sync"
+
+	"github.com/anthdm/hollywood/actor"
+	"github.com/ebitenui/ebitenui/widget"
+	"github.com/hajimehoshi/ebiten/v2"
+	"golang.org/x/exp/shiny/materialdesign/colornames"
+)
+
+// feedHealth keeps the latest state of all feeds published by the health
+// monitor, so the status bar and the window title bars can show them.
+type feedHealth struct {
+	mu      sync.RWMutex
+	eventCh chan any
+	health  event.FeedHealth
+}
+
+func newFeedHealth(e *actor.Engine) *feedHealth {
+	f := &feedHealth{
+		eventCh: make(chan any),
+	}
+	streams := []session.Stream{{
+		Stream: event.StreamFeedHealth,
+	}}
+	e.Spawn(session.New(f.eventCh, event.NewPair(settings.Health, "feeds"), streams), "session")
+
+	go f.receiveData()
+
+	return f
+}
+
+func (f *feedHealth) receiveData() {
+	for ev := range f.eventCh {
+		switch msg := ev.(type) {
+		case event.FeedHealth:
+			f.mu.Lock()
+			f.health = msg
+			f.mu.Unlock()
+		}
+	}
+}
+
+func (f *feedHealth) venues() []event.FeedStatus {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return f.health.Venues
+}
+
+func (f *feedHealth) symbol(pair event.Pair) (event.FeedStatus, bool) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	for _, status := range f.health.Symbols {
+		if status.Pair == pair {
+			return status, true
+		}
+	}
+	return event.FeedStatus{}, false
+}
+
+func feedStateColor(state event.FeedState) color.Color {
+	switch state {
+	case event.FeedLive:
+		return settings.Green
+	case event.FeedStale:
+		return colornames.Orange300
+	default:
+		return settings.Red
+	}
+}
+
+// feedStateLabel shows the feed state of a pair in the title bar of the
+// window that displays it.
+type feedStateLabel struct {
+	*widget.Text
+
+	pair event.Pair
+}
+
+func newFeedStateLabel(pair event.Pair) *feedStateLabel {
+	return &feedStateLabel{
+		Text: widget.NewText(
+			widget.TextOpts.WidgetOpts(
+				widget.WidgetOpts.LayoutData(widget.RowLayoutData{
+					Position: widget.RowLayoutPositionCenter,
+				}),
+			),
+			widget.TextOpts.Text("", settings.FontSM, color.White),
+		),
+		pair: pair,
+	}
+}
+
+func (l *feedStateLabel) Render(screen *ebiten.Image) {
+	if status, ok := app.feeds.symbol(l.pair); ok {
+		l.Label = status.State.String()
+		l.Color = feedStateColor(status.State)
+	}
+	l.Text.Render(screen)
+}
@@ -0,0 +1,232 @@
+package app
+
+import (
+	"fmt"
+	"image/color"
+	"math"
+	"sort"
+	"sync"
+
+	"marketmonkey/actor/session"
+	"marketmonkey/event"
+	"marketmonkey/settings"
+
+	"github.com/anthdm/hollywood/actor"
+	evt "github.com/ebitenui/ebitenui/event"
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/text/v2"
+	"github.com/hajimehoshi/ebiten/v2/vector"
+)
+
+type footprintCell struct {
+	price float64
+	bid   float64
+	ask   float64
+}
+
+// FootprintLayer draws the bars of the footprint chart type. Every bar is
+// split into cells of the price bucket of the chart, showing the volume sold
+// into the bid and bought from the ask. Cells that outweigh the opposite side
+// diagonally next to them are highlighted, the cell with the most volume is
+// outlined as the POC.
+type FootprintLayer struct {
+	pair       event.Pair
+	tickSize   float64
+	eventCh    chan any
+	sessionPID *actor.PID
+
+	removeIntervalHandler evt.RemoveHandlerFunc
+
+	mu         sync.Mutex
+	footprints map[int64]event.Footprint
+	order      []int64
+}
+
+func NewFootprintLayer(pair event.Pair) *FootprintLayer {
+	return &FootprintLayer{
+		pair:     pair,
+		tickSize: settings.TickSize(pair),
+	}
+}
+
+func (l *FootprintLayer) subscribe(interval int64) {
+	l.eventCh = make(chan any)
+	streams := []session.Stream{{
+		Stream:    event.StreamFootprint,
+		Timeframe: interval,
+		Backfill:  settings.History.Footprints,
+	}}
+	l.sessionPID = app.engine.Spawn(session.New(l.eventCh, l.pair, streams), "session")
+
+	l.mu.Lock()
+	l.footprints = make(map[int64]event.Footprint)
+	l.order = nil
+	l.mu.Unlock()
+
+	go l.receiveData(l.eventCh)
+}
+
+func (l *FootprintLayer) receiveData(eventCh chan any) {
+	for ev := range eventCh {
+		switch msg := ev.(type) {
+		case event.Footprint:
+			l.mu.Lock()
+			if _, ok := l.footprints[msg.Unix]; !ok {
+				l.order = append(l.order, msg.Unix)
+				if len(l.order) > settings.History.Footprints {
+					delete(l.footprints, l.order[0])
+					l.order = l.order[1:]
+				}
+			}
+			l.footprints[msg.Unix] = msg
+			l.mu.Unlock()
+		}
+	}
+}
+
+func (l *FootprintLayer) initialize(chart *ChartWidget) {
+	l.subscribe(chart.interval)
+	l.removeIntervalHandler = chart.intervalChangeEvent.AddHandler(func(interval any) {
+		app.engine.Poison(l.sessionPID)
+		l.subscribe(interval.(int64))
+	})
+}
+
+func (l *FootprintLayer) update(_ *ChartWidget) {}
+
+func (l *FootprintLayer) render(screen *ebiten.Image, chart *ChartWidget) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	rect := chart.GetWidget().Rect
+	bucket := float64(chart.footprintBucket) * l.tickSize
+	visibleBars := float64(rect.Dx()) / chart.barWidth
+	width := float32(chart.barWidth * 0.9)
+	_, fontHeight := text.Measure("0", settings.FontSM, settings.FontSM.Metrics().VLineGap)
+
+	for unix, footprint := range l.footprints {
+		index := float64(chart.getBarIndex(unix))
+		if index < chart.barOffset-1 || index > chart.barOffset+visibleBars+1 {
+			continue
+		}
+		x := float32(rect.Min.X) + float32((index-chart.barOffset)*chart.barWidth)
+		cells, poc := footprintCells(footprint, bucket)
+		maxVolume := cells[poc].bid + cells[poc].ask
+		byBucket := make(map[int64]footprintCell, len(cells))
+		for _, cell := range cells {
+			byBucket[bucketIndex(cell.price, bucket)] = cell
+		}
+
+		bottom := float32(0)
+		for i, cell := range cells {
+			top := chart.getPriceYScreen(cell.price + bucket)
+			bot := chart.getPriceYScreen(cell.price)
+			bottom = max(bottom, bot)
+			if bot < float32(rect.Min.Y) || top > float32(rect.Max.Y) {
+				continue
+			}
+			height := max(bot-top-1, 1)
+
+			volume := cell.bid + cell.ask
+			col := settings.CandleStickGreen
+			if cell.bid > cell.ask {
+				col = settings.CandleStickRed
+			}
+			alpha := uint8(30 + 150*volume/maxVolume)
+			fill := color.RGBA{R: col.R, G: col.G, B: col.B, A: alpha}
+			vector.DrawFilledRect(screen, x, top, width, height, fill, false)
+			if i == poc {
+				vector.StrokeRect(screen, x, top, width, height, 1, settings.FootprintPOCColor, false)
+			}
+
+			if float64(height) < fontHeight {
+				continue
+			}
+			// The aggressive buys lift the ask above the bid they are
+			// compared to, the sells hit the bid below the ask.
+			idx := bucketIndex(cell.price, bucket)
+			bidCol, askCol := settings.FootprintTextColor, settings.FootprintTextColor
+			if below, ok := byBucket[idx-1]; ok && cell.ask >= below.bid*settings.FootprintImbalanceRatio && below.bid > 0 {
+				askCol = settings.CandleStickGreen
+			}
+			if above, ok := byBucket[idx+1]; ok && cell.bid >= above.ask*settings.FootprintImbalanceRatio && above.ask > 0 {
+				bidCol = settings.CandleStickRed
+			}
+			bidLabel := formatVolume(cell.bid)
+			askLabel := formatVolume(cell.ask)
+			labelWidth, _ := text.Measure(bidLabel+"x"+askLabel, settings.FontSM, 0)
+			if float32(labelWidth) > width {
+				continue
+			}
+			y := float64(top) + (float64(height)-fontHeight)/2
+			bidWidth, _ := text.Measure(bidLabel+"x", settings.FontSM, 0)
+			cx := float64(x) + (float64(width)-labelWidth)/2
+			DrawText(screen, bidLabel, settings.FontSM, cx, y, bidCol)
+			DrawText(screen, "x", settings.FontSM, cx+bidWidth-text.Advance("x", settings.FontSM), y, settings.FootprintTextColor)
+			DrawText(screen, askLabel, settings.FontSM, cx+bidWidth, y, askCol)
+		}
+
+		if bottom > 0 && bottom < float32(rect.Max.Y) {
+			col := settings.CandleStickGreen
+			if footprint.Delta < 0 {
+				col = settings.CandleStickRed
+			}
+			DrawText(screen, formatVolume(footprint.Delta), settings.FontSM, float64(x), float64(bottom)+2, col)
+		}
+	}
+}
+
+// footprintCells sums the levels of a footprint into cells of the given
+// price bucket. It returns the cells sorted by price and the index of the
+// cell with the most volume.
+func footprintCells(footprint event.Footprint, bucket float64) ([]footprintCell, int) {
+	byBucket := make(map[int64]*footprintCell)
+	for _, level := range footprint.Levels {
+		idx := bucketIndex(level.Price, bucket)
+		cell, ok := byBucket[idx]
+		if !ok {
+			cell = &footprintCell{price: float64(idx) * bucket}
+			byBucket[idx] = cell
+		}
+		cell.bid += level.Bid
+		cell.ask += level.Ask
+	}
+	cells := make([]footprintCell, 0, len(byBucket))
+	for _, cell := range byBucket {
+		cells = append(cells, *cell)
+	}
+	sort.Slice(cells, func(i, j int) bool {
+		return cells[i].price < cells[j].price
+	})
+	poc := 0
+	for i, cell := range cells {
+		if cell.bid+cell.ask > cells[poc].bid+cells[poc].ask {
+			poc = i
+		}
+	}
+	return cells, poc
+}
+
+func bucketIndex(price, bucket float64) int64 {
+	return int64(math.Floor(price/bucket + 1e-9))
+}
+
+// formatVolume shortens a volume to fit a footprint cell.
+func formatVolume(v float64) string {
+	switch abs := math.Abs(v); {
+	case abs >= 1000:
+		return fmt.Sprintf("%.1fk", v/1000)
+	case abs >= 100:
+		return fmt.Sprintf("%.0f", v)
+	case abs >= 10:
+		return fmt.Sprintf("%.1f", v)
+	}
+	return fmt.Sprintf("%.2f", v)
+}
+
+func (l *FootprintLayer) delete() {
+	if l.removeIntervalHandler != nil {
+		l.removeIntervalHandler()
+	}
+	app.engine.Poison(l.sessionPID)
+}
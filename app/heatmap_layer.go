@@ -8,7 +8,7 @@ import (
 	"marketmonkey/event"
 	"marketmonkey/settings"
 	"math"
-	"time"
+	"sync"
 
 	"github.com/anthdm/hollywood/actor"
 	"github.com/hajimehoshi/ebiten/v2"
@@ -35,51 +35,81 @@ type HeatmapLayer struct {
 	image    *ebiten.Image
 	vertImg  *ebiten.Image
 	isDirty  bool
-	heats    []event.Heatmap
 	interval int64
+	grouping int64
+
+	// guards the heatmaps and the normalizer, which are written by the
+	// receive loop and the UI
+	mu         sync.Mutex
+	heats      []event.Heatmap
+	normalizer *heatmapNormalizer
 }
 
 func NewHeatmapLayer(pair event.Pair) *HeatmapLayer {
-	eventCh := make(chan any)
-	streams := []session.Stream{{
-		Stream: event.StreamHeatmap,
-	}}
-	pid := app.engine.Spawn(session.New(eventCh, pair, streams), "session")
-
 	vertImg := ebiten.NewImage(1, 1)
 	vertImg.Fill(colornames.White)
 
 	layer := &HeatmapLayer{
 		name:       fmt.Sprintf("Heatmap - %s %s", pair.Exchange, pair.Symbol),
 		pair:       pair,
-		streams:    streams,
-		sessionPID: pid,
-		eventCh:    eventCh,
 		vertImg:    vertImg,
-		lastUnix:   time.Now().Unix(),
 		heats:      []event.Heatmap{},
+		grouping:   settings.DefaultPriceGrouping,
+		normalizer: newHeatmapNormalizer(pair, normalizationSnapshot, settings.DefaultPriceGrouping),
 	}
-
-	go layer.receiveData()
+	layer.subscribe()
 
 	return layer
 }
 
+// subscribe starts a new session that backfills the history of the current
+// grouping before the live heatmaps.
+func (l *HeatmapLayer) subscribe() {
+	eventCh := make(chan any)
+	l.streams = []session.Stream{{
+		Stream:   event.StreamHeatmap,
+		Grouping: l.grouping,
+		Backfill: settings.History.Heatmaps,
+	}}
+	l.mu.Lock()
+	l.eventCh = eventCh
+	l.heats = []event.Heatmap{}
+	l.normalizer = newHeatmapNormalizer(l.pair, l.normalizer.mode, l.grouping)
+	l.lastUnix = 0
+	l.mu.Unlock()
+	l.isDirty = true
+	l.sessionPID = app.engine.Spawn(session.New(eventCh, l.pair, l.streams), "session")
+
+	go l.receiveData(eventCh)
+}
+
 func (l *HeatmapLayer) initialize(chart *ChartWidget) {
 	l.interval = chart.interval
 	chart.intervalChangeEvent.AddHandler(l.onIntervalChange)
+	chart.groupingChangeEvent.AddHandler(l.onGroupingChange)
+	chart.normChangeEvent.AddHandler(l.onNormalizationChange)
+	if chart.grouping != l.grouping {
+		l.onGroupingChange(chart.grouping)
+	}
+	if chart.normalization != l.normalizer.mode {
+		l.onNormalizationChange(chart.normalization)
+	}
 }
 
-func (l *HeatmapLayer) receiveData() {
+func (l *HeatmapLayer) receiveData(eventCh chan any) {
 	fmt.Println("heatmap start recieving data")
-	for ev := range l.eventCh {
+	for ev := range eventCh {
 		switch msg := ev.(type) {
 		case event.Heatmap:
-			if l.lastUnix+l.interval <= msg.Unix {
+			l.mu.Lock()
+			// The heatmaps of a previous session are dropped.
+			if eventCh == l.eventCh && l.lastUnix+l.interval <= msg.Unix {
+				l.normalizer.normalize(&msg)
 				l.heats = append(l.heats, msg)
 				l.isDirty = true
 				l.lastUnix = msg.Unix
 			}
+			l.mu.Unlock()
 		}
 	}
 	fmt.Println("heatmap stopped recieving data")
@@ -93,18 +123,14 @@ func (l *HeatmapLayer) update(chart *ChartWidget) {
 }
 
 func (l *HeatmapLayer) renderUpdate(chart *ChartWidget) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
 	l.isDirty = false
 	rect := chart.GetWidget().Rect
 
 	visibleBars := float64(rect.Dx()) / chart.barWidth
 	visibleStartBar := chart.barOffset
 	visibleEndBar := visibleStartBar + visibleBars
-	startIndex := int(max(0, visibleStartBar))
-	endIndex := min(len(l.heats)-1, int(visibleEndBar))
-
-	if startIndex > endIndex {
-		return
-	}
 
 	// Use int for idxCount to avoid uint16 wraparound
 	var idxCount int
@@ -135,9 +161,13 @@ func (l *HeatmapLayer) renderUpdate(chart *ChartWidget) {
 		idxCount = 0
 	}
 
-	for i := startIndex; i <= endIndex; i++ {
-		heat := l.heats[i]
+	// The backfilled heatmaps can start before the chart, hence they are
+	// selected by their bar and not by their index.
+	for _, heat := range l.heats {
 		barIndex := float64(chart.getBarIndex(heat.Unix))
+		if barIndex < visibleStartBar-1 || barIndex > visibleEndBar {
+			continue
+		}
 
 		for _, level := range heat.Levels {
 			botPrice := level.Price
@@ -279,6 +309,34 @@ func (l *HeatmapLayer) onIntervalChange(interval any) {
 	}
 
 	l.interval = i
-	l.heats = []event.Heatmap{}
+	app.engine.Poison(l.sessionPID)
+	l.subscribe()
+}
+
+func (l *HeatmapLayer) onGroupingChange(grouping any) {
+	g, ok := grouping.(int64)
+	if !ok {
+		log.Fatal("grouping changed failed cast", grouping)
+		return
+	}
+	app.engine.Poison(l.sessionPID)
+	l.grouping = g
+	l.subscribe()
+}
+
+// onNormalizationChange renormalizes the heatmaps we already have, in order,
+// since the stateful modes depend on the history.
+func (l *HeatmapLayer) onNormalizationChange(normalization any) {
+	mode, ok := normalization.(heatmapNormalization)
+	if !ok {
+		log.Fatal("normalization changed failed cast", normalization)
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.normalizer = newHeatmapNormalizer(l.pair, mode, l.grouping)
+	for i := range l.heats {
+		l.normalizer.normalize(&l.heats[i])
+	}
 	l.isDirty = true
 }
@@ -0,0 +1,137 @@
+package app
+
+import (
+	"math"
+	"sort"
+
+	"marketmonkey/event"
+	"marketmonkey/settings"
+)
+
+type heatmapNormalization int
+
+const (
+	// normalizationSnapshot scales every snapshot to its own largest level.
+	normalizationSnapshot heatmapNormalization = iota
+	// normalizationPercentile scales to a percentile of the level sizes of
+	// the recent snapshots, a single huge level does not wash out the rest.
+	normalizationPercentile
+	// normalizationFixed scales to the thresholds of the instrument, the
+	// intensity is comparable over time.
+	normalizationFixed
+	// normalizationDecay scales to the largest level seen, decaying over
+	// time so the contrast recovers after a spike.
+	normalizationDecay
+)
+
+var heatmapNormalizations = []heatmapNormalization{
+	normalizationSnapshot,
+	normalizationPercentile,
+	normalizationFixed,
+	normalizationDecay,
+}
+
+func (n heatmapNormalization) String() string {
+	switch n {
+	case normalizationSnapshot:
+		return "Snapshot"
+	case normalizationPercentile:
+		return "Percentile"
+	case normalizationFixed:
+		return "Fixed"
+	case normalizationDecay:
+		return "Decay"
+	default:
+		return "Unknown"
+	}
+}
+
+// heatmapNormalizer sets the intensity of the heatmap levels. Except for the
+// snapshot mode it keeps state across snapshots, hence they have to be
+// normalized in order. The heatmaps are shared by all subscribers of the
+// pair and the orderbook history, the levels are copied before they are
+// written to.
+type heatmapNormalizer struct {
+	mode    heatmapNormalization
+	minSize float64
+	maxSize float64
+
+	window [][]float64
+	ref    float64
+	unix   int64
+}
+
+// newHeatmapNormalizer returns a normalizer for the heatmaps of the given
+// grouping in ticks.
+func newHeatmapNormalizer(pair event.Pair, mode heatmapNormalization, grouping int64) *heatmapNormalizer {
+	symbol := settings.Markets[pair.Exchange].Symbols[pair.Symbol]
+	scale := float64(max(grouping, 1))
+	return &heatmapNormalizer{
+		mode:    mode,
+		minSize: symbol.HeatmapMinSize * scale,
+		maxSize: symbol.HeatmapMaxSize * scale,
+	}
+}
+
+func (n *heatmapNormalizer) normalize(heat *event.Heatmap) {
+	snapshotMax := 0.0
+	for _, level := range heat.Levels {
+		snapshotMax = max(snapshotMax, level.Size)
+	}
+
+	low, high := 0.0, snapshotMax
+	switch n.mode {
+	case normalizationPercentile:
+		high = n.percentile(heat.Levels)
+	case normalizationFixed:
+		if n.maxSize > n.minSize {
+			low, high = n.minSize, n.maxSize
+		}
+	case normalizationDecay:
+		if n.unix > 0 {
+			elapsed := float64(heat.Unix - n.unix)
+			n.ref *= math.Pow(0.5, elapsed/settings.HeatmapDecayHalfLife)
+		}
+		n.ref = max(n.ref, snapshotMax)
+		n.unix = heat.Unix
+		high = n.ref
+	}
+
+	levels := make([]event.HeatmapLevel, len(heat.Levels))
+	for i, level := range heat.Levels {
+		level.Intensity = logScale(level.Size, low, high)
+		levels[i] = level
+	}
+	heat.Levels = levels
+}
+
+// percentile adds the sizes of the snapshot to the rolling window and
+// returns the configured percentile of all sizes in it.
+func (n *heatmapNormalizer) percentile(levels []event.HeatmapLevel) float64 {
+	sizes := make([]float64, len(levels))
+	for i, level := range levels {
+		sizes[i] = level.Size
+	}
+	n.window = append(n.window, sizes)
+	if len(n.window) > settings.HeatmapPercentileWindow {
+		n.window = n.window[1:]
+	}
+
+	all := []float64{}
+	for _, sizes := range n.window {
+		all = append(all, sizes...)
+	}
+	if len(all) == 0 {
+		return 0
+	}
+	sort.Float64s(all)
+	return all[int(float64(len(all)-1)*settings.HeatmapPercentile)]
+}
+
+func logScale(size, low, high float64) float64 {
+	if high <= low {
+		return 0
+	}
+	val := (math.Log10(size+1) - math.Log10(low+1)) / (math.Log10(high+1) - math.Log10(low+1))
+	return math.Max(0, math.Min(1, val))
+}
@@ -0,0 +1,129 @@
+package app
+
+import (
+	"fmt"
+	"math"
+	"sync"
+
+	"marketmonkey/actor/session"
+	"marketmonkey/event"
+	"marketmonkey/settings"
+
+	"github.com/anthdm/hollywood/actor"
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/vector"
+	"golang.org/x/image/colornames"
+)
+
+// The amount of icebergs kept, the oldest are dropped first.
+const icebergMaxCount = 1000
+
+// IcebergLayer marks the detected icebergs with a diamond at the price and
+// time of their first hit, sized by their estimated hidden size.
+type IcebergLayer struct {
+	pair       event.Pair
+	eventCh    chan any
+	sessionPID *actor.PID
+	vertImg    *ebiten.Image
+
+	mu       sync.Mutex
+	icebergs map[uint64]event.Iceberg
+	order    []uint64
+}
+
+func NewIcebergLayer(pair event.Pair) *IcebergLayer {
+	eventCh := make(chan any)
+	streams := []session.Stream{{
+		Stream: event.StreamIceberg,
+	}}
+	pid := app.engine.Spawn(session.New(eventCh, pair, streams), "session")
+
+	vertImg := ebiten.NewImage(1, 1)
+	vertImg.Fill(colornames.White)
+
+	layer := &IcebergLayer{
+		vertImg:    vertImg,
+		pair:       pair,
+		eventCh:    eventCh,
+		sessionPID: pid,
+		icebergs:   make(map[uint64]event.Iceberg),
+	}
+
+	go layer.receiveData()
+
+	return layer
+}
+
+func (l *IcebergLayer) receiveData() {
+	for ev := range l.eventCh {
+		switch msg := ev.(type) {
+		case event.Iceberg:
+			l.mu.Lock()
+			if _, ok := l.icebergs[msg.ID]; !ok {
+				l.order = append(l.order, msg.ID)
+				if len(l.order) > icebergMaxCount {
+					delete(l.icebergs, l.order[0])
+					l.order = l.order[1:]
+				}
+			}
+			l.icebergs[msg.ID] = msg
+			l.mu.Unlock()
+		}
+	}
+}
+
+func (l *IcebergLayer) initialize(_ *ChartWidget) {}
+
+func (l *IcebergLayer) update(_ *ChartWidget) {}
+
+func (l *IcebergLayer) render(screen *ebiten.Image, chart *ChartWidget) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	rect := chart.GetWidget().Rect
+	maxHidden := 0.0
+	for _, iceberg := range l.icebergs {
+		maxHidden = max(maxHidden, iceberg.Hidden)
+	}
+	if maxHidden == 0 {
+		return
+	}
+
+	for _, iceberg := range l.icebergs {
+		index := float64(chart.getBarIndex(iceberg.Unix / 1000))
+		x := float32(rect.Min.X) + float32((index-chart.barOffset)*chart.barWidth+chart.barWidth/2)
+		y := chart.getPriceYScreen(iceberg.Price)
+		if x < float32(rect.Min.X) || x > float32(rect.Max.X) || y < float32(rect.Min.Y) || y > float32(rect.Max.Y) {
+			continue
+		}
+		col := settings.IcebergAskColor
+		if iceberg.IsBid {
+			col = settings.IcebergBidColor
+		}
+		r := float32(3+5*math.Sqrt(iceberg.Hidden/maxHidden)) * settings.Scale
+
+		var path vector.Path
+		path.MoveTo(x, y-r)
+		path.LineTo(x+r, y)
+		path.LineTo(x, y+r)
+		path.LineTo(x-r, y)
+		path.Close()
+		vertices, indices := path.AppendVerticesAndIndicesForFilling(nil, nil)
+		for i := range vertices {
+			vertices[i].SrcX = 0.5
+			vertices[i].SrcY = 0.5
+			vertices[i].ColorR = float32(col.R) / 255
+			vertices[i].ColorG = float32(col.G) / 255
+			vertices[i].ColorB = float32(col.B) / 255
+			vertices[i].ColorA = 1
+		}
+		screen.DrawTriangles(vertices, indices, l.vertImg, &ebiten.DrawTrianglesOptions{AntiAlias: true})
+
+		label := fmt.Sprintf("%.2f", iceberg.Hidden)
+		DrawText(screen, label, settings.FontSM, float64(x+r+2), float64(y)-6, col)
+	}
+}
+
+func (l *IcebergLayer) delete() {
+	app.engine.Poison(l.sessionPID)
+}
@@ -1,6 +1,8 @@
 package app
 
 import (
+	"marketmonkey/event"
+
 	"github.com/ebitenui/ebitenui/widget"
 	"github.com/hajimehoshi/ebiten/v2"
 )
@@ -14,9 +16,22 @@ type Toolbar interface {
 	Toolbar() *widget.Container
 }
 
+// pairWidget is implemented by the widgets that display a single pair, their
+// window shows the state of its feed.
+type pairWidget interface {
+	Pair() event.Pair
+}
+
 type layer interface {
 	initialize(*ChartWidget)
 	update(*ChartWidget)
 	render(*ebiten.Image, *ChartWidget)
 	delete()
 }
+
+// paneLayer is implemented by the layers that draw into their own pane at the
+// bottom of the chart instead of on the price scale.
+type paneLayer interface {
+	layer
+	paneLabel() string
+}
@@ -1,21 +1,84 @@
 package app
 
 import (
+	"fmt"
 	"image/color"
 
+	"marketmonkey/event"
 	"marketmonkey/settings"
 
 	"github.com/ebitenui/ebitenui/image"
 	"github.com/ebitenui/ebitenui/widget"
 )
 
-func intervalDropdown(selectFn func(int64)) *widget.ListComboButton {
+// intervalDropdown selects the interval of the time based candles or one of
+// the activity bars.
+func intervalDropdown(intervalFn func(int64), barsFn func(settings.BarConfig)) *widget.ListComboButton {
 	enabledEntries := []any{}
 	for _, entry := range settings.TickIntervals {
 		if !entry.Disabled {
 			enabledEntries = append(enabledEntries, entry.Interval)
 		}
 	}
+	for _, bars := range settings.ActivityBars {
+		enabledEntries = append(enabledEntries, bars)
+	}
+	label := func(e any) string {
+		if bars, ok := e.(settings.BarConfig); ok {
+			return barsLabel(bars)
+		}
+		return TickInterval(e.(int64)).String()
+	}
+	return newDropdown(enabledEntries, settings.TickIntervals[0].Interval, label, func(e any) {
+		if bars, ok := e.(settings.BarConfig); ok {
+			barsFn(bars)
+			return
+		}
+		intervalFn(e.(int64))
+	})
+}
+
+func barsLabel(bars settings.BarConfig) string {
+	switch bars.Bar {
+	case event.BarTick:
+		return fmt.Sprintf("%d trades", bars.Param)
+	case event.BarVolume:
+		return fmt.Sprintf("%d vol", bars.Param)
+	case event.BarDollar:
+		switch {
+		case bars.Param%1000000 == 0:
+			return fmt.Sprintf("$%dM", bars.Param/1000000)
+		case bars.Param%1000 == 0:
+			return fmt.Sprintf("$%dK", bars.Param/1000)
+		}
+		return fmt.Sprintf("$%d", bars.Param)
+	case event.BarRange:
+		return fmt.Sprintf("%dT range", bars.Param)
+	case event.BarRenko:
+		return fmt.Sprintf("%dT renko", bars.Param)
+	}
+	return bars.Bar.String()
+}
+
+func groupingDropdown(selected int64, selectFn func(int64)) *widget.ListComboButton {
+	return ticksDropdown(settings.PriceGroupings, selected, selectFn)
+}
+
+// ticksDropdown selects one of the given amounts of ticks.
+func ticksDropdown(ticks []int64, selected int64, selectFn func(int64)) *widget.ListComboButton {
+	entries := []any{}
+	for _, t := range ticks {
+		entries = append(entries, t)
+	}
+	label := func(e any) string {
+		return fmt.Sprintf("%dT", e.(int64))
+	}
+	return newDropdown(entries, selected, label, func(e any) {
+		selectFn(e.(int64))
+	})
+}
+
+func newDropdown(entries []any, selected any, labelFn func(any) string, selectFn func(any)) *widget.ListComboButton {
 	comboBox := widget.NewListComboButton(
 		widget.ListComboButtonOpts.SelectComboButtonOpts(
 			widget.SelectComboButtonOpts.ComboButtonOpts(
@@ -50,7 +113,7 @@ func intervalDropdown(selectFn func(int64)) *widget.ListComboButton {
 		),
 		widget.ListComboButtonOpts.ListOpts(
 			widget.ListOpts.ContainerOpts(widget.ContainerOpts.WidgetOpts(widget.WidgetOpts.MinSize(0, 0))),
-			widget.ListOpts.Entries(enabledEntries),
+			widget.ListOpts.Entries(entries),
 			widget.ListOpts.ScrollContainerOpts(
 				widget.ScrollContainerOpts.Image(&widget.ScrollContainerImage{
 					Idle: image.NewNineSliceColor(settings.BackgroundColor),
@@ -87,18 +150,12 @@ func intervalDropdown(selectFn func(int64)) *widget.ListComboButton {
 			widget.ListOpts.EntryTextPadding(widget.NewInsetsSimple(5)),
 		),
 		// Define how the entry is displayed
-		widget.ListComboButtonOpts.EntryLabelFunc(
-			func(e any) string {
-				return TickInterval(e.(int64)).String()
-			},
-			func(e any) string {
-				return TickInterval(e.(int64)).String()
-			}),
+		widget.ListComboButtonOpts.EntryLabelFunc(labelFn, labelFn),
 		widget.ListComboButtonOpts.EntrySelectedHandler(func(args *widget.ListComboButtonEntrySelectedEventArgs) {
-			selectFn(args.Entry.(int64))
+			selectFn(args.Entry)
 		}),
 	)
-	comboBox.SetSelectedEntry(settings.TickIntervals[0].Interval)
+	comboBox.SetSelectedEntry(selected)
 
 	return comboBox
 }
@@ -0,0 +1,120 @@
+package app
+
+import (
+	"image/color"
+
+	"marketmonkey/actor/session"
+	"marketmonkey/event"
+	"marketmonkey/settings"
+
+	"github.com/anthdm/hollywood/actor"
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/vector"
+)
+
+const (
+	// The amount of liquidity messages kept, 10 minutes at the publish rate
+	// of the orderbook.
+	liquidityMaxMessages = 3000
+	// Changes smaller than this fraction of the largest visible change are
+	// not drawn.
+	liquidityMinRatio = 0.1
+)
+
+// LiquidityLayer marks the levels where liquidity was added or pulled
+// without being traded, which makes spoofing and pulled walls visible.
+type LiquidityLayer struct {
+	pair       event.Pair
+	eventCh    chan any
+	sessionPID *actor.PID
+	messages   []event.Liquidity
+}
+
+func NewLiquidityLayer(pair event.Pair) *LiquidityLayer {
+	eventCh := make(chan any)
+	streams := []session.Stream{{
+		Stream: event.StreamLiquidity,
+	}}
+	pid := app.engine.Spawn(session.New(eventCh, pair, streams), "session")
+
+	layer := &LiquidityLayer{
+		pair:       pair,
+		eventCh:    eventCh,
+		sessionPID: pid,
+		messages:   []event.Liquidity{},
+	}
+
+	go layer.receiveData()
+
+	return layer
+}
+
+func (l *LiquidityLayer) receiveData() {
+	for ev := range l.eventCh {
+		switch msg := ev.(type) {
+		case event.Liquidity:
+			if len(l.messages) == liquidityMaxMessages {
+				l.messages = append(l.messages[:0], l.messages[1:]...)
+			}
+			l.messages = append(l.messages, msg)
+		}
+	}
+}
+
+func (l *LiquidityLayer) initialize(_ *ChartWidget) {}
+
+func (l *LiquidityLayer) update(_ *ChartWidget) {}
+
+func (l *LiquidityLayer) render(screen *ebiten.Image, chart *ChartWidget) {
+	rect := chart.GetWidget().Rect
+	visibleBars := float64(rect.Dx()) / chart.barWidth
+
+	visible := func(msg event.Liquidity) (float64, bool) {
+		index := float64(chart.getBarIndex(msg.Unix / 1000))
+		return index, index >= chart.barOffset-1 && index <= chart.barOffset+visibleBars
+	}
+
+	maxSize := 0.0
+	for _, msg := range l.messages {
+		if _, ok := visible(msg); !ok {
+			continue
+		}
+		for _, change := range msg.Changes {
+			if change.Kind != event.LiquidityConsumed {
+				maxSize = max(maxSize, change.Size)
+			}
+		}
+	}
+	if maxSize == 0 {
+		return
+	}
+
+	for _, msg := range l.messages {
+		index, ok := visible(msg)
+		if !ok {
+			continue
+		}
+		x := float32(rect.Min.X) + float32((index-chart.barOffset)*chart.barWidth)
+		for _, change := range msg.Changes {
+			ratio := change.Size / maxSize
+			if change.Kind == event.LiquidityConsumed || ratio < liquidityMinRatio {
+				continue
+			}
+			y := chart.getPriceYScreen(change.Price)
+			if y < float32(rect.Min.Y) || y > float32(rect.Max.Y) {
+				continue
+			}
+			col := settings.LiquidityAddedColor
+			if change.Kind == event.LiquidityPulled {
+				col = settings.LiquidityPulledColor
+			}
+			fill := color.NRGBA{R: col.R, G: col.G, B: col.B, A: uint8(255 * ratio)}
+			height := float32(1+3*ratio) * settings.Scale
+			vector.DrawFilledRect(screen, x, y-height/2, float32(chart.barWidth*0.9), height, fill, false)
+		}
+	}
+}
+
+func (l *LiquidityLayer) delete() {
+	app.engine.Poison(l.sessionPID)
+}
@@ -51,10 +51,14 @@ func NewMenuBarWidget() *MenuBarWidget {
 	chartButton := makeMenubarButton("Chart", "chart")
 	orderbookButton := makeMenubarButton("Orderbook", "orderbook")
 	tradesButton := makeMenubarButton("Trades", "trades")
+	depthButton := makeMenubarButton("Depth", "depth")
+	spreadButton := makeSpreadMenubarButton("Spread")
 	innerContainer.AddChild(
 		chartButton,
 		orderbookButton,
 		tradesButton,
+		depthButton,
+		spreadButton,
 	)
 
 	container.AddChild(innerContainer)
@@ -75,7 +79,8 @@ func makeMenubarButton(name string, widgetType string) *widget.Button {
 	for _, market := range settings.Markets {
 		button := newToolbarMenuEntry(market.Name)
 		marketButtons[i] = button
-		syms := make([]*widget.Button, len(market.Symbols))
+		i++
+		syms := make([]*widget.Button, 0, len(market.Symbols))
 		for _, symbol := range market.Symbols {
 			sym := newToolbarMenuEntry(symbol.Name)
 			sym.ClickedEvent.AddHandler((func(args any) {
@@ -88,14 +93,16 @@ func makeMenubarButton(name string, widgetType string) *widget.Button {
 				case "trades":
 					tradesWidget := NewTradesWidget(pair)
 					app.ui.AddWindow(NewWindow(tradesWidget, windowName, app.getWidgetRect("small")))
+				case "depth":
+					depthWidget := NewDepthWidget(pair)
+					app.ui.AddWindow(NewWindow(depthWidget, windowName, app.getWidgetRect("small")))
 				case "chart":
 					chartWidget := NewChartWidget(pair, 1)
 					chartWidget.AddLayer(NewHeatmapLayer(pair))
 					app.ui.AddWindow(NewWindow(chartWidget, windowName, app.getWidgetRect("large")))
 				}
 			}))
-			syms[i] = sym
-			i++
+			syms = append(syms, sym)
 		}
 		button.ClickedEvent.AddHandler(func(args any) {
 			openToolbarMenu(exchangeButton.GetWidget(), app.ui, syms...)
@@ -107,6 +114,27 @@ func makeMenubarButton(name string, widgetType string) *widget.Button {
 	return exchangeButton
 }
 
+// makeSpreadMenubarButton lists the assets of the spread monitor, they are not
+// bound to a single market like the other widgets.
+func makeSpreadMenubarButton(name string) *widget.Button {
+	button := newToolbarButton(name)
+	entries := make([]*widget.Button, 0, len(settings.SpreadMonitors))
+	for symbol := range settings.SpreadMonitors {
+		entry := newToolbarMenuEntry(symbol)
+		entry.ClickedEvent.AddHandler(func(args any) {
+			pair := event.NewPair(settings.Spread, symbol)
+			windowName := fmt.Sprintf("%s %s", name, pair)
+			spreadWidget := NewSpreadWidget(pair)
+			app.ui.AddWindow(NewWindow(spreadWidget, windowName, app.getWidgetRect("small")))
+		})
+		entries = append(entries, entry)
+	}
+	button.ClickedEvent.AddHandler(func(args any) {
+		openToolbarMenu(button.GetWidget(), app.ui, entries...)
+	})
+	return button
+}
+
 func newToolbarButton(label string) *widget.Button {
 	return widget.NewButton(
 		widget.ButtonOpts.Image(&widget.ButtonImage{
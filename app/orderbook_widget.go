@@ -23,6 +23,8 @@ type OrderbookWidget struct {
 	eventCh    chan any
 	orderbook  event.Orderbook
 	sessionPID *actor.PID
+	// price grouping in ticks, zero shows the raw levels
+	grouping int64
 
 	rows []*OrderbookRow
 }
@@ -122,6 +124,10 @@ func (p *OrderbookWidget) PreferredSize() (int, int) {
 	return 0, 0
 }
 
+func (p *OrderbookWidget) Pair() event.Pair {
+	return p.pair
+}
+
 func (p *OrderbookWidget) GetWidget() *widget.Widget {
 	return p.Container.GetWidget()
 }
@@ -130,6 +136,41 @@ func (p *OrderbookWidget) Close(_ *widget.WindowClosedEventArgs) {
 	app.engine.Poison(p.sessionPID)
 }
 
+func (p *OrderbookWidget) Toolbar() *widget.Container {
+	container := widget.NewContainer(
+		widget.ContainerOpts.BackgroundImage(
+			image.NewNineSliceColor(settings.PanelBackgroundColor),
+		),
+		widget.ContainerOpts.Layout(widget.NewRowLayout()),
+		widget.ContainerOpts.WidgetOpts(
+			widget.WidgetOpts.LayoutData(widget.RowLayoutData{
+				Position: widget.RowLayoutPositionCenter,
+			}),
+		),
+	)
+	// The raw levels are the same as a grouping of a single tick.
+	container.AddChild(groupingDropdown(1, p.onGroupingChange))
+	return container
+}
+
+func (p *OrderbookWidget) onGroupingChange(grouping int64) {
+	if p.grouping == grouping {
+		return
+	}
+	app.engine.Poison(p.sessionPID)
+
+	p.grouping = grouping
+	p.eventCh = make(chan any)
+	p.streams = []session.Stream{{
+		Stream:   event.StreamOrderbook,
+		Grouping: grouping,
+	}}
+	p.sessionPID = app.engine.Spawn(session.New(p.eventCh, p.pair, p.streams), "session")
+	p.orderbook = event.Orderbook{}
+
+	go p.receiveData()
+}
+
 type OrderbookRow struct {
 	*widget.Container
 
@@ -0,0 +1,85 @@
+package app
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+
+	"marketmonkey/settings"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/vector"
+)
+
+// panePoint is a single sample of a time series drawn in a pane. Unix is in
+// seconds like the bars of the chart.
+type panePoint struct {
+	Unix  int64
+	Value float64
+}
+
+// renderPane draws the series as a line into the given rect, scaled to the
+// value range of the visible bars. When there are several samples per bar
+// the last one is drawn.
+func renderPane(screen *ebiten.Image, chart *ChartWidget, rect image.Rectangle, label string, points []panePoint, col color.Color) {
+	renderPaneBackground(screen, rect)
+
+	type bar struct {
+		index int64
+		value float64
+	}
+	visibleBars := float64(rect.Dx()) / chart.barWidth
+	bars := []bar{}
+	minValue, maxValue := math.Inf(1), math.Inf(-1)
+	for _, point := range points {
+		index := chart.getBarIndex(point.Unix)
+		if float64(index) < chart.barOffset-1 || float64(index) > chart.barOffset+visibleBars+1 {
+			continue
+		}
+		if n := len(bars); n > 0 && bars[n-1].index == index {
+			bars[n-1].value = point.Value
+		} else {
+			bars = append(bars, bar{index: index, value: point.Value})
+		}
+		minValue = math.Min(minValue, point.Value)
+		maxValue = math.Max(maxValue, point.Value)
+	}
+
+	if len(bars) > 0 {
+		DrawText(screen, fmt.Sprintf("%s %.4f", label, bars[len(bars)-1].value), settings.FontSM, float64(rect.Min.X)+float64(settings.PanelPadding), float64(rect.Min.Y)+4, col)
+	} else {
+		DrawText(screen, label, settings.FontSM, float64(rect.Min.X)+float64(settings.PanelPadding), float64(rect.Min.Y)+4, col)
+	}
+	if len(bars) < 2 {
+		return
+	}
+	if maxValue == minValue {
+		maxValue++
+		minValue--
+	}
+
+	padding := float32(rect.Dy()) * 0.1
+	height := float32(rect.Dy()) - 2*padding
+	y := func(value float64) float32 {
+		return float32(rect.Max.Y) - padding - float32((value-minValue)/(maxValue-minValue))*height
+	}
+	x := func(index int64) float32 {
+		return float32(rect.Min.X) + float32((float64(index)-chart.barOffset)*chart.barWidth+chart.barWidth/2)
+	}
+	if minValue < 0 && maxValue > 0 {
+		DrawDashedLine(screen, float32(rect.Min.X), y(0), float32(rect.Max.X), y(0), 0.5, 5, 5, settings.PanelDividerColor, true)
+	}
+	for i := 1; i < len(bars); i++ {
+		x1, x2 := x(bars[i-1].index), x(bars[i].index)
+		if x2 < float32(rect.Min.X) || x1 > float32(rect.Max.X) {
+			continue
+		}
+		vector.StrokeLine(screen, x1, y(bars[i-1].value), x2, y(bars[i].value), float32(settings.LineChartStrokeWidth), col, true)
+	}
+}
+
+func renderPaneBackground(screen *ebiten.Image, rect image.Rectangle) {
+	vector.DrawFilledRect(screen, float32(rect.Min.X), float32(rect.Min.Y), float32(rect.Dx()), float32(rect.Dy()), settings.PanelBackgroundColor, false)
+	vector.StrokeLine(screen, float32(rect.Min.X), float32(rect.Min.Y), float32(rect.Max.X), float32(rect.Min.Y), 1, settings.PanelDividerColor, false)
+}
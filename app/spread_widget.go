@@ -0,0 +1,137 @@
+package app
+
+import (
+	"fmt"
+	"image/color"
+	"marketmonkey/actor/session"
+	"marketmonkey/event"
+	"marketmonkey/settings"
+
+	"github.com/anthdm/hollywood/actor"
+	"github.com/ebitenui/ebitenui/image"
+	"github.com/ebitenui/ebitenui/widget"
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/vector"
+)
+
+// SpreadWidget renders a venue x venue matrix of the spreads published by
+// the spread monitor. Every cell holds the bid of the row venue minus the ask
+// of the column venue in basis points.
+type SpreadWidget struct {
+	*widget.Container
+
+	pair       event.Pair
+	eventCh    chan any
+	sessionPID *actor.PID
+	spread     event.Spread
+}
+
+func NewSpreadWidget(pair event.Pair) *SpreadWidget {
+	eventCh := make(chan any)
+	streams := []session.Stream{{
+		Stream: event.StreamSpread,
+	}}
+	pid := app.engine.Spawn(session.New(eventCh, pair, streams), "session")
+
+	container := widget.NewContainer(
+		widget.ContainerOpts.BackgroundImage(
+			image.NewNineSliceColor(settings.PanelBackgroundColor),
+		),
+		widget.ContainerOpts.Layout(widget.NewAnchorLayout()),
+		widget.ContainerOpts.WidgetOpts(
+			widget.WidgetOpts.LayoutData(widget.AnchorLayoutData{
+				StretchHorizontal: true,
+				StretchVertical:   true,
+			}),
+		),
+	)
+
+	w := &SpreadWidget{
+		Container:  container,
+		pair:       pair,
+		eventCh:    eventCh,
+		sessionPID: pid,
+	}
+
+	go w.receiveData()
+
+	return w
+}
+
+func (w *SpreadWidget) receiveData() {
+	for ev := range w.eventCh {
+		switch msg := ev.(type) {
+		case event.Spread:
+			w.spread = msg
+		}
+	}
+}
+
+func (w *SpreadWidget) Render(screen *ebiten.Image) {
+	w.Container.Render(screen)
+
+	spread := w.spread
+	if len(spread.Quotes) == 0 {
+		return
+	}
+
+	rect := w.GetWidget().Rect
+	padding := float64(settings.PanelPadding)
+	rowHeight := float64(24 * settings.Scale)
+	cols := len(spread.Quotes) + 1
+	colWidth := (float64(rect.Dx()) - padding*2) / float64(cols)
+	x0 := float64(rect.Min.X) + padding
+	y0 := float64(rect.Min.Y) + padding
+
+	// Header row and column with the venue names
+	for i, quote := range spread.Quotes {
+		DrawText(screen, quote.Venue.Exchange, settings.FontSM, x0+colWidth*float64(i+1), y0, color.White)
+		DrawText(screen, quote.Venue.Exchange, settings.FontSM, x0, y0+rowHeight*float64(i+1), color.White)
+	}
+
+	for i, quote := range spread.Quotes {
+		if !quote.HasQuote() {
+			DrawText(screen, "no quote", settings.FontSM, x0+colWidth, y0+rowHeight*float64(i+1), color.White)
+			continue
+		}
+		for j := range spread.Quotes {
+			x := x0 + colWidth*float64(j+1)
+			y := y0 + rowHeight*float64(i+1)
+			if i == j || !spread.Quotes[j].HasQuote() {
+				DrawText(screen, "-", settings.FontSM, x, y, color.White)
+				continue
+			}
+			bps := spread.Spreads[i][j]
+			bg := settings.OrderbookRed
+			fg := settings.Red
+			if bps > 0 {
+				bg = settings.OrderbookGreen
+				fg = settings.Green
+			}
+			vector.DrawFilledRect(screen, float32(x-4), float32(y-2), float32(colWidth-4), float32(rowHeight-4), bg, false)
+			DrawText(screen, fmt.Sprintf("%.1f", bps), settings.FontSM, x, y, fg)
+		}
+	}
+
+	y := y0 + rowHeight*float64(cols+1)
+	label := "Basis: no quote"
+	if spread.HasBasis {
+		label = fmt.Sprintf("Basis %.2f (%.3f%%)", spread.Basis, spread.BasisPerc)
+	}
+	if spread.BasisAnnualized != 0 {
+		label += fmt.Sprintf("  Annualized %.2f%%", spread.BasisAnnualized)
+	}
+	DrawText(screen, label, settings.FontSM, x0, y, color.White)
+}
+
+func (w *SpreadWidget) PreferredSize() (int, int) {
+	return 0, 0
+}
+
+func (w *SpreadWidget) GetWidget() *widget.Widget {
+	return w.Container.GetWidget()
+}
+
+func (w *SpreadWidget) Close(_ *widget.WindowClosedEventArgs) {
+	app.engine.Poison(w.sessionPID)
+}
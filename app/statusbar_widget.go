@@ -8,6 +8,7 @@ import (
 	"github.com/ebitenui/ebitenui/image"
 	"github.com/ebitenui/ebitenui/widget"
 	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/text/v2"
 )
 
 type StatusBarWidget struct {
@@ -50,6 +51,25 @@ func (w *StatusBarWidget) Render(screen *ebiten.Image) {
 
 	fps := ebiten.ActualFPS()
 	w.fpsLabel.Label = fmt.Sprintf("FPS %d", int(fps))
+
+	w.renderFeeds(screen)
+}
+
+// renderFeeds draws the state and message rate of every venue, colored by
+// its state, right aligned in the status bar.
+func (w *StatusBarWidget) renderFeeds(screen *ebiten.Image) {
+	rect := w.GetWidget().Rect
+	font := settings.FontSM
+	x := float64(rect.Max.X) - float64(settings.PanelPadding)
+	venues := app.feeds.venues()
+	for i := len(venues) - 1; i >= 0; i-- {
+		venue := venues[i]
+		label := fmt.Sprintf("%s %s %.0f/s", venue.Pair.Exchange, venue.State, venue.Rate)
+		fw, fh := text.Measure(label, font, font.Metrics().VLineGap)
+		x -= fw
+		DrawText(screen, label, font, x, float64(rect.Min.Y)+(float64(rect.Dy())-fh)/2, feedStateColor(venue.State))
+		x -= float64(settings.PanelPadding)
+	}
 }
 
 func (w *StatusBarWidget) PreferredSize() (int, int) {
@@ -1,6 +1,7 @@
 package app
 
 import (
+	"marketmonkey/event"
 	"marketmonkey/settings"
 	"math"
 	"time"
@@ -8,6 +9,7 @@ import (
 	"github.com/ebitenui/ebitenui/image"
 	"github.com/ebitenui/ebitenui/widget"
 	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/text/v2"
 	"golang.org/x/image/colornames"
 )
 
@@ -48,6 +50,11 @@ func (ts *TimeScaleWidget) Render(screen *ebiten.Image) {
 	minBar := ts.chart.barOffset + (minX-float64(rect.Min.X))/ts.chart.barWidth
 	maxBar := ts.chart.barOffset + (maxX-float64(rect.Min.X))/ts.chart.barWidth
 
+	if ts.chart.barType != event.BarTime {
+		ts.renderBars(screen, minBar, maxBar)
+		return
+	}
+
 	// Bar indices → time in seconds
 	minT := minBar * float64(ts.chart.interval)
 	maxT := maxBar * float64(ts.chart.interval)
@@ -83,3 +90,23 @@ func (ts *TimeScaleWidget) Render(screen *ebiten.Image) {
 		DrawText(screen, label, font, drawX, drawY, colornames.White)
 	}
 }
+
+// renderBars labels the activity bars, which are not spaced by time, with
+// the start time of every few bars.
+func (ts *TimeScaleWidget) renderBars(screen *ebiten.Image, minBar, maxBar float64) {
+	rect := ts.GetWidget().Rect
+	font := settings.FontSM
+	labelWidth, _ := text.Measure("00:00:00", font, 0)
+	step := int64(math.Ceil((labelWidth + float64(settings.PanelPadding)) / ts.chart.barWidth))
+	for index := int64(math.Floor(minBar)) / step * step; float64(index) <= maxBar; index += step {
+		if index < 0 || index >= int64(len(ts.chart.barTimes)) {
+			continue
+		}
+		x := float64(rect.Min.X) + (float64(index)-ts.chart.barOffset)*ts.chart.barWidth
+		if x < float64(rect.Min.X) || x > float64(rect.Max.X) {
+			continue
+		}
+		label := time.Unix(ts.chart.getBarUnix(index), 0).Format("15:04:05")
+		DrawText(screen, label, font, x, float64(rect.Min.Y)+float64(font.Metrics().CapHeight), colornames.White)
+	}
+}
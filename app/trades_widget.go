@@ -50,9 +50,12 @@ func NewTradesWidget(pair event.Pair) *TradesWidget {
 		),
 	)
 
+	// Merged tapes carry trades of multiple venues, show where they printed.
+	showVenue := pair.Exchange == settings.Aggregated
+
 	rows := []*TradeRow{}
 	for i := 0; i < 16; i++ {
-		r := NewTradeRow()
+		r := NewTradeRow(showVenue)
 		container.AddChild(r)
 		rows = append(rows, r)
 	}
@@ -80,6 +83,9 @@ func (t *TradesWidget) receiveData() {
 				t.rows[i].priceLabel.Color = t.rows[i-1].priceLabel.Color
 				t.rows[i].sizeLabel.Label = t.rows[i-1].sizeLabel.Label
 				t.rows[i].timeLabel.Label = t.rows[i-1].timeLabel.Label
+				if t.rows[i].venueLabel != nil {
+					t.rows[i].venueLabel.Label = t.rows[i-1].venueLabel.Label
+				}
 			}
 			color := settings.Red
 			if msg.IsBuy {
@@ -89,6 +95,9 @@ func (t *TradesWidget) receiveData() {
 			t.rows[0].priceLabel.Color = color
 			t.rows[0].sizeLabel.Label = fmt.Sprintf("%.2f", msg.Qty)
 			t.rows[0].timeLabel.Label = time.UnixMilli(msg.Unix).Format("15:04:05")
+			if t.rows[0].venueLabel != nil {
+				t.rows[0].venueLabel.Label = msg.Venue
+			}
 			t.rows[0].flash = true
 		}
 	}
@@ -106,6 +115,10 @@ func (*TradesWidget) PreferredSize() (int, int) {
 	return 0, 0
 }
 
+func (w *TradesWidget) Pair() event.Pair {
+	return w.pair
+}
+
 func (w *TradesWidget) GetWidget() *widget.Widget {
 	return w.Container.GetWidget()
 }
@@ -120,39 +133,63 @@ type TradeRow struct {
 	priceLabel *widget.Text
 	sizeLabel  *widget.Text
 	timeLabel  *widget.Text
+	venueLabel *widget.Text
 	image      *ebiten.Image
 	flash      bool
 }
 
-func NewTradeRow() *TradeRow {
+func NewTradeRow(showVenue bool) *TradeRow {
 	priceLabel := widget.NewText(
 		widget.TextOpts.WidgetOpts(
-			widget.WidgetOpts.LayoutData(widget.AnchorLayoutData{
-				VerticalPosition: widget.AnchorLayoutPositionCenter,
+			widget.WidgetOpts.LayoutData(widget.GridLayoutData{
+				VerticalPosition: widget.GridLayoutPositionCenter,
 			}),
 		),
 		widget.TextOpts.Text(".....", settings.FontSM, color.White),
 	)
 	sizeLabel := widget.NewText(
 		widget.TextOpts.WidgetOpts(
-			widget.WidgetOpts.LayoutData(widget.AnchorLayoutData{
-				VerticalPosition:   widget.AnchorLayoutPositionCenter,
-				HorizontalPosition: widget.AnchorLayoutPositionCenter,
+			widget.WidgetOpts.LayoutData(widget.GridLayoutData{
+				VerticalPosition:   widget.GridLayoutPositionCenter,
+				HorizontalPosition: widget.GridLayoutPositionCenter,
 			}),
 		),
 		widget.TextOpts.Text(".....", settings.FontSM, color.White),
 	)
 	timeLabel := widget.NewText(
 		widget.TextOpts.WidgetOpts(
-			widget.WidgetOpts.LayoutData(widget.AnchorLayoutData{
-				VerticalPosition:   widget.AnchorLayoutPositionCenter,
-				HorizontalPosition: widget.AnchorLayoutPositionEnd,
+			widget.WidgetOpts.LayoutData(widget.GridLayoutData{
+				VerticalPosition:   widget.GridLayoutPositionCenter,
+				HorizontalPosition: widget.GridLayoutPositionEnd,
 			}),
 		),
 		widget.TextOpts.Text(time.Now().Format("15:04:05"), settings.FontSM, color.White),
 	)
+	columns := []*widget.Text{priceLabel, sizeLabel}
+	var venueLabel *widget.Text
+	if showVenue {
+		venueLabel = widget.NewText(
+			widget.TextOpts.WidgetOpts(
+				widget.WidgetOpts.LayoutData(widget.GridLayoutData{
+					VerticalPosition:   widget.GridLayoutPositionCenter,
+					HorizontalPosition: widget.GridLayoutPositionCenter,
+				}),
+			),
+			widget.TextOpts.Text(".....", settings.FontSM, color.White),
+		)
+		columns = append(columns, venueLabel)
+	}
+	columns = append(columns, timeLabel)
+
+	stretch := make([]bool, len(columns))
+	for i := range stretch {
+		stretch[i] = true
+	}
 	container := widget.NewContainer(
-		widget.ContainerOpts.Layout(widget.NewAnchorLayout()),
+		widget.ContainerOpts.Layout(widget.NewGridLayout(
+			widget.GridLayoutOpts.Columns(len(columns)),
+			widget.GridLayoutOpts.Stretch(stretch, []bool{true}),
+		)),
 		widget.ContainerOpts.WidgetOpts(
 			widget.WidgetOpts.LayoutData(widget.RowLayoutData{
 				Position: widget.RowLayoutPositionStart,
@@ -161,7 +198,9 @@ func NewTradeRow() *TradeRow {
 			widget.WidgetOpts.MinSize(0, int(24*settings.Scale)),
 		),
 	)
-	container.AddChild(priceLabel, sizeLabel, timeLabel)
+	for _, column := range columns {
+		container.AddChild(column)
+	}
 	image := ebiten.NewImage(1, 1)
 	image.Fill(settings.FlashLastTradeColor)
 	return &TradeRow{
@@ -169,6 +208,7 @@ func NewTradeRow() *TradeRow {
 		priceLabel: priceLabel,
 		sizeLabel:  sizeLabel,
 		timeLabel:  timeLabel,
+		venueLabel: venueLabel,
 		image:      image,
 	}
 }
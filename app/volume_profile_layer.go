@@ -0,0 +1,252 @@
+package app
+
+import (
+	"image/color"
+	"log"
+	"sync"
+	"time"
+
+	"marketmonkey/actor/profile"
+	"marketmonkey/event"
+	"marketmonkey/settings"
+
+	evt "github.com/ebitenui/ebitenui/event"
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/vector"
+)
+
+// How often the profile is requested again while its range is open.
+const profileRefreshInterval = time.Second
+
+type profileMode int
+
+const (
+	profileOff profileMode = iota
+	// the profile of the current session
+	profileSession
+	// the profile of the bars on the screen
+	profileVisible
+	// the profile between two bars clicked with the anchor button
+	profileFixed
+)
+
+func (m profileMode) String() string {
+	switch m {
+	case profileSession:
+		return "VP session"
+	case profileVisible:
+		return "VP visible"
+	case profileFixed:
+		return "VP fixed"
+	}
+	return "VP off"
+}
+
+// VolumeProfileLayer draws the volume traded per price over a range of
+// time as horizontal buy and sell histograms, with the POC, the value area
+// and the high and low volume nodes marked. The profiles are summed up by
+// the profile actor of the pair on request.
+type VolumeProfileLayer struct {
+	pair     event.Pair
+	mode     profileMode
+	tickSize float64
+
+	// the anchors of the fixed range, zero while unset
+	anchorFrom int64
+	anchorTo   int64
+
+	removeAnchorHandler evt.RemoveHandlerFunc
+
+	// the range and grouping of the last request
+	from, to    int64
+	grouping    int64
+	lastRequest time.Time
+
+	mu         sync.Mutex
+	requesting bool
+	profile    *event.VolumeProfile
+}
+
+func NewVolumeProfileLayer(pair event.Pair, mode profileMode) *VolumeProfileLayer {
+	return &VolumeProfileLayer{
+		pair:     pair,
+		mode:     mode,
+		tickSize: settings.TickSize(pair),
+	}
+}
+
+func (l *VolumeProfileLayer) initialize(chart *ChartWidget) {
+	l.removeAnchorHandler = chart.anchorEvent.AddHandler(func(unix any) {
+		if l.mode != profileFixed {
+			return
+		}
+		// The first click anchors the start, the second the end, the
+		// third one starts over.
+		switch u := unix.(int64); {
+		case l.anchorFrom == 0 || l.anchorTo != 0:
+			l.anchorFrom, l.anchorTo = u, 0
+		case u < l.anchorFrom:
+			l.anchorFrom, l.anchorTo = u, chart.getNextBarUnix(l.anchorFrom)
+		default:
+			l.anchorTo = chart.getNextBarUnix(u)
+		}
+		chart.isDirty = true
+	})
+}
+
+func (l *VolumeProfileLayer) update(chart *ChartWidget) {
+	from, to, ok := l.timeRange(chart)
+	if !ok {
+		l.mu.Lock()
+		l.profile = nil
+		l.mu.Unlock()
+		return
+	}
+	changed := from != l.from || to != l.to || chart.grouping != l.grouping
+	// A closed range does not change anymore.
+	if !changed && (to != 0 || time.Since(l.lastRequest) < profileRefreshInterval) {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.requesting {
+		return
+	}
+	l.requesting = true
+	l.from, l.to, l.grouping = from, to, chart.grouping
+	l.lastRequest = time.Now()
+
+	req := event.VolumeProfileRequest{From: from, To: to, Grouping: chart.grouping}
+	go func() {
+		msg, err := profile.Request(app.engine, l.pair, req)
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		l.requesting = false
+		if err != nil {
+			log.Printf("failed to request the volume profile of %s: %v", l.pair, err)
+			return
+		}
+		l.profile = &msg
+	}()
+}
+
+// timeRange returns the range of the profile in unix seconds for the mode
+// of the layer, a to of zero is until now.
+func (l *VolumeProfileLayer) timeRange(chart *ChartWidget) (from, to int64, ok bool) {
+	switch l.mode {
+	case profileSession:
+		now := time.Now().Unix()
+		return now / settings.SessionLength * settings.SessionLength, 0, true
+	case profileVisible:
+		if chart.startTime.IsZero() {
+			return 0, 0, false
+		}
+		rect := chart.GetWidget().Rect
+		from = chart.getBarUnixAtX(float64(rect.Min.X))
+		to = chart.getNextBarUnix(chart.getBarUnixAtX(float64(rect.Max.X)))
+		if to >= time.Now().Unix() {
+			to = 0
+		}
+		return from, to, true
+	case profileFixed:
+		return l.anchorFrom, l.anchorTo, l.anchorFrom != 0
+	}
+	return 0, 0, false
+}
+
+func (l *VolumeProfileLayer) render(screen *ebiten.Image, chart *ChartWidget) {
+	l.mu.Lock()
+	msg := l.profile
+	l.mu.Unlock()
+
+	rect := chart.GetWidget().Rect
+	if l.mode == profileFixed && l.anchorFrom != 0 {
+		l.renderAnchor(screen, chart, l.anchorFrom)
+		if l.anchorTo != 0 {
+			l.renderAnchor(screen, chart, l.anchorTo)
+		}
+	}
+	if msg == nil || len(msg.Levels) == 0 {
+		return
+	}
+
+	maxWidth := float32(rect.Dx()) * settings.VolumeProfileWidthPerc
+	// The fixed range profile starts at its first bar, the others hang off
+	// the right edge of the chart.
+	left, right := float32(rect.Max.X)-maxWidth, float32(rect.Max.X)
+	fromRight := true
+	if l.mode == profileFixed {
+		left = chart.getUnixX(l.anchorFrom)
+		right = left + maxWidth
+		if l.anchorTo != 0 {
+			right = chart.getUnixX(l.anchorTo)
+			maxWidth = min(maxWidth, right-left)
+		}
+		fromRight = false
+	}
+
+	rowHeight := float64(msg.Grouping) * l.tickSize
+	maxVolume := 0.0
+	for _, level := range msg.Levels {
+		maxVolume = max(maxVolume, level.Buy+level.Sell)
+	}
+	for _, level := range msg.Levels {
+		top := chart.getPriceYScreen(level.Price + rowHeight)
+		bottom := chart.getPriceYScreen(level.Price)
+		if bottom < float32(rect.Min.Y) || top > float32(rect.Max.Y) {
+			continue
+		}
+		height := max(bottom-top-1, 1)
+		buyWidth := maxWidth * float32(level.Buy/maxVolume)
+		sellWidth := maxWidth * float32(level.Sell/maxVolume)
+
+		alpha := uint8(90)
+		if level.Price >= msg.VAL && level.Price <= msg.VAH {
+			alpha = 180
+		}
+		buyX, sellX := left, left+buyWidth
+		if fromRight {
+			buyX, sellX = right-buyWidth, right-buyWidth-sellWidth
+		}
+		vector.DrawFilledRect(screen, buyX, top, buyWidth, height, withAlpha(settings.VolumeProfileBuyColor, alpha), false)
+		vector.DrawFilledRect(screen, sellX, top, sellWidth, height, withAlpha(settings.VolumeProfileSellColor, alpha), false)
+	}
+
+	mid := func(price float64) float32 {
+		return chart.getPriceYScreen(price + rowHeight/2)
+	}
+	vector.StrokeLine(screen, left, mid(msg.POC), right, mid(msg.POC), 1, settings.VolumeProfilePOCColor, false)
+	DrawDashedLine(screen, left, mid(msg.VAH), right, mid(msg.VAH), 1, 4, 4, settings.VolumeProfileValueAreaColor, false)
+	DrawDashedLine(screen, left, mid(msg.VAL), right, mid(msg.VAL), 1, 4, 4, settings.VolumeProfileValueAreaColor, false)
+
+	// The nodes are marked on the side the histogram grows from.
+	edge := left
+	if fromRight {
+		edge = right - 6
+	}
+	for _, price := range msg.HVNs {
+		vector.DrawFilledRect(screen, edge, mid(price)-1, 6, 3, settings.VolumeProfileHVNColor, false)
+	}
+	for _, price := range msg.LVNs {
+		vector.DrawFilledRect(screen, edge, mid(price)-1, 6, 3, settings.VolumeProfileLVNColor, false)
+	}
+}
+
+func (l *VolumeProfileLayer) renderAnchor(screen *ebiten.Image, chart *ChartWidget, unix int64) {
+	rect := chart.GetWidget().Rect
+	x := chart.getUnixX(unix)
+	if x < float32(rect.Min.X) || x > float32(rect.Max.X) {
+		return
+	}
+	DrawDashedLine(screen, x, float32(rect.Min.Y), x, float32(rect.Max.Y), 1, 4, 4, settings.VolumeProfileValueAreaColor, false)
+}
+
+func withAlpha(c color.RGBA, alpha uint8) color.RGBA {
+	return color.RGBA{R: c.R, G: c.G, B: c.B, A: alpha}
+}
+
+func (l *VolumeProfileLayer) delete() {
+	if l.removeAnchorHandler != nil {
+		l.removeAnchorHandler()
+	}
+}
@@ -0,0 +1,184 @@
+package app
+
+import (
+	"image/color"
+	"log"
+	"sync"
+	"time"
+
+	"marketmonkey/actor/vwap"
+	"marketmonkey/event"
+	"marketmonkey/settings"
+
+	evt "github.com/ebitenui/ebitenui/event"
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/vector"
+)
+
+// How often the VWAP is requested again while it is live.
+const vwapRefreshInterval = time.Second
+
+type vwapMode int
+
+const (
+	vwapOff vwapMode = iota
+	// the VWAP starts over at every session
+	vwapSession
+	// the VWAP from a bar clicked with the anchor button
+	vwapAnchored
+)
+
+func (m vwapMode) String() string {
+	switch m {
+	case vwapSession:
+		return "VWAP session"
+	case vwapAnchored:
+		return "VWAP anchored"
+	}
+	return "VWAP off"
+}
+
+// VWAPLayer draws the volume weighted average price with its standard
+// deviation bands, see settings.VWAP. The values are summed up by the vwap
+// actor of the pair on request.
+type VWAPLayer struct {
+	pair event.Pair
+	mode vwapMode
+
+	// the bar the anchored VWAP starts at, zero while unset
+	anchor int64
+
+	removeAnchorHandler evt.RemoveHandlerFunc
+
+	// the range and step of the last request
+	from, step  int64
+	lastRequest time.Time
+
+	mu         sync.Mutex
+	requesting bool
+	vwap       *event.VWAP
+}
+
+func NewVWAPLayer(pair event.Pair, mode vwapMode) *VWAPLayer {
+	return &VWAPLayer{
+		pair: pair,
+		mode: mode,
+	}
+}
+
+func (l *VWAPLayer) initialize(chart *ChartWidget) {
+	l.removeAnchorHandler = chart.anchorEvent.AddHandler(func(unix any) {
+		if l.mode != vwapAnchored {
+			return
+		}
+		l.anchor = unix.(int64)
+		chart.isDirty = true
+	})
+}
+
+func (l *VWAPLayer) update(chart *ChartWidget) {
+	from, ok := l.anchorUnix(chart)
+	if !ok {
+		l.mu.Lock()
+		l.vwap = nil
+		l.mu.Unlock()
+		return
+	}
+	step := chart.interval
+	if chart.barType != event.BarTime {
+		step = settings.VWAP.Resolution
+	}
+	changed := from != l.from || step != l.step
+	if !changed && time.Since(l.lastRequest) < vwapRefreshInterval {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.requesting {
+		return
+	}
+	l.requesting = true
+	l.from, l.step = from, step
+	l.lastRequest = time.Now()
+
+	req := event.VWAPRequest{From: from, Step: step, Session: l.mode == vwapSession}
+	go func() {
+		msg, err := vwap.Request(app.engine, l.pair, req)
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		l.requesting = false
+		if err != nil {
+			log.Printf("failed to request the vwap of %s: %v", l.pair, err)
+			return
+		}
+		l.vwap = &msg
+	}()
+}
+
+// anchorUnix returns where the VWAP starts in unix seconds for the mode of
+// the layer. The session VWAP starts at the session of the first bar on the
+// screen.
+func (l *VWAPLayer) anchorUnix(chart *ChartWidget) (int64, bool) {
+	switch l.mode {
+	case vwapSession:
+		if chart.startTime.IsZero() {
+			return 0, false
+		}
+		unix := chart.getBarUnixAtX(float64(chart.GetWidget().Rect.Min.X))
+		return unix / settings.SessionLength * settings.SessionLength, true
+	case vwapAnchored:
+		return l.anchor, l.anchor != 0
+	}
+	return 0, false
+}
+
+func (l *VWAPLayer) render(screen *ebiten.Image, chart *ChartWidget) {
+	l.mu.Lock()
+	msg := l.vwap
+	l.mu.Unlock()
+
+	rect := chart.GetWidget().Rect
+	dst := screen.SubImage(rect).(*ebiten.Image)
+	col := settings.VWAPColor
+	if l.mode == vwapAnchored {
+		col = settings.AnchoredVWAPColor
+		if l.anchor != 0 {
+			x := chart.getUnixX(l.anchor)
+			DrawDashedLine(dst, x, float32(rect.Min.Y), x, float32(rect.Max.Y), 1, 4, 4, col, false)
+		}
+	}
+	if msg == nil || len(msg.Points) < 2 {
+		return
+	}
+
+	center := float32(chart.barWidth / 2)
+	line := func(deviations float64, col color.Color, width float32) {
+		for i := 1; i < len(msg.Points); i++ {
+			p1, p2 := msg.Points[i-1], msg.Points[i]
+			// The session VWAP starts over without a line between them.
+			if msg.Session && p1.Unix/settings.SessionLength != p2.Unix/settings.SessionLength {
+				continue
+			}
+			x1, x2 := chart.getUnixX(p1.Unix)+center, chart.getUnixX(p2.Unix)+center
+			if x2 < float32(rect.Min.X) || x1 > float32(rect.Max.X) {
+				continue
+			}
+			y1 := chart.getPriceYScreen(p1.VWAP + deviations*p1.StdDev)
+			y2 := chart.getPriceYScreen(p2.VWAP + deviations*p2.StdDev)
+			vector.StrokeLine(dst, x1, y1, x2, y2, width, col, true)
+		}
+	}
+	for i, band := range settings.VWAP.Bands {
+		// The outer bands fade out.
+		bandCol := withAlpha(settings.VWAPBandColor, uint8(200/(i+1)))
+		line(band, bandCol, 1)
+		line(-band, bandCol, 1)
+	}
+	line(0, col, float32(settings.LineChartStrokeWidth))
+}
+
+func (l *VWAPLayer) delete() {
+	if l.removeAnchorHandler != nil {
+		l.removeAnchorHandler()
+	}
+}
@@ -0,0 +1,123 @@
+package app
+
+import (
+	"image/color"
+	"math"
+	"sync"
+	"time"
+
+	"marketmonkey/actor/session"
+	"marketmonkey/event"
+	"marketmonkey/settings"
+
+	"github.com/anthdm/hollywood/actor"
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/vector"
+)
+
+// The amount of walls kept, the oldest are dropped first.
+const wallMaxCount = 1000
+
+// WallLayer draws the lifetime of every wall as a horizontal segment at its
+// price, from the time it appeared until it was pulled or filled.
+type WallLayer struct {
+	pair       event.Pair
+	eventCh    chan any
+	sessionPID *actor.PID
+
+	mu    sync.Mutex
+	walls map[uint64]event.Wall
+	order []uint64
+}
+
+func NewWallLayer(pair event.Pair) *WallLayer {
+	eventCh := make(chan any)
+	streams := []session.Stream{{
+		Stream: event.StreamWalls,
+	}}
+	pid := app.engine.Spawn(session.New(eventCh, pair, streams), "session")
+
+	layer := &WallLayer{
+		pair:       pair,
+		eventCh:    eventCh,
+		sessionPID: pid,
+		walls:      make(map[uint64]event.Wall),
+	}
+
+	go layer.receiveData()
+
+	return layer
+}
+
+func (l *WallLayer) receiveData() {
+	for ev := range l.eventCh {
+		switch msg := ev.(type) {
+		case event.Walls:
+			l.mu.Lock()
+			for _, wall := range msg.Walls {
+				if _, ok := l.walls[wall.ID]; !ok {
+					l.order = append(l.order, wall.ID)
+					if len(l.order) > wallMaxCount {
+						delete(l.walls, l.order[0])
+						l.order = l.order[1:]
+					}
+				}
+				l.walls[wall.ID] = wall
+			}
+			l.mu.Unlock()
+		}
+	}
+}
+
+func (l *WallLayer) initialize(_ *ChartWidget) {}
+
+func (l *WallLayer) update(_ *ChartWidget) {}
+
+func (l *WallLayer) render(screen *ebiten.Image, chart *ChartWidget) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	rect := chart.GetWidget().Rect
+	maxPeak := 0.0
+	for _, wall := range l.walls {
+		maxPeak = max(maxPeak, wall.PeakSize)
+	}
+	if maxPeak == 0 {
+		return
+	}
+
+	now := time.Now().UnixMilli()
+	barX := func(unix int64) float32 {
+		if chart.barType != event.BarTime {
+			return chart.getUnixX(unix / 1000)
+		}
+		index := float64(unix-chart.startTime.UnixMilli()) / 1000 / float64(chart.interval)
+		return float32(rect.Min.X) + float32((index-chart.barOffset)*chart.barWidth)
+	}
+	for _, wall := range l.walls {
+		end := wall.End
+		if end == 0 {
+			end = now
+		}
+		x1 := max(barX(wall.Start), float32(rect.Min.X))
+		x2 := min(barX(end), float32(rect.Max.X))
+		y := chart.getPriceYScreen(wall.Price)
+		if x2 <= x1 || y < float32(rect.Min.Y) || y > float32(rect.Max.Y) {
+			continue
+		}
+		col := settings.Red
+		if wall.IsBid {
+			col = settings.Green
+		}
+		alpha := uint8(255)
+		if wall.State != event.WallActive {
+			alpha = 120
+		}
+		width := float32(1+3*math.Sqrt(wall.PeakSize/maxPeak)) * settings.Scale
+		vector.StrokeLine(screen, x1, y, x2, y, width, color.NRGBA{R: col.R, G: col.G, B: col.B, A: alpha}, false)
+	}
+}
+
+func (l *WallLayer) delete() {
+	app.engine.Poison(l.sessionPID)
+}
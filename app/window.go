@@ -62,6 +62,10 @@ func NewWindow(widg widgetCloser, title string, rect img.Rectangle) *widget.Wind
 		),
 	))
 
+	if pw, ok := widg.(pairWidget); ok {
+		innerContainer.AddChild(newFeedStateLabel(pw.Pair()))
+	}
+
 	foo, ok := widg.(Toolbar)
 	if ok {
 		innerContainer.AddChild(foo.Toolbar())
@@ -2,7 +2,15 @@ package main
 
 import (
 	"log"
+	"marketmonkey/actor/aggregate"
+	"marketmonkey/actor/consumer/binance"
 	"marketmonkey/actor/consumer/binancef"
+	"marketmonkey/actor/consumer/bybit"
+	"marketmonkey/actor/consumer/coinbase"
+	"marketmonkey/actor/consumer/kraken"
+	"marketmonkey/actor/health"
+	"marketmonkey/actor/history"
+	"marketmonkey/actor/spread"
 	"marketmonkey/app"
 
 	"github.com/anthdm/hollywood/actor"
@@ -15,9 +23,20 @@ func main() {
 		log.Fatal(err)
 	}
 
-	//engine.Spawn(kraken.New(), "kraken", actor.WithID("1"))
+	// The health monitor needs to be up before the feeds report to it.
+	engine.Spawn(health.New(), "health", actor.WithID("1"))
+	engine.Spawn(history.New(history.DefaultFetchers()), "history", actor.WithID("1"))
+
+	engine.Spawn(binance.New(), "binance", actor.WithID("1"))
 	engine.Spawn(binancef.New(), "binancef", actor.WithID("1"))
-	// engine.Spawn(bybit.New(), "bybit", actor.WithID("1"))
+	engine.Spawn(bybit.New(), "bybit", actor.WithID("1"))
+	engine.Spawn(coinbase.New(), "coinbase", actor.WithID("1"))
+	engine.Spawn(kraken.New(), "kraken", actor.WithID("1"))
+
+	// The aggregated and spread markets subscribe to the venues above, so
+	// they need to be spawned after them.
+	engine.Spawn(aggregate.New(), "aggregated", actor.WithID("1"))
+	engine.Spawn(spread.New(), "spread", actor.WithID("1"))
 
 	w, h := ebiten.Monitor().Size()
 	ebiten.SetWindowSize(w, h)
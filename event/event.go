@@ -8,6 +8,9 @@ type Trade struct {
 	Qty   float64
 	IsBuy bool
 	Unix  int64
+	// Venue is the exchange the trade was printed on. Only set on trades
+	// of an aggregated pair, where Pair.Exchange is the synthetic market.
+	Venue string
 }
 
 func (t Trade) GetTimeframe() int64 { return 0 }
@@ -43,12 +46,17 @@ type HeatmapLevel struct {
 
 type Heatmap struct {
 	PriceGroup float64
-	Pair       Pair
-	Unix       int64
-	Levels     []HeatmapLevel
+	// Grouping is the price grouping in ticks the heatmap was subscribed
+	// with. The default grouping is always published, see
+	// settings.DefaultPriceGrouping.
+	Grouping int64
+	Pair     Pair
+	Unix     int64
+	Levels   []HeatmapLevel
 }
 
-func (h Heatmap) GetTimeframe() int64 { return 0 }
+// The grouping takes the place of the timeframe in the route key.
+func (h Heatmap) GetTimeframe() int64 { return h.Grouping }
 
 type Candle struct {
 	Pair      Pair
@@ -62,10 +70,141 @@ type Candle struct {
 	Vsell     float64
 	Tbuy      float64
 	Tsell     float64
+	// Closed is set on the final update of a candle, once its interval is
+	// over.
+	Closed bool
+	// Bar is the rule the candle is closed by. For the activity bars
+	// Timeframe holds the parameter of the rule and Seq the index of the
+	// bar in its stream, Unix is the time of its first trade.
+	Bar BarType
+	Seq int64
 }
 
+// The parameter of the activity bars takes the place of the timeframe in the
+// route key.
 func (c Candle) GetTimeframe() int64 { return c.Timeframe }
 
+// BarType is the rule the bars of a candle stream are closed by.
+type BarType int
+
+const (
+	// BarTime closes after the timeframe.
+	BarTime BarType = iota
+	// BarTick closes after the parameter amount of trades.
+	BarTick
+	// BarVolume closes once the parameter amount of contracts traded.
+	BarVolume
+	// BarDollar closes once the parameter amount of notional traded.
+	BarDollar
+	// BarRange closes once the next trade would take its range beyond the
+	// parameter amount of ticks.
+	BarRange
+	// BarRenko are bricks of the parameter amount of ticks.
+	BarRenko
+)
+
+func (b BarType) String() string {
+	switch b {
+	case BarTime:
+		return "time"
+	case BarTick:
+		return "tick"
+	case BarVolume:
+		return "volume"
+	case BarDollar:
+		return "dollar"
+	case BarRange:
+		return "range"
+	case BarRenko:
+		return "renko"
+	default:
+		return "unknown"
+	}
+}
+
+// Stream returns the candle stream of the bar type.
+func (b BarType) Stream() Stream {
+	switch b {
+	case BarTick:
+		return StreamTickBars
+	case BarVolume:
+		return StreamVolumeBars
+	case BarDollar:
+		return StreamDollarBars
+	case BarRange:
+		return StreamRangeBars
+	case BarRenko:
+		return StreamRenkoBars
+	default:
+		return StreamCandles
+	}
+}
+
+// BarTypeOf returns the bar type of a candle stream, BarTime for the time
+// based candles and the streams that carry no candles.
+func BarTypeOf(stream Stream) BarType {
+	switch stream {
+	case StreamTickBars:
+		return BarTick
+	case StreamVolumeBars:
+		return BarVolume
+	case StreamDollarBars:
+		return BarDollar
+	case StreamRangeBars:
+		return BarRange
+	case StreamRenkoBars:
+		return BarRenko
+	default:
+		return BarTime
+	}
+}
+
+// FootprintLevel is the volume traded at a price within a bar. Bid is the
+// volume sold into the bid, Ask the volume bought from the ask.
+type FootprintLevel struct {
+	Price float64
+	Bid   float64
+	Ask   float64
+}
+
+// Footprint is the volume at price of a bar. Levels are sorted by price,
+// Delta is the ask minus the bid volume and POC the price with the most
+// volume.
+type Footprint struct {
+	Pair      Pair
+	Timeframe int64
+	Unix      int64
+	Levels    []FootprintLevel
+	Delta     float64
+	POC       float64
+	Closed    bool
+}
+
+func (f Footprint) GetTimeframe() int64 { return f.Timeframe }
+
+// CVD is the cumulative volume delta, the buy minus the sell volume, at a
+// bar of a timeframe. Open, High, Low and Close are the delta accumulated
+// since the start of the stream. SessionStart is the delta at the start of
+// the session of the bar, subtracting it gives the delta of the session.
+// On aggregated pairs Spot and Perp split Close by the kind of the venues.
+type CVD struct {
+	Pair             Pair
+	Timeframe        int64
+	Unix             int64
+	Open             float64
+	High             float64
+	Low              float64
+	Close            float64
+	SessionStart     float64
+	Spot             float64
+	Perp             float64
+	SpotSessionStart float64
+	PerpSessionStart float64
+	Closed           bool
+}
+
+func (c CVD) GetTimeframe() int64 { return c.Timeframe }
+
 type Orderbook struct {
 	Unix      int64
 	Pair      Pair
@@ -76,15 +215,23 @@ type Orderbook struct {
 	BidSizes  []float64
 	BidSums   []float64
 	LastPrice float64
+	// Grouping is the price grouping in ticks the orderbook was subscribed
+	// with. Zero means the raw levels.
+	Grouping int64
 }
 
-func (o Orderbook) GetTimeframe() int64 { return 0 }
+// The grouping takes the place of the timeframe in the route key.
+func (o Orderbook) GetTimeframe() int64 { return o.Grouping }
 
 type BookUpdate struct {
 	Unix int64
 	Pair Pair
 	Asks []BookEntry
 	Bids []BookEntry
+	// Reset clears the book before the levels are applied. It is set on the
+	// first update after the feed reconnected, the diffs missed while it was
+	// down can't be reconciled.
+	Reset bool
 }
 
 type BookEntry struct {
@@ -92,12 +239,450 @@ type BookEntry struct {
 	Size  float64
 }
 
+// BookMetrics are microstructure metrics sampled from the orderbook.
+type BookMetrics struct {
+	Pair Pair
+	Unix int64
+	// Imbalance of the top levels between -1 (all asks) and 1 (all bids).
+	Imbalance float64
+	Mid       float64
+	// WeightedMid is the mid of the size weighted prices of the top levels.
+	WeightedMid float64
+	// Microprice is the mid of the touch weighted by the opposite sizes.
+	Microprice  float64
+	SpreadTicks float64
+	// BidDepth and AskDepth are the sizes within the depth band around the mid.
+	BidDepth float64
+	AskDepth float64
+}
+
+func (m BookMetrics) GetTimeframe() int64 { return 0 }
+
+type LiquidityKind int
+
+const (
+	LiquidityAdded LiquidityKind = iota
+	LiquidityPulled
+	LiquidityConsumed
+)
+
+func (k LiquidityKind) String() string {
+	switch k {
+	case LiquidityAdded:
+		return "added"
+	case LiquidityPulled:
+		return "pulled"
+	case LiquidityConsumed:
+		return "consumed"
+	default:
+		return "unknown"
+	}
+}
+
+// LiquidityChange is the size that was added to or removed from a single
+// book level.
+type LiquidityChange struct {
+	Kind  LiquidityKind
+	Price float64
+	Size  float64
+	IsBid bool
+}
+
+// Liquidity holds the classified book changes since the last publish,
+// summed per level and kind.
+type Liquidity struct {
+	Pair    Pair
+	Unix    int64
+	Changes []LiquidityChange
+}
+
+func (l Liquidity) GetTimeframe() int64 { return 0 }
+
+// Iceberg is a level that traded more than it displayed. It is published
+// again with the same ID while the level keeps trading.
+type Iceberg struct {
+	ID    uint64
+	Pair  Pair
+	Unix  int64
+	Price float64
+	IsBid bool
+	// Displayed is the largest size the level showed in the book.
+	Displayed float64
+	Traded    float64
+	// Hidden is the estimated size that traded without being displayed.
+	Hidden float64
+	// Refills counts how often the level grew back after being hit.
+	Refills int
+}
+
+func (i Iceberg) GetTimeframe() int64 { return 0 }
+
+type WallState int
+
+const (
+	WallActive WallState = iota
+	WallPulled
+	WallFilled
+)
+
+func (s WallState) String() string {
+	switch s {
+	case WallActive:
+		return "active"
+	case WallPulled:
+		return "pulled"
+	case WallFilled:
+		return "filled"
+	default:
+		return "unknown"
+	}
+}
+
+// Wall is an unusually large resting order. Start and End are in unix
+// milliseconds, End is zero while the wall is active.
+type Wall struct {
+	ID       uint64
+	Price    float64
+	IsBid    bool
+	Start    int64
+	End      int64
+	Size     float64
+	PeakSize float64
+	State    WallState
+}
+
+// Walls holds the walls that appeared, changed or ended since the last
+// publish.
+type Walls struct {
+	Pair  Pair
+	Unix  int64
+	Walls []Wall
+}
+
+func (w Walls) GetTimeframe() int64 { return 0 }
+
+// Depth holds the raw levels of the book around the mid. Asks are sorted
+// ascending and bids descending by price.
+type Depth struct {
+	Pair Pair
+	Unix int64
+	Mid  float64
+	Asks []BookEntry
+	Bids []BookEntry
+}
+
+func (d Depth) GetTimeframe() int64 { return 0 }
+
+// BookSnapshotRequest asks the orderbook actor of a pair for its current
+// book. Depth limits the levels per side, zero returns the full book.
+// Grouping is the price grouping in ticks, zero returns the raw levels.
+type BookSnapshotRequest struct {
+	Depth    int
+	Grouping int64
+}
+
+// BookSnapshot is the response to a BookSnapshotRequest. Seq counts the
+// changes to the book, the applied updates as well as the levels evicted when
+// the depth window moves, two snapshots with the same Seq hold the same book.
+type BookSnapshot struct {
+	Pair      Pair
+	Unix      int64
+	Seq       uint64
+	Grouping  int64
+	LastPrice float64
+	// Asks are sorted ascending and bids descending by price.
+	Asks []BookEntry
+	Bids []BookEntry
+}
+
+type L3EventKind int
+
+const (
+	// L3Received is an order that was accepted by the venue, it is not on
+	// the book yet and only advances the sequence.
+	L3Received L3EventKind = iota
+	L3Open
+	L3Match
+	L3Change
+	L3Done
+)
+
+// L3Event is a single message of an order-by-order feed. For a match the
+// OrderID is the resting maker order and Size is the traded size, for a
+// change Size is the new size of the order.
+type L3Event struct {
+	Kind    L3EventKind
+	Seq     int64
+	Unix    int64
+	OrderID string
+	IsBid   bool
+	Price   float64
+	Size    float64
+}
+
+// L3Update is the batch of order events the symbol actor drained from its
+// ingress queue.
+type L3Update struct {
+	Pair   Pair
+	Events []L3Event
+}
+
+// L3Order is a resting order of an L3Snapshot.
+type L3Order struct {
+	ID    string
+	IsBid bool
+	Price float64
+	Size  float64
+}
+
+// L3Snapshot is the full order-by-order book at sequence Seq. Orders are in
+// the queue order of their level.
+type L3Snapshot struct {
+	Pair   Pair
+	Seq    int64
+	Unix   int64
+	Orders []L3Order
+}
+
+// L3Resync asks the consumer of the pair for a new L3Snapshot, sent when the
+// sequence of the order events has a gap.
+type L3Resync struct {
+	Pair Pair
+}
+
+// OrderLevel is a price level of an L3 book with the detail of its orders.
+// The ages are in milliseconds since the orders were opened.
+type OrderLevel struct {
+	Price     float64
+	Size      float64
+	Orders    int
+	OldestAge int64
+	MedianAge int64
+}
+
+// OrderLevels are the top levels of an L3 book. Asks are sorted ascending
+// and bids descending by price.
+type OrderLevels struct {
+	Pair Pair
+	Unix int64
+	Asks []OrderLevel
+	Bids []OrderLevel
+}
+
+func (o OrderLevels) GetTimeframe() int64 { return 0 }
+
+// QueuePositionRequest asks the L3 book of a pair where an order is in the
+// queue of its level.
+type QueuePositionRequest struct {
+	OrderID string
+}
+
+// QueuePosition is the response to a QueuePositionRequest. Ahead is the size
+// resting in front of the order and Position its index in the queue. Found is
+// false when the order is not on the book.
+type QueuePosition struct {
+	OrderID  string
+	Found    bool
+	Price    float64
+	IsBid    bool
+	Size     float64
+	Ahead    float64
+	Position int
+	Orders   int
+	Age      int64
+}
+
+// HistoryRequest asks the history service for the candles of a pair from
+// the REST API of its venue. End is the unix time in seconds the candles end
+// before, zero for the latest ones.
+type HistoryRequest struct {
+	Pair      Pair
+	Timeframe int64
+	End       int64
+	Count     int
+}
+
+// HistoryCandles is the response to a HistoryRequest. The candles are sorted
+// by time and without gaps, they are empty when the venue has no history for
+// the pair or timeframe. Failed is set when the fetch failed, the request can
+// be retried.
+type HistoryCandles struct {
+	Pair      Pair
+	Timeframe int64
+	End       int64
+	Candles   []Candle
+	Failed    bool
+}
+
+// VolumeProfileRequest asks the profile actor of a pair for the volume
+// traded per price from From until To, in unix seconds. A To of zero is
+// until now. Grouping is the height of a row of the profile in ticks.
+type VolumeProfileRequest struct {
+	From     int64
+	To       int64
+	Grouping int64
+}
+
+// VolumeProfileLevel is the volume traded within a row of a profile, Price
+// is the lowest price of the row.
+type VolumeProfileLevel struct {
+	Price float64
+	Buy   float64
+	Sell  float64
+}
+
+// VolumeProfile is the response to a VolumeProfileRequest. Levels are sorted
+// by price. POC is the row with the most volume, VAL and VAH bound the rows
+// around it that hold the value area share of the volume. HVNs and LVNs are
+// the rows of the high and low volume nodes.
+type VolumeProfile struct {
+	Pair     Pair
+	From     int64
+	To       int64
+	Grouping int64
+	Levels   []VolumeProfileLevel
+	POC      float64
+	VAH      float64
+	VAL      float64
+	HVNs     []float64
+	LVNs     []float64
+}
+
+// VWAPRequest asks the vwap actor of a pair for the volume weighted average
+// price from From until To in unix seconds, sampled every Step seconds. A To
+// of zero is until now. With Session the average starts over at every
+// session, otherwise it is anchored at From.
+type VWAPRequest struct {
+	From    int64
+	To      int64
+	Step    int64
+	Session bool
+}
+
+// VWAPPoint is the average price and its volume weighted standard deviation
+// from the anchor until the end of the step starting at Unix.
+type VWAPPoint struct {
+	Unix   int64
+	VWAP   float64
+	StdDev float64
+}
+
+// VWAP is the response to a VWAPRequest. The points are sorted by time, the
+// steps before the first trade after the anchor are left out.
+type VWAP struct {
+	Pair    Pair
+	From    int64
+	To      int64
+	Step    int64
+	Session bool
+	Points  []VWAPPoint
+}
+
+// VenueQuote is the top of the book of a single venue.
+type VenueQuote struct {
+	Venue Pair
+	Bid   float64
+	Ask   float64
+	Unix  int64
+}
+
+// HasQuote reports whether the venue quoted both sides.
+func (q VenueQuote) HasQuote() bool {
+	return q.Bid > 0 && q.Ask > 0
+}
+
+func (q VenueQuote) Mid() float64 {
+	return (q.Bid + q.Ask) / 2
+}
+
+type Spread struct {
+	Pair   Pair
+	Unix   int64
+	Quotes []VenueQuote
+	// Spreads[i][j] is the bid of Quotes[i] minus the ask of Quotes[j] in
+	// basis points of the mid. A positive value means venue i can be sold
+	// above the price venue j can be bought at.
+	Spreads [][]float64
+	// Basis is the futures mid minus the spot mid, HasBasis is false while
+	// either of them has no quote. BasisAnnualized is only set for dated
+	// futures.
+	HasBasis        bool
+	Basis           float64
+	BasisPerc       float64
+	BasisAnnualized float64
+}
+
+func (s Spread) GetTimeframe() int64 { return 0 }
+
+type FeedState int
+
+const (
+	FeedLive FeedState = iota
+	FeedStale
+	FeedReconnecting
+)
+
+func (s FeedState) String() string {
+	switch s {
+	case FeedLive:
+		return "live"
+	case FeedStale:
+		return "stale"
+	case FeedReconnecting:
+		return "reconnecting"
+	default:
+		return "unknown"
+	}
+}
+
+// FeedStats is reported periodically by every symbol actor with the amount
+// of messages it received since the last report.
+type FeedStats struct {
+	Pair     Pair
+	Messages int64
+	// Dropped is the amount of messages that overflowed the ingress queue.
+	Dropped  int64
+	LastUnix int64
+}
+
+// FeedConnection is reported by the consumers whenever the state of their
+// connection changes.
+type FeedConnection struct {
+	Exchange string
+	State    FeedState
+}
+
+// Reconnect asks a consumer to drop its connection and dial again.
+type Reconnect struct{}
+
+type FeedStatus struct {
+	Pair     Pair
+	State    FeedState
+	Rate     float64
+	Dropped  int64
+	LastUnix int64
+}
+
+type FeedHealth struct {
+	Unix    int64
+	Venues  []FeedStatus
+	Symbols []FeedStatus
+}
+
+func (h FeedHealth) GetTimeframe() int64 { return 0 }
+
 type Tick struct {
 }
 
 type TickHeatmap struct {
 }
 
+type TickIngress struct {
+}
+
+type TickMetrics struct {
+}
+
 type Stream int64
 
 const (
@@ -105,6 +690,24 @@ const (
 	StreamOrderbook
 	StreamHeatmap
 	StreamCandles
+	StreamSpread
+	StreamFeedHealth
+	StreamBookMetrics
+	StreamLiquidity
+	StreamIceberg
+	StreamWalls
+	StreamDepth
+	StreamOrderLevels
+	// StreamClosedCandles only carries the candles once they are closed.
+	StreamClosedCandles
+	StreamFootprint
+	StreamCVD
+	// The activity bar streams carry candles, see BarType.
+	StreamTickBars
+	StreamVolumeBars
+	StreamDollarBars
+	StreamRangeBars
+	StreamRenkoBars
 )
 
 type PubSub struct {
@@ -115,6 +718,45 @@ type PubUnsub struct {
 	Streams []uint32
 }
 
+// Backfill subscribes the sender to the stream with the given route key,
+// like PubSub, after sending it the last Count messages of the stream. It is
+// sent to the actor that keeps the history of the stream, which is the trade
+// actor for candles and the orderbook actor for heatmaps. Timeframe is the
+// timeframe of the candles or the grouping of the heatmaps.
+type Backfill struct {
+	Key       uint32
+	Stream    Stream
+	Timeframe int64
+	Count     int
+}
+
+// GroupingSub asks the orderbook actor to compute the given stream with the
+// given price grouping in ticks, in addition to the streams it already
+// publishes. The depth is only computed while subscribed, with a grouping of
+// zero. Every GroupingSub needs a matching GroupingUnsub.
+type GroupingSub struct {
+	Stream   Stream
+	Grouping int64
+}
+
+type GroupingUnsub struct {
+	Stream   Stream
+	Grouping int64
+}
+
+// BarSub asks the trade actor to sample the activity bars of the given
+// stream and parameter. Every BarSub needs a matching BarUnsub, the bars are
+// dropped a while after the last subscriber left.
+type BarSub struct {
+	Stream Stream
+	Param  int64
+}
+
+type BarUnsub struct {
+	Stream Stream
+	Param  int64
+}
+
 type TimeFramer interface {
 	GetTimeframe() int64
 }
@@ -63,18 +63,18 @@ func (rb *Buffer[T]) Last() T {
 		var zero T
 		return zero
 	}
-	return rb.items[rb.tail-1]
+	return rb.items[rb.index(rb.count-1)]
 }
 
 func (rb *Buffer[T]) SecondLast() T {
 	rb.mu.Lock()
 	defer rb.mu.Unlock()
 
-	if rb.count == 0 {
+	if rb.count < 2 {
 		var zero T
 		return zero
 	}
-	return rb.items[rb.tail-2]
+	return rb.items[rb.index(rb.count-2)]
 }
 
 func (rb *Buffer[T]) First() T {
@@ -88,6 +88,32 @@ func (rb *Buffer[T]) First() T {
 	return rb.items[rb.head]
 }
 
+// At returns the item at the given position, the oldest item is at 0.
+func (rb *Buffer[T]) At(i int) T {
+	rb.mu.Lock()
+	defer rb.mu.Unlock()
+
+	return rb.items[rb.index(i)]
+}
+
+// Tail returns a copy of the last n items, the oldest first.
+func (rb *Buffer[T]) Tail(n int) []T {
+	rb.mu.Lock()
+	defer rb.mu.Unlock()
+
+	n = min(n, rb.count)
+	items := make([]T, n)
+	for i := range items {
+		items[i] = rb.items[rb.index(rb.count-n+i)]
+	}
+	return items
+}
+
+// index returns the index in items of the item at the given position.
+func (rb *Buffer[T]) index(i int) int {
+	return (rb.head + i) % rb.size
+}
+
 func (rb *Buffer[T]) GetRange(start, end int) []T {
 	rb.mu.Lock()
 	defer rb.mu.Unlock()
@@ -102,5 +128,5 @@ func (rb *Buffer[T]) SetLast(item T) {
 	rb.mu.Lock()
 	defer rb.mu.Unlock()
 
-	rb.items[rb.tail-1] = item
+	rb.items[rb.index(rb.count-1)] = item
 }
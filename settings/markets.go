@@ -1,7 +1,16 @@
 package settings
 
+import (
+	"time"
+
+	"marketmonkey/event"
+)
+
 const (
-	Binancef = "binancef"
+	Binancef   = "binancef"
+	Aggregated = "aggregated"
+	Spread     = "spread"
+	Health     = "health"
 )
 
 var Markets = map[string]Market{
@@ -9,33 +18,277 @@ var Markets = map[string]Market{
 		Name: Binancef,
 		Symbols: map[string]Symbol{
 			"btcusdt": {
-				Name:     "btcusdt",
-				TickSize: 0.10,
+				Name:           "btcusdt",
+				TickSize:       0.10,
+				DepthWindow:    DepthWindow{Ticks: 20000},
+				HeatmapMinSize: 1,
+				HeatmapMaxSize: 100,
 			},
 			"solusdt": {
-				Name:     "solusdt",
-				TickSize: 0.001,
+				Name:           "solusdt",
+				TickSize:       0.001,
+				DepthWindow:    DepthWindow{Percent: 5},
+				HeatmapMinSize: 100,
+				HeatmapMaxSize: 10000,
 			},
 			"ethusdt": {
-				Name:     "ethusdt",
-				TickSize: 0.01,
+				Name:           "ethusdt",
+				TickSize:       0.01,
+				DepthWindow:    DepthWindow{Percent: 5},
+				HeatmapMinSize: 10,
+				HeatmapMaxSize: 1000,
 			},
 			"trumpusdt": {
-				Name:     "trumpusdt",
-				TickSize: 0.001,
+				Name:           "trumpusdt",
+				TickSize:       0.001,
+				DepthWindow:    DepthWindow{Percent: 10},
+				HeatmapMinSize: 100,
+				HeatmapMaxSize: 50000,
+			},
+		},
+	},
+	Aggregated: {
+		Name: Aggregated,
+		Symbols: map[string]Symbol{
+			"btcusd": {
+				Name:           "btcusd",
+				TickSize:       0.10,
+				HeatmapMinSize: 1,
+				HeatmapMaxSize: 100,
+			},
+			"ethusd": {
+				Name:           "ethusd",
+				TickSize:       0.01,
+				HeatmapMinSize: 10,
+				HeatmapMaxSize: 1000,
 			},
 		},
 	},
 }
 
+// Aggregates holds the venues that are merged into each symbol of the
+// aggregated market.
+var Aggregates = map[string]Aggregate{
+	"btcusd": {
+		Sources: []Source{
+			{Exchange: "binancef", Symbol: "btcusdt", Perp: true},
+			{Exchange: "bybit", Symbol: "btcusdt", Perp: true},
+			{Exchange: "coinbase", Symbol: "btcusd"},
+			{Exchange: "kraken", Symbol: "xbtusd"},
+		},
+	},
+	"ethusd": {
+		Sources: []Source{
+			{Exchange: "binancef", Symbol: "ethusdt", Perp: true},
+			{Exchange: "bybit", Symbol: "ethusdt", Perp: true},
+			{Exchange: "coinbase", Symbol: "ethusd"},
+			{Exchange: "kraken", Symbol: "ethusd"},
+		},
+	},
+}
+
+// SpreadMonitors holds the venues that are compared against each other
+// for every asset of the spread market.
+var SpreadMonitors = map[string]SpreadMonitor{
+	"btcusd": {
+		Venues: []Source{
+			{Exchange: "binancef", Symbol: "btcusdt"},
+			{Exchange: "binance", Symbol: "btcusdt"},
+			{Exchange: "bybit", Symbol: "btcusdt"},
+			{Exchange: "coinbase", Symbol: "btcusd"},
+			{Exchange: "kraken", Symbol: "xbtusd"},
+		},
+		Futures: Source{Exchange: "binancef", Symbol: "btcusdt"},
+		Spot:    Source{Exchange: "binance", Symbol: "btcusdt"},
+	},
+}
+
+// BookMetrics configures the microstructure metrics of the orderbooks.
+var BookMetrics = BookMetricsConfig{
+	Interval: time.Second,
+	Levels:   10,
+	DepthBps: 10,
+}
+
+type BookMetricsConfig struct {
+	// Interval the metrics are sampled and published at.
+	Interval time.Duration
+	// Levels is the amount of top levels the imbalance and the weighted mid
+	// are calculated over.
+	Levels int
+	// DepthBps is the band around the mid in basis points the depth is
+	// summed over.
+	DepthBps float64
+}
+
+// Icebergs configures the iceberg detection of the orderbooks.
+var Icebergs = IcebergConfig{
+	Window:     time.Second * 5,
+	MinRatio:   2,
+	MinRefills: 2,
+}
+
+type IcebergConfig struct {
+	// Window is how long a level is tracked after it was last hit.
+	Window time.Duration
+	// A level is an iceberg once it traded MinRatio times its displayed
+	// size, or refilled MinRefills times and traded more than displayed.
+	MinRatio   float64
+	MinRefills int
+}
+
+// History is the amount of messages kept for the backfill of new
+// subscribers, per candle, CVD and footprint timeframe and per heatmap
+// grouping. The CVD bars are kept as many as the candles.
+var History = HistoryConfig{
+	Candles:    1000,
+	Heatmaps:   600,
+	Footprints: 300,
+}
+
+type HistoryConfig struct {
+	Candles int
+	// Footprints hold every traded price of a bar, fewer of them are kept.
+	Footprints int
+	// Heatmaps are kept at most one per second.
+	Heatmaps int
+}
+
+// SessionLength is the length of a trading session in seconds, the sessions
+// start at its multiples in UTC.
+var SessionLength int64 = 86400
+
+// VolumeProfile configures the volume by price the profile actors keep.
+var VolumeProfile = VolumeProfileConfig{
+	Resolution: 60,
+	Retention:  86400 * 2,
+	ValueArea:  0.7,
+	NodeWindow: 3,
+}
+
+type VolumeProfileConfig struct {
+	// Resolution is the length in seconds of the slices the volume is kept
+	// in, the ranges of the profiles are rounded to it.
+	Resolution int64
+	// Retention is how long in seconds the volume is kept.
+	Retention int64
+	// ValueArea is the share of the volume in the value area.
+	ValueArea float64
+	// A row is a high or low volume node when it has the most or the least
+	// volume of the NodeWindow rows on each side of it.
+	NodeWindow int
+}
+
+// VWAP configures the volume weighted prices the vwap actors keep.
+var VWAP = VWAPConfig{
+	Resolution: 10,
+	Retention:  86400 * 2,
+	Bands:      []float64{1, 2, 3},
+}
+
+type VWAPConfig struct {
+	// Resolution is the length in seconds of the slices the traded volume
+	// is summed up in, the anchors and steps are rounded to it.
+	Resolution int64
+	// Retention is how long in seconds the slices are kept.
+	Retention int64
+	// Bands are the distances of the bands around the VWAP in standard
+	// deviations.
+	Bands []float64
+}
+
+// DepthRange is the range around the mid in percent of the published depth.
+// It bounds the range the depth chart can show.
+var DepthRange = 10.0
+
+// Walls configures the wall tracking of the orderbooks.
+var Walls = WallConfig{
+	Levels: 100,
+	Ratio:  5,
+}
+
+type WallConfig struct {
+	// Levels is the amount of top levels per side a wall is searched in and
+	// the average size is taken over.
+	Levels int
+	// A level is a wall once its size is Ratio times the average size of
+	// its side. It ends once it drops below half of that.
+	Ratio float64
+}
+
 type Symbol struct {
 	Name         string
 	InternalName string
 	PriceGroup   float64
 	TickSize     float64
+	DepthWindow  DepthWindow
+	// HeatmapMinSize and HeatmapMaxSize are the sizes of a single tick level
+	// that map to the lowest and highest intensity in the fixed normalization.
+	// The rows of a grouped heatmap scale them by the grouping.
+	HeatmapMinSize float64
+	HeatmapMaxSize float64
+}
+
+// FallbackTickSize is used for the symbols without a configured tick size.
+const FallbackTickSize = 0.01
+
+// TickSize returns the configured tick size of the pair, or FallbackTickSize
+// when it has none.
+func TickSize(pair event.Pair) float64 {
+	if size := Markets[pair.Exchange].Symbols[pair.Symbol].TickSize; size > 0 {
+		return size
+	}
+	return FallbackTickSize
+}
+
+// DefaultDepthWindow is used for symbols that have no window configured.
+var DefaultDepthWindow = DepthWindow{Percent: 5}
+
+// DepthWindow is the price range around the last price in which the
+// orderbook keeps its levels. Set either Ticks or Percent, both are the
+// distance from the last price to each edge of the window.
+type DepthWindow struct {
+	Ticks   int
+	Percent float64
+}
+
+// HalfWidth returns the distance from the given price to each edge of the
+// window. Ticks take precedence over Percent.
+func (w DepthWindow) HalfWidth(price, tickSize float64) float64 {
+	if w.Ticks > 0 && tickSize > 0 {
+		return float64(w.Ticks) * tickSize
+	}
+	if w.Percent > 0 {
+		return price * w.Percent / 100
+	}
+	return DefaultDepthWindow.HalfWidth(price, tickSize)
 }
 
 type Market struct {
 	Name    string
 	Symbols map[string]Symbol
 }
+
+type Source struct {
+	Exchange string
+	Symbol   string
+	// Perp marks the perpetual futures venues of an aggregate, its CVD is
+	// split into spot and perp by it.
+	Perp bool
+}
+
+type Aggregate struct {
+	Sources []Source
+	// Notional converts the quantity of every trade into its USD value
+	// (price * qty) so contract sizes of different venues add up.
+	Notional bool
+}
+
+type SpreadMonitor struct {
+	Venues  []Source
+	Futures Source
+	Spot    Source
+	// Expiry of the futures contract in unix seconds. Zero means a
+	// perpetual, its basis is shown raw and not annualized.
+	Expiry int64
+}
@@ -5,6 +5,8 @@ import (
 	"log"
 	"os"
 
+	"marketmonkey/event"
+
 	"github.com/hajimehoshi/ebiten/v2"
 	"github.com/hajimehoshi/ebiten/v2/text/v2"
 	"golang.org/x/exp/shiny/materialdesign/colornames"
@@ -58,11 +60,34 @@ var (
 	ChartCrossHairColor                      = colornames.BlueGrey800
 	LineChartLineColor                       = colornames.Blue300
 	LineChartStrokeWidth                     = 1.5 * Scale
+	ChartPaneHeight                          = 100 * Scale
+	ChartPaneLineColor                       = colornames.Orange300
+	LiquidityAddedColor                      = colornames.Blue300
+	LiquidityPulledColor                     = colornames.Orange600
+	IcebergBidColor                          = colornames.Cyan300
+	IcebergAskColor                          = colornames.Pink300
+	FootprintTextColor                       = colornames.Grey400
+	FootprintPOCColor                        = colornames.Orange300
+	VolumeProfileBuyColor                    = colornames.Teal300
+	VolumeProfileSellColor                   = colornames.Pink300
+	VolumeProfilePOCColor                    = colornames.Orange300
+	VolumeProfileValueAreaColor              = colornames.Grey400
+	VolumeProfileHVNColor                    = colornames.Amber200
+	VolumeProfileLVNColor                    = colornames.BlueGrey300
+	CVDSpotColor                             = colornames.Cyan300
+	CVDPerpColor                             = colornames.Purple200
+	VWAPColor                                = colornames.Yellow300
+	AnchoredVWAPColor                        = colornames.LightBlue300
+	VWAPBandColor                            = colornames.Grey500
+	TradeBubbleBuyColor                      = colornames.GreenA400
+	TradeBubbleSellColor                     = colornames.RedA200
 	CandleStickGreen                         = Green
 	CandleStickRed                           = Red
 	VolumeBarGreen                           = colornames.GreenA100
 	VolumeBarRed                             = colornames.PinkA100
 	VolumeBarHeightPerc              float32 = 0.15
+	VolumeProfileWidthPerc           float32 = 0.25
+	TradeBubbleMaxRadius             float32 = 20 * Scale
 
 	HeatmapStartColor = PanelBackgroundColor
 	HeatmapEndColor   = color.RGBA{255, 255, 0, 255}
@@ -75,6 +100,9 @@ var (
 
 	// Buttons
 	PanChartButton = ebiten.MouseButton0
+	// AnchorChartButton anchors the drawings that start at a bar, like the
+	// fixed range volume profile.
+	AnchorChartButton = ebiten.MouseButton2
 
 	MenuButtonHoverBg         = colornames.Orange300
 	MenuButtonClickBg         = colornames.Orange600
@@ -101,6 +129,49 @@ var (
 		{Interval: 604800},
 		{Interval: 2629800},
 	}
+
+	// ActivityBars are the bars the charts can show besides the time based
+	// ones. The parameter of the range and renko bars is in ticks.
+	ActivityBars = []BarConfig{
+		{Bar: event.BarTick, Param: 100},
+		{Bar: event.BarTick, Param: 1000},
+		{Bar: event.BarVolume, Param: 100},
+		{Bar: event.BarDollar, Param: 1000000},
+		{Bar: event.BarRange, Param: 100},
+		{Bar: event.BarRenko, Param: 100},
+	}
+
+	// PriceGroupings are the price groupings in ticks the orderbook and
+	// heatmap can be switched between.
+	PriceGroupings       = []int64{1, 10, 50, 100}
+	DefaultPriceGrouping = int64(50)
+
+	// FootprintBuckets are the price buckets in ticks the footprint chart
+	// can be switched between.
+	FootprintBuckets       = []int64{1, 5, 10, 25, 50, 100}
+	DefaultFootprintBucket = int64(10)
+	// A footprint cell is highlighted when its volume is this many times
+	// the volume of the opposite side diagonally next to it.
+	FootprintImbalanceRatio = 3.0
+
+	// TradeBubbleMinSizes are the minimum notionals in quote currency the
+	// trade bubbles can be filtered to. TradeBubbleMerge merges the fills of
+	// the same millisecond by default.
+	TradeBubbleMinSizes = []float64{0, 10000, 50000, 100000, 500000, 1000000}
+	TradeBubbleMerge    = true
+
+	// DepthRanges are the ranges around the mid in percent the depth chart
+	// can be switched between.
+	DepthRanges       = []float64{0.5, 1, 2, 5, 10}
+	DefaultDepthRange = 2.0
+
+	// HeatmapPercentileWindow is the amount of heatmap snapshots the rolling
+	// percentile normalization is taken over.
+	HeatmapPercentileWindow = 120
+	HeatmapPercentile       = 0.99
+	// HeatmapDecayHalfLife is the half life of the max in the decayed max
+	// normalization, in seconds.
+	HeatmapDecayHalfLife = 60.0
 )
 
 type IntervalConfig struct {
@@ -108,6 +179,11 @@ type IntervalConfig struct {
 	Disabled bool
 }
 
+type BarConfig struct {
+	Bar   event.BarType
+	Param int64
+}
+
 func init() {
 	FontSM, _ = LoadFont(12)
 	FontBase, _ = LoadFont(13)